@@ -2,12 +2,9 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
 	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
 	"github.com/spf13/cobra"
-	"log"
 )
 
 func accountInfoCMD(ctx context.Context) *cobra.Command {
@@ -15,36 +12,35 @@ func accountInfoCMD(ctx context.Context) *cobra.Command {
 	defer span.Done()
 
 	var (
-		ownerKeyFilename string
+		req solana.SolanaAccountInfoRequest
 	)
 
 	cmd := &cobra.Command{
 		Use:   "account_info",
 		Short: "Check Solana account info",
-		Run: func(cmd *cobra.Command, args []string) {
-			ctx = context.Background()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
 
 			m, err := solana.New(ctx)
 			if err != nil {
-				log.Fatalln(err)
+				return err
 			}
 
-			info, err := m.SolanaAccountInfo(ctx, ownerKeyFilename)
+			info, err := m.SolanaAccountInfo(ctx, &req)
 			if err != nil {
-				log.Fatalln(err)
+				return err
 			}
 
-			res, err := json.MarshalIndent(info, "", "    ")
-			if err != nil {
-				log.Fatalln(err)
-			}
-
-			fmt.Println(string(res))
+			return printResult(info)
 		},
 	}
 
-	cmd.Flags().StringVar(&ownerKeyFilename, "owner-key-file", "", "Enter name for a file with owner account key details")
-	cmd.MarkFlagRequired("owner-key-file")
+	cmd.Flags().StringSliceVar(&req.KeyFilenames, "owner-key-file", nil, "Key file of an account to report, only its public key is read, can be repeated")
+	cmd.Flags().StringSliceVar(&req.Addresses, "address", nil, "Address of an account to report, can be repeated")
+	cmd.Flags().StringVar(&req.AddressesFilename, "addresses-file", "", "File with one address or @label per line")
+	cmd.MarkFlagsOneRequired("owner-key-file", "address", "addresses-file")
+
+	markAddressFlags(cmd, "address")
 
 	return cmd
 }
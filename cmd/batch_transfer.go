@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+func batchTransferCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.batchTransferCMD")
+	defer span.Done()
+
+	var (
+		ownerKeyFilename string
+		inputFilename    string
+		journalFilename  string
+		tokenMint        string
+		concurrency      int
+		memo             string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "batch_transfer",
+		Short: "Transfer SOL or SPL token to recipients from CSV",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			f, err := os.Open(inputFilename)
+			if err != nil {
+				return solana.WithKind(solana.ErrorKindInvalidInput, errors.Wrap(err, "failed to open input file"))
+			}
+			recipients, err := solana.ParseBatchTransferCSV(f, func(address string) (string, error) {
+				return m.ResolveAddress(ctx, address)
+			})
+			f.Close()
+			if err != nil {
+				return err
+			}
+
+			if journalFilename == "" {
+				journalFilename = inputFilename + ".journal"
+			}
+
+			var total uint64
+			for _, recipient := range recipients {
+				total += recipient.Amount
+			}
+
+			asset := "lamports"
+			if tokenMint != "" {
+				asset = fmt.Sprintf("tokens of %s", tokenMint)
+			}
+			req := &solana.BatchTransferRequest{
+				OwnerKeyFilename: ownerKeyFilename,
+				TokenMint:        tokenMint,
+				Recipients:       recipients,
+				JournalFilename:  journalFilename,
+				Concurrency:      concurrency,
+				Memo:             memo,
+			}
+
+			estimate, err := m.EstimateBatchTransfer(ctx, req)
+			if err != nil {
+				return err
+			}
+
+			addresses := make([]string, len(recipients))
+			for i, recipient := range recipients {
+				addresses[i] = recipient.Address
+			}
+			lookAlikes, err := m.FindLookAlikes(ctx, ownerKeyFilename, addresses...)
+			if err != nil {
+				return err
+			}
+			if ok, err := confirmLookAlikes(ctx, m, lookAlikes); !ok || err != nil {
+				return err
+			}
+
+			if ok, err := confirm(ctx, m, "Transfer %d %s to %d recipients in %d transactions, %s", total, asset, len(recipients), estimate.Transactions, feeDescription(estimate.TotalLamports, estimate.PriorityFee)); !ok || err != nil {
+				return err
+			}
+
+			result, err := m.BatchTransfer(ctx, req)
+			if err != nil {
+				return err
+			}
+
+			if err := printResult(result); err != nil {
+				return err
+			}
+
+			if err := ctx.Err(); err != nil {
+				return errors.Wrap(err, "batch transfer interrupted, rerun to resume from the journal")
+			}
+			if len(result.Failed) > 0 {
+				return solana.WithKind(solana.ErrorKindTransactionFailed, errors.New("some transfers failed, rerun to retry them"))
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&ownerKeyFilename, "owner-key-file", "", "Enter name for a file with owner account key details")
+	cmd.MarkFlagRequired("owner-key-file")
+
+	cmd.Flags().StringVar(&inputFilename, "input-file", "", "CSV file with address,amount rows (lamports for SOL, base units for SPL)")
+	cmd.MarkFlagRequired("input-file")
+
+	cmd.Flags().StringVar(&tokenMint, "token-mint", "", "Token mint, SOL is transferred when empty")
+	cmd.Flags().StringVar(&journalFilename, "journal-file", "", "Journal of sent transfers, defaults to <input-file>.journal")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 4, "Maximum number of transactions in flight")
+	cmd.Flags().StringVar(&memo, "memo", "", "Memo attached to every batch transaction")
+
+	markAddressFlags(cmd, "token-mint")
+
+	return cmd
+}
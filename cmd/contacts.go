@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+// addressFlagAnnotation marks flags taking addresses, which accept @label.
+const addressFlagAnnotation = "solana_address"
+
+func markAddressFlags(cmd *cobra.Command, names ...string) {
+	for _, name := range names {
+		cmd.Flags().SetAnnotation(name, addressFlagAnnotation, []string{"true"})
+	}
+}
+
+// resolveAddressFlags replaces @label values of address flags with the
+// contact's address before the command runs.
+func resolveAddressFlags(ctx context.Context, m *solana.Module, cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if err != nil || !flag.Changed || flag.Annotations[addressFlagAnnotation] == nil {
+			return
+		}
+
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			values := slice.GetSlice()
+			for i := range values {
+				if values[i], err = m.ResolveAddress(ctx, values[i]); err != nil {
+					return
+				}
+			}
+			err = slice.Replace(values)
+			return
+		}
+
+		var address string
+		if address, err = m.ResolveAddress(ctx, flag.Value.String()); err != nil {
+			return
+		}
+		err = flag.Value.Set(address)
+	})
+
+	return err
+}
+
+func contactsCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.contactsCMD")
+	defer span.Done()
+
+	cmd := &cobra.Command{
+		Use:   "contacts",
+		Short: "Manage the address book of labelled counterparties",
+	}
+
+	cmd.AddCommand(
+		contactsAddCMD(ctx),
+		contactsListCMD(ctx),
+		contactsRemoveCMD(ctx),
+	)
+
+	return cmd
+}
+
+func contactsAddCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.contactsAddCMD")
+	defer span.Done()
+
+	var (
+		contact solana.Contact
+		limits  []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Add a contact",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			if contact.Limits, err = parseContactLimits(limits); err != nil {
+				return err
+			}
+			contact.Label = strings.TrimPrefix(contact.Label, solana.ContactPrefix)
+
+			if err := m.AddContact(ctx, &contact); err != nil {
+				return err
+			}
+
+			return printResult(&contact)
+		},
+	}
+
+	cmd.Flags().StringVar(&contact.Label, "label", "", "Contact label, used as @label in place of the address")
+	cmd.MarkFlagRequired("label")
+
+	cmd.Flags().StringVar(&contact.Address, "address", "", "Contact address")
+	cmd.MarkFlagRequired("address")
+
+	cmd.Flags().StringVar(&contact.Note, "note", "", "Free text note")
+	cmd.Flags().StringVar(&contact.DefaultToken, "default-token", "", "Mint sent by transfer_spl when --token-mint is not set")
+	cmd.Flags().StringSliceVar(&limits, "limit", nil, "Per transfer limit as SOL=<lamports> or <mint>=<base units>, can be repeated")
+	markAddressFlags(cmd, "default-token")
+
+	return cmd
+}
+
+func contactsListCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.contactsListCMD")
+	defer span.Done()
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List contacts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			contacts, err := m.ListContacts(ctx)
+			if err != nil {
+				return err
+			}
+
+			return printResult(contacts)
+		},
+	}
+
+	return cmd
+}
+
+type removeContactResult struct {
+	Removed string `json:"removed"`
+}
+
+func contactsRemoveCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.contactsRemoveCMD")
+	defer span.Done()
+
+	var label string
+
+	cmd := &cobra.Command{
+		Use:   "remove",
+		Short: "Remove a contact",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			if err := m.RemoveContact(ctx, label); err != nil {
+				return err
+			}
+
+			return printResult(&removeContactResult{
+				Removed: strings.TrimPrefix(label, solana.ContactPrefix),
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&label, "label", "", "Label of the contact to remove")
+	cmd.MarkFlagRequired("label")
+
+	return cmd
+}
+
+func parseContactLimits(values []string) (map[string]uint64, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	res := make(map[string]uint64, len(values))
+	for _, value := range values {
+		asset, amount, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, usageError("invalid limit %q, expected SOL=<lamports> or <mint>=<base units>", value)
+		}
+
+		limit, err := strconv.ParseUint(amount, 10, 64)
+		if err != nil {
+			return nil, usageError("invalid limit amount %q: %v", amount, err)
+		}
+		res[asset] = limit
+	}
+
+	return res, nil
+}
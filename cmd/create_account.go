@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"context"
-	"log"
 
 	"github.com/spf13/cobra"
 
@@ -10,6 +9,11 @@ import (
 	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
 )
 
+type createAccountResult struct {
+	PublicKey   string `json:"public_key"`
+	KeyFilename string `json:"key_file"`
+}
+
 func createAccountCMD(ctx context.Context) *cobra.Command {
 	span, _ := tracer.Start(ctx, "cmd.createAccountCMD")
 	defer span.Done()
@@ -19,18 +23,23 @@ func createAccountCMD(ctx context.Context) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "create_account",
 		Short: "Create Solana blockchain account",
-		Run: func(cmd *cobra.Command, args []string) {
-			ctx = context.Background()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
 
 			m, err := solana.New(ctx)
 			if err != nil {
-				log.Fatalln(err)
+				return err
 			}
 
-			if _, err := m.CreateAccount(ctx, outputKeyFilename); err != nil {
-				log.Fatalln(err)
+			account, err := m.CreateAccount(ctx, outputKeyFilename)
+			if err != nil {
+				return err
 			}
 
+			return printResult(&createAccountResult{
+				PublicKey:   account.PublicKey.ToBase58(),
+				KeyFilename: outputKeyFilename,
+			})
 		},
 	}
 
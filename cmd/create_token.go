@@ -2,8 +2,8 @@ package cmd
 
 import (
 	"context"
-	"log"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
@@ -15,9 +15,12 @@ func createTokenCMD(ctx context.Context) *cobra.Command {
 	defer span.Done()
 
 	var (
-		outputKeyFilename string
-		ownerKeyFilename  string
-		initialSupply     uint64
+		outputKeyFilename  string
+		ownerKeyFilename   string
+		checkpointFilename string
+		initialSupply      uint64
+		resume             bool
+		estimate           bool
 
 		name   string
 		symbol string
@@ -27,24 +30,52 @@ func createTokenCMD(ctx context.Context) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "create_token",
 		Short: "Create Solana token",
-		Run: func(cmd *cobra.Command, args []string) {
-			ctx = context.Background()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if !resume && (name == "" || symbol == "" || uri == "") {
+				return usageError("name, symbol and uri are required unless --resume is set")
+			}
 
 			m, err := solana.New(ctx)
 			if err != nil {
-				log.Fatalln(err)
+				return err
 			}
 
-			if err = m.CreateToken(ctx, &solana.CreateTokenRequest{
+			req := &solana.CreateTokenRequest{
 				OutputTokenKeyFilename: outputKeyFilename,
 				OwnerKeyFilename:       ownerKeyFilename,
+				CheckpointFilename:     checkpointFilename,
 				InitialSupply:          initialSupply,
 				Name:                   name,
 				Symbol:                 symbol,
 				Uri:                    uri,
-			}); err != nil {
-				log.Fatalln(err)
+				Resume:                 resume,
+			}
+
+			if estimate {
+				res, err := m.EstimateCreateToken(ctx, req)
+				if err != nil {
+					return err
+				}
+
+				if err := printResult(res); err != nil {
+					return err
+				}
+
+				if !res.Sufficient {
+					return solana.WithKind(solana.ErrorKindInsufficientFunds, errors.New("owner balance does not cover token creation"))
+				}
+
+				return nil
 			}
+
+			res, err := m.CreateToken(ctx, req)
+			if err != nil {
+				return printDryRun(err)
+			}
+
+			return printResult(res)
 		},
 	}
 
@@ -53,15 +84,13 @@ func createTokenCMD(ctx context.Context) *cobra.Command {
 
 	cmd.Flags().StringVar(&outputKeyFilename, "output-key-file", "new_token_key.json", "Enter name for a new file with token key details")
 	cmd.Flags().Uint64Var(&initialSupply, "initial-supply", 0, "Enter amount for an initial supply token")
+	cmd.Flags().StringVar(&checkpointFilename, "checkpoint-file", "", "Token creation checkpoint file, defaults to <output-key-file>.checkpoint.json")
+	cmd.Flags().BoolVar(&resume, "resume", false, "Finish a previously interrupted token creation from its checkpoint")
+	cmd.Flags().BoolVar(&estimate, "estimate", false, "Print the lamports needed for token creation and exit")
 
-	cmd.Flags().StringVar(&name, "name", "", "Token metadata name")
-	cmd.MarkFlagRequired("name")
-
-	cmd.Flags().StringVar(&symbol, "symbol", "", "Token metadata symbol")
-	cmd.MarkFlagRequired("symbol")
-
-	cmd.Flags().StringVar(&uri, "uri", "", "Token metadata Uri")
-	cmd.MarkFlagRequired("uri")
+	cmd.Flags().StringVar(&name, "name", "", "Token metadata name, required unless resuming")
+	cmd.Flags().StringVar(&symbol, "symbol", "", "Token metadata symbol, required unless resuming")
+	cmd.Flags().StringVar(&uri, "uri", "", "Token metadata Uri, required unless resuming")
 
 	return cmd
 }
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+func createTreeCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.createTreeCMD")
+	defer span.Done()
+
+	var (
+		ownerKeyFilename string
+		maxDepth         uint32
+		maxBufferSize    uint32
+		canopyDepth      uint32
+		public           bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create_tree",
+		Short: "Create concurrent Merkle tree for compressed NFTs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			req := &solana.CreateMerkleTreeRequest{
+				OwnerKeyFilename: ownerKeyFilename,
+				MaxDepth:         maxDepth,
+				MaxBufferSize:    maxBufferSize,
+				CanopyDepth:      canopyDepth,
+				Public:           public,
+			}
+
+			estimate, err := m.EstimateMerkleTreeCost(ctx, req)
+			if err != nil {
+				return err
+			}
+
+			if err := writeResult(os.Stderr, outputFormat, estimate); err != nil {
+				return err
+			}
+
+			if ok, err := confirm(ctx, m, "Create tree for %d NFTs paying %v SOL rent, %s", estimate.Capacity, lamportsToSOL(estimate.TreeRent+estimate.TreeConfigRent), feeDescription(estimate.TransactionFee.TotalLamports, estimate.TransactionFee.PriorityFee)); !ok || err != nil {
+				return err
+			}
+
+			res, err := m.CreateMerkleTree(ctx, req)
+			if err != nil {
+				return printDryRun(err)
+			}
+
+			return printResult(res)
+		},
+	}
+
+	cmd.Flags().StringVar(&ownerKeyFilename, "owner-key-file", "", "Enter name for a file with owner account key details")
+	cmd.MarkFlagRequired("owner-key-file")
+
+	cmd.Flags().Uint32Var(&maxDepth, "max-depth", 14, "Tree max depth, the tree holds 2^depth NFTs")
+	cmd.Flags().Uint32Var(&maxBufferSize, "max-buffer-size", 64, "Tree max buffer size, the number of concurrent changes per slot")
+	cmd.Flags().Uint32Var(&canopyDepth, "canopy-depth", 0, "Number of upper tree levels cached on chain to shorten proofs")
+	cmd.Flags().BoolVar(&public, "public", false, "Allow anyone to mint into the tree")
+
+	return cmd
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
+)
+
+// Exit codes of the CLI, documented in the README.
+const (
+	ExitOK                = 0
+	ExitFailure           = 1
+	ExitInvalidInput      = 2
+	ExitInsufficientFunds = 3
+	ExitRPC               = 4
+	ExitTransactionFailed = 5
+	ExitTimeout           = 6
+	// ExitInterrupted follows the shell convention of 128 + SIGINT.
+	ExitInterrupted = 130
+)
+
+// ExitCode maps the error of a command to the exit code of the process.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	switch solana.KindOf(err) {
+	case solana.ErrorKindInvalidInput:
+		return ExitInvalidInput
+	case solana.ErrorKindInsufficientFunds:
+		return ExitInsufficientFunds
+	case solana.ErrorKindRPC:
+		return ExitRPC
+	case solana.ErrorKindTransactionFailed:
+		return ExitTransactionFailed
+	case solana.ErrorKindTimeout:
+		return ExitTimeout
+	case solana.ErrorKindInterrupted:
+		return ExitInterrupted
+	default:
+		return ExitFailure
+	}
+}
+
+// usageError reports flags or input the command cannot run with.
+func usageError(format string, args ...any) error {
+	return solana.WithKind(solana.ErrorKindInvalidInput, errors.Errorf(format, args...))
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+type exportResult struct {
+	OutputFilename string `json:"output_file"`
+	Rows           int    `json:"rows"`
+}
+
+func exportCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.exportCMD")
+	defer span.Done()
+
+	var (
+		ownerKeyFilenames []string
+		addresses         []string
+		outputFilename    string
+		stateFilename     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Append wallet activity to a ledger CSV",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if len(ownerKeyFilenames) == 0 && len(addresses) == 0 {
+				return usageError("at least one --owner-key-file or --address is required")
+			}
+			if stateFilename == "" {
+				stateFilename = outputFilename + ".state.json"
+			}
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			req := &solana.ExportRequest{
+				OwnerKeyFilenames: ownerKeyFilenames,
+				Addresses:         addresses,
+				StateFilename:     stateFilename,
+			}
+
+			var exported int
+			if err := m.ExportLedger(ctx, req, func(rows []*solana.LedgerRow) error {
+				f, err := os.OpenFile(outputFilename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				info, err := f.Stat()
+				if err != nil {
+					return err
+				}
+
+				if err := writeLedgerCSV(f, rows, info.Size() == 0); err != nil {
+					return err
+				}
+				exported = len(rows)
+
+				return f.Sync()
+			}); err != nil {
+				return err
+			}
+
+			return printResult(&exportResult{OutputFilename: outputFilename, Rows: exported})
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&ownerKeyFilenames, "owner-key-file", nil, "Key file of a wallet to export, can be repeated")
+	cmd.Flags().StringSliceVar(&addresses, "address", nil, "Address of a wallet to export, can be repeated")
+
+	cmd.Flags().StringVar(&outputFilename, "output-file", "", "Ledger CSV file, new rows are appended")
+	cmd.MarkFlagRequired("output-file")
+
+	cmd.Flags().StringVar(&stateFilename, "state-file", "", "Export state, defaults to <output-file>.state.json")
+
+	markAddressFlags(cmd, "address")
+
+	return cmd
+}
+
+func writeLedgerCSV(w io.Writer, rows []*solana.LedgerRow, header bool) error {
+	cw := csv.NewWriter(w)
+
+	if header {
+		if err := cw.Write([]string{"timestamp", "wallet", "wallet_label", "signature", "status", "counterparty", "counterparty_label", "asset", "symbol", "amount_in", "amount_out", "fee", "memo", "balance"}); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range rows {
+		if err := cw.Write([]string{
+			row.Time.Format(time.RFC3339),
+			row.Wallet,
+			row.WalletLabel,
+			row.Signature,
+			row.Status,
+			row.Counterparty,
+			row.CounterpartyLabel,
+			row.Asset,
+			row.Symbol,
+			row.AmountIn,
+			row.AmountOut,
+			row.Fee,
+			row.Memo,
+			row.Balance,
+		}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
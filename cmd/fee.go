@@ -0,0 +1,16 @@
+package cmd
+
+import "fmt"
+
+func lamportsToSOL(lamports uint64) float64 {
+	return float64(lamports) / 1000000000
+}
+
+// feeDescription renders transaction fees for confirmation prompts.
+func feeDescription(totalLamports, priorityFee uint64) string {
+	if priorityFee == 0 {
+		return fmt.Sprintf("fee %v SOL", lamportsToSOL(totalLamports))
+	}
+
+	return fmt.Sprintf("fee %v SOL incl. %v SOL priority fee", lamportsToSOL(totalLamports), lamportsToSOL(priorityFee))
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+func historyCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.historyCMD")
+	defer span.Done()
+
+	var (
+		ownerKeyFilename string
+		address          string
+		limit            int
+		before           string
+		from, to         string
+		mint             string
+		direction        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show decoded transaction history of an account",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if (ownerKeyFilename == "") == (address == "") {
+				return usageError("exactly one of --owner-key-file and --address is required")
+			}
+
+			req := &solana.HistoryRequest{
+				OwnerKeyFilename: ownerKeyFilename,
+				Address:          address,
+				Limit:            limit,
+				Before:           before,
+				Mint:             mint,
+				Direction:        direction,
+			}
+
+			var err error
+			if req.From, err = parseHistoryDate(from, false); err != nil {
+				return err
+			}
+			if req.To, err = parseHistoryDate(to, true); err != nil {
+				return err
+			}
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			history, err := m.History(ctx, req)
+			if err != nil {
+				return err
+			}
+
+			if err := printResult(&historyResult{history}); err != nil {
+				return err
+			}
+
+			// The rows of csv and table output leave the next page out.
+			if history.Next != "" && (outputFormat == outputCSV || outputFormat == outputTable) {
+				fmt.Fprintf(os.Stderr, "more: --before=%s\n", history.Next)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&ownerKeyFilename, "owner-key-file", "", "Path to the owner's private key file")
+	cmd.Flags().StringVar(&address, "address", "", "Account address to show instead of a key file")
+	cmd.Flags().IntVar(&limit, "limit", 20, "Maximum number of transactions, 0 for all")
+	cmd.Flags().StringVar(&before, "before", "", "Continue before this signature, the \"next\" of a previous run")
+	cmd.Flags().StringVar(&from, "from", "", "Earliest date, YYYY-MM-DD or RFC 3339")
+	cmd.Flags().StringVar(&to, "to", "", "Latest date, YYYY-MM-DD (inclusive) or RFC 3339")
+	cmd.Flags().StringVar(&mint, "mint", "", "Only transactions moving this token mint, or SOL")
+	cmd.Flags().StringVar(&direction, "direction", "", "Only incoming (in) or outgoing (out) transactions")
+
+	markAddressFlags(cmd, "address", "mint")
+
+	return cmd
+}
+
+// parseHistoryDate accepts a date or a timestamp. A date used as the upper
+// bound covers the whole day.
+func parseHistoryDate(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, usageError("invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+
+	return t, nil
+}
+
+// historyResult has a row per transaction in csv and table output.
+type historyResult struct {
+	*solana.HistoryResponse
+}
+
+func (r *historyResult) header() []string {
+	return []string{"time", "signature", "status", "fee", "actions"}
+}
+
+func (r *historyResult) rows() [][]string {
+	res := make([][]string, 0, len(r.Entries))
+	for _, entry := range r.Entries {
+		var descriptions []string
+		for _, action := range entry.Actions {
+			descriptions = append(descriptions, action.Description)
+		}
+
+		status := entry.Status
+		if entry.Error != "" {
+			status += " (" + entry.Error + ")"
+		}
+
+		fee := ""
+		if entry.FeePayer {
+			fee = fmt.Sprintf("%v SOL", lamportsToSOL(entry.Fee))
+		}
+
+		res = append(res, []string{entry.Time.Format(time.DateTime), entry.Signature, status, fee, strings.Join(descriptions, "; ")})
+	}
+
+	return res
+}
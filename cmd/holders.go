@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+func holdersCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.holdersCMD")
+	defer span.Done()
+
+	var (
+		mintAddress     string
+		topN            int
+		excluded        []string
+		excludeFilename string
+		outputFilename  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "holders",
+		Short: "Report token holder distribution",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if excludeFilename != "" {
+				data, err := os.ReadFile(excludeFilename)
+				if err != nil {
+					return solana.WithKind(solana.ErrorKindInvalidInput, errors.Wrap(err, "failed to read exclude file"))
+				}
+				for _, line := range strings.Split(string(data), "\n") {
+					if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+						excluded = append(excluded, line)
+					}
+				}
+			}
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			report, err := m.TokenHolders(ctx, &solana.TokenHoldersRequest{
+				MintAddress:       mintAddress,
+				TopN:              topN,
+				ExcludedAddresses: excluded,
+			})
+			if err != nil {
+				return err
+			}
+
+			var w io.Writer = os.Stdout
+			if outputFilename != "" {
+				f, err := os.Create(outputFilename)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+
+			return writeResult(w, outputFormat, &holdersResult{report})
+		},
+	}
+
+	cmd.Flags().StringVar(&mintAddress, "mint", "", "Token mint address")
+	cmd.MarkFlagRequired("mint")
+
+	cmd.Flags().IntVar(&topN, "top", 10, "Number of largest holders used for the concentration figure")
+	cmd.Flags().StringSliceVar(&excluded, "exclude", nil, "Treasury owner or token account addresses left out of the circulating supply")
+	cmd.Flags().StringVar(&excludeFilename, "exclude-file", "", "File with one excluded address per line")
+	cmd.Flags().StringVar(&outputFilename, "output-file", "", "Write the report to a file in the --output format instead of stdout")
+
+	markAddressFlags(cmd, "mint", "exclude")
+
+	return cmd
+}
+
+// holdersResult lists the holders by rank in csv and table output.
+type holdersResult struct {
+	*solana.TokenHoldersReport
+}
+
+func (r *holdersResult) header() []string {
+	return []string{"rank", "owner", "amount", "ui_amount", "supply_percent", "circulating_percent", "excluded", "token_accounts"}
+}
+
+func (r *holdersResult) rows() [][]string {
+	res := make([][]string, 0, len(r.Holders))
+	for i, holder := range r.Holders {
+		res = append(res, []string{
+			strconv.Itoa(i + 1),
+			holder.Owner,
+			strconv.FormatUint(holder.Amount, 10),
+			holder.UiAmount,
+			strconv.FormatFloat(holder.SupplyPercent, 'f', 4, 64),
+			strconv.FormatFloat(holder.CirculatingPercent, 'f', 4, 64),
+			strconv.FormatBool(holder.Excluded),
+			strings.Join(holder.TokenAccounts, " "),
+		})
+	}
+
+	return res
+}
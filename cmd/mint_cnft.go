@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+func mintCNFTCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.mintCNFTCMD")
+	defer span.Done()
+
+	var (
+		ownerKeyFilename     string
+		treeAddress          string
+		toAddress            string
+		collectionMint       string
+		name                 string
+		symbol               string
+		uri                  string
+		sellerFeeBasisPoints uint16
+	)
+
+	cmd := &cobra.Command{
+		Use:   "mint_cnft",
+		Short: "Mint compressed NFT into Merkle tree",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			res, err := m.MintCompressedNFT(ctx, &solana.MintCompressedNFTRequest{
+				OwnerKeyFilename:     ownerKeyFilename,
+				TreeAddress:          treeAddress,
+				RecipientAddress:     toAddress,
+				CollectionMint:       collectionMint,
+				Name:                 name,
+				Symbol:               symbol,
+				Uri:                  uri,
+				SellerFeeBasisPoints: sellerFeeBasisPoints,
+			})
+			if err != nil {
+				return printDryRun(err)
+			}
+
+			return printResult(res)
+		},
+	}
+
+	cmd.Flags().StringVar(&ownerKeyFilename, "owner-key-file", "", "Enter name for a file with owner account key details")
+	cmd.MarkFlagRequired("owner-key-file")
+
+	cmd.Flags().StringVar(&treeAddress, "tree", "", "Merkle tree address")
+	cmd.MarkFlagRequired("tree")
+
+	cmd.Flags().StringVar(&toAddress, "to-address", "", "Recipient address, defaults to the owner")
+	cmd.Flags().StringVar(&collectionMint, "collection-mint", "", "Collection NFT mint to verify the NFT into")
+
+	cmd.Flags().StringVar(&name, "name", "", "NFT metadata name")
+	cmd.MarkFlagRequired("name")
+
+	cmd.Flags().StringVar(&symbol, "symbol", "", "NFT metadata symbol")
+	cmd.MarkFlagRequired("symbol")
+
+	cmd.Flags().StringVar(&uri, "uri", "", "NFT metadata Uri")
+	cmd.MarkFlagRequired("uri")
+
+	cmd.Flags().Uint16Var(&sellerFeeBasisPoints, "seller-fee-basis-points", 0, "Royalty in basis points")
+
+	markAddressFlags(cmd, "tree", "to-address", "collection-mint")
+
+	return cmd
+}
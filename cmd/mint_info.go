@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+func mintInfoCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.mintInfoCMD")
+	defer span.Done()
+
+	var mintAddress string
+
+	cmd := &cobra.Command{
+		Use:   "mint_info",
+		Short: "Check Solana token mint info",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			info, err := m.MintInfo(ctx, mintAddress)
+			if err != nil {
+				return err
+			}
+
+			return printResult(info)
+		},
+	}
+
+	cmd.Flags().StringVar(&mintAddress, "mint", "", "Token mint address")
+	cmd.MarkFlagRequired("mint")
+
+	markAddressFlags(cmd, "mint")
+
+	return cmd
+}
@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputCSV   = "csv"
+	outputTable = "table"
+)
+
+// outputFormat is set by the global --output flag. Results go to stdout in
+// this format, logs and prompts go to stderr.
+var outputFormat = outputJSON
+
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputJSON, outputYAML, outputCSV, outputTable:
+		return nil
+	default:
+		return usageError("unsupported output format %q, expected json, yaml, csv or table", format)
+	}
+}
+
+// tabular is implemented by results with their own csv and table columns.
+// Other results are flattened field by field.
+type tabular interface {
+	header() []string
+	rows() [][]string
+}
+
+// printResult renders the result of a command to stdout.
+func printResult(v any) error {
+	return writeResult(os.Stdout, outputFormat, v)
+}
+
+func writeResult(w io.Writer, format string, v any) error {
+	switch format {
+	case outputJSON:
+		data, err := json.MarshalIndent(v, "", "    ")
+		if err != nil {
+			return errors.Wrap(err, "marshal result")
+		}
+		_, err = fmt.Fprintln(w, string(data))
+
+		return err
+	case outputYAML:
+		data, err := marshalYAML(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+
+		return err
+	default:
+		header, rows, err := resultRows(v)
+		if err != nil {
+			return err
+		}
+
+		return writeRows(w, format, header, rows, true)
+	}
+}
+
+// resultStream renders a result per event, for commands running until
+// interrupted: one JSON document per line, YAML documents separated by
+// "---", and csv or table rows under a single header.
+type resultStream struct {
+	w           io.Writer
+	format      string
+	wroteHeader bool
+}
+
+func newResultStream(w io.Writer) *resultStream {
+	return &resultStream{w: w, format: outputFormat}
+}
+
+func (s *resultStream) write(v any) error {
+	switch s.format {
+	case outputJSON:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return errors.Wrap(err, "marshal result")
+		}
+		_, err = fmt.Fprintln(s.w, string(data))
+
+		return err
+	case outputYAML:
+		data, err := marshalYAML(v)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(s.w, "---\n%s", data)
+
+		return err
+	default:
+		header, rows, err := resultRows(v)
+		if err != nil {
+			return err
+		}
+		err = writeRows(s.w, s.format, header, rows, !s.wroteHeader)
+		s.wroteHeader = true
+
+		return err
+	}
+}
+
+func writeRows(w io.Writer, format string, header []string, rows [][]string, withHeader bool) error {
+	if format == outputCSV {
+		cw := csv.NewWriter(w)
+		if withHeader {
+			if err := cw.Write(header); err != nil {
+				return err
+			}
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if withHeader {
+		upper := make([]string, len(header))
+		for i, column := range header {
+			upper[i] = strings.ToUpper(column)
+		}
+		fmt.Fprintln(tw, strings.Join(upper, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// marshalYAML goes through JSON so the json tags of the results name the
+// YAML keys as well.
+func marshalYAML(v any) ([]byte, error) {
+	node, err := resultNode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal yaml result")
+	}
+
+	return data, nil
+}
+
+// resultNode parses the JSON of v into a YAML node, which keeps the field
+// order of the structs. JSON styles are cleared to render block YAML.
+func resultNode(v any) (*yaml.Node, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal result")
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "parse result")
+	}
+	clearStyle(&doc)
+
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+
+	return doc.Content[0], nil
+}
+
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
+
+// resultRows lays out a result without its own columns: a list has a row per
+// element, anything else is a single row. Nested fields get dotted names.
+func resultRows(v any) ([]string, [][]string, error) {
+	if t, ok := v.(tabular); ok {
+		return t.header(), t.rows(), nil
+	}
+
+	node, err := resultNode(v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		items = node.Content
+	}
+
+	var (
+		header  []string
+		columns = map[string]int{}
+		records []map[string]string
+	)
+	for _, item := range items {
+		record := map[string]string{}
+		flattenNode("", item, func(name, value string) {
+			if _, ok := columns[name]; !ok {
+				columns[name] = len(header)
+				header = append(header, name)
+			}
+			record[name] = value
+		})
+		records = append(records, record)
+	}
+
+	rows := make([][]string, len(records))
+	for i, record := range records {
+		rows[i] = make([]string, len(header))
+		for name, value := range record {
+			rows[i][columns[name]] = value
+		}
+	}
+
+	return header, rows, nil
+}
+
+func flattenNode(prefix string, node *yaml.Node, add func(name, value string)) {
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+
+		return prefix + "." + name
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			flattenNode(join(node.Content[i].Value), node.Content[i+1], add)
+		}
+	case yaml.SequenceNode:
+		if scalars(node.Content) {
+			values := make([]string, len(node.Content))
+			for i, item := range node.Content {
+				values[i] = scalarValue(item)
+			}
+			add(prefix, strings.Join(values, " "))
+
+			return
+		}
+		for i, item := range node.Content {
+			flattenNode(join(strconv.Itoa(i)), item, add)
+		}
+	default:
+		name := prefix
+		if name == "" {
+			name = "value"
+		}
+		add(name, scalarValue(node))
+	}
+}
+
+func scalars(nodes []*yaml.Node) bool {
+	for _, node := range nodes {
+		if node.Kind != yaml.ScalarNode {
+			return false
+		}
+	}
+
+	return true
+}
+
+func scalarValue(node *yaml.Node) string {
+	if node.Tag == "!!null" {
+		return ""
+	}
+
+	return node.Value
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
+)
+
+// assumeYes is set by the global --yes flag and answers confirmation prompts.
+var assumeYes bool
+
+// interactive reports whether stdin is a terminal someone can answer on.
+func interactive() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// scanAnswer reads a line from stdin. An interrupt stops waiting for it, as
+// signals are handled by the command context rather than killing the process.
+func scanAnswer(ctx context.Context) (string, error) {
+	answer := make(chan string, 1)
+	go func() {
+		var line string
+		fmt.Scanln(&line)
+		answer <- line
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", fmt.Errorf("interrupted before sending a transaction: %w", ctx.Err())
+	case line := <-answer:
+		return line, nil
+	}
+}
+
+// confirm asks the user to type "yes" before sending. Dry runs send nothing
+// and skip the question, --yes answers it. Without a terminal to ask on the
+// command fails instead of waiting for input.
+func confirm(ctx context.Context, m *solana.Module, format string, args ...any) (bool, error) {
+	if m.DryRun() {
+		return true, nil
+	}
+
+	if assumeYes {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+		return true, nil
+	}
+
+	if !interactive() {
+		return false, usageError("stdin is not a terminal, pass --yes to confirm: %s", fmt.Sprintf(format, args...))
+	}
+
+	fmt.Fprintf(os.Stderr, format+" ARE YOU SURE? (type \"yes\")\n", args...)
+	check, err := scanAnswer(ctx)
+	if err != nil {
+		return false, err
+	}
+	if check != "yes" {
+		fmt.Fprintln(os.Stderr, "Exiting...")
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// printDryRun prints the simulation report when err ends a dry run, and
+// returns any other error.
+func printDryRun(err error) error {
+	var dryRun *solana.DryRunError
+	if !errors.As(err, &dryRun) {
+		return err
+	}
+
+	return printResult(dryRun.Report)
+}
+
+// printRecipientWarnings shows what looks wrong about the recipient before
+// the confirmation prompt.
+func printRecipientWarnings(recipient *solana.Recipient) {
+	for _, warning := range recipient.Warnings {
+		fmt.Fprintf(os.Stderr, "WARNING: %s %s\n", recipient.Address, warning)
+	}
+}
+
+// confirmLookAlikes makes the user retype every recipient that looks like a
+// known counterparty, on top of the usual confirmation. --yes does not
+// answer this, so scripts have to send to the exact known address.
+func confirmLookAlikes(ctx context.Context, m *solana.Module, lookAlikes []*solana.LookAlike) (bool, error) {
+	if len(lookAlikes) == 0 || m.DryRun() {
+		return true, nil
+	}
+
+	flagged := map[string]bool{}
+	for _, lookAlike := range lookAlikes {
+		fmt.Fprintf(os.Stderr, "WARNING: %s looks like %s from your %s but is a different address, this may be address poisoning\n", lookAlike.Address, lookAlike.KnownAddress, lookAlike.Source)
+		flagged[lookAlike.Address] = true
+	}
+
+	if !interactive() {
+		return false, usageError("%d recipients look like known addresses and need to be retyped on a terminal", len(flagged))
+	}
+
+	for address := range flagged {
+		fmt.Fprintf(os.Stderr, "Type the full recipient address %s...%s to send anyway:\n", address[:4], address[len(address)-4:])
+		check, err := scanAnswer(ctx)
+		if err != nil {
+			return false, err
+		}
+		if check != address {
+			fmt.Fprintln(os.Stderr, "Exiting...")
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
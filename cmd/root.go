@@ -2,9 +2,11 @@ package cmd
 
 import (
 	"context"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
 	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
 )
 
@@ -12,16 +14,90 @@ func New(ctx context.Context) *cobra.Command {
 	span, _ := tracer.Start(ctx, "cmd.New")
 	defer span.Done()
 
+	var (
+		computeBudget solana.ComputeBudget
+		dryRun        bool
+		timeout       time.Duration
+		cancelTimeout context.CancelFunc = func() {}
+	)
+
 	cmd := &cobra.Command{
 		Short: "Solana token management CLI",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Cobra checks these after the pre-run as plain errors, checked
+			// here to exit with the invalid input code.
+			if err := cmd.ValidateRequiredFlags(); err != nil {
+				return solana.WithKind(solana.ErrorKindInvalidInput, err)
+			}
+			if err := cmd.ValidateFlagGroups(); err != nil {
+				return solana.WithKind(solana.ErrorKindInvalidInput, err)
+			}
+			if err := validateOutputFormat(outputFormat); err != nil {
+				return err
+			}
+			if timeout < 0 {
+				return usageError("--timeout must not be negative")
+			}
+			// Flags are fine, errors from here on are not about usage.
+			cmd.SilenceUsage = true
+
+			ctx := cmd.Context()
+			if timeout > 0 {
+				ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+				cmd.SetContext(ctx)
+			}
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			if err := resolveAddressFlags(ctx, m, cmd); err != nil {
+				return err
+			}
+
+			if computeBudget == (solana.ComputeBudget{}) && !dryRun {
+				return nil
+			}
+			m.SetDryRun(dryRun)
+
+			return m.SetComputeBudget(&computeBudget)
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			cancelTimeout()
+		},
 	}
+
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputJSON, "Result format: json, yaml, csv or table. Logs and prompts go to stderr")
+	cmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Answer confirmation prompts with yes, required when stdin is not a terminal")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Give up on the command after this long, e.g. 30s or 5m. Zero waits as long as the command needs")
+	cmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Simulate transactions and report logs, compute units and balance changes instead of sending them")
+	cmd.PersistentFlags().StringVar(&computeBudget.UnitLimit, "compute-unit-limit", "", "Compute unit limit of every transaction, or \"simulate\" to size it from a simulation")
+	cmd.PersistentFlags().StringVar(&computeBudget.UnitPrice, "compute-unit-price", "", "Priority fee in micro-lamports per compute unit, or \"auto\" to use recent prioritization fees")
+	cmd.PersistentFlags().IntVar(&computeBudget.PriorityFeePercentile, "priority-fee-percentile", 0, "Percentile of recent prioritization fees used by --compute-unit-price=auto (default 75)")
+
 	cmd.AddCommand(
 		createAccountCMD(ctx),
 		createTokenCMD(ctx),
 		accountInfoCMD(ctx),
+		mintInfoCMD(ctx),
+		holdersCMD(ctx),
+		watchCMD(ctx),
+		historyCMD(ctx),
+		exportCMD(ctx),
 		transferSOLCMD(ctx),
 		transferSPLCMD(ctx),
+		batchTransferCMD(ctx),
+		createTreeCMD(ctx),
+		mintCNFTCMD(ctx),
+		transferCNFTCMD(ctx),
+		stakeCMD(ctx),
+		contactsCMD(ctx),
 	)
 
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return solana.WithKind(solana.ErrorKindInvalidInput, err)
+	})
+
 	return cmd
 }
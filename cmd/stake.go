@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+func stakeCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.stakeCMD")
+	defer span.Done()
+
+	cmd := &cobra.Command{
+		Use:   "stake",
+		Short: "Manage stake accounts controlled by the owner key",
+	}
+
+	cmd.AddCommand(
+		stakeOperationCMD(ctx, solana.StakeCreate, "Create a stake account, delegated right away when --vote-address is set"),
+		stakeOperationCMD(ctx, solana.StakeDelegate, "Delegate a stake account to a validator vote account"),
+		stakeOperationCMD(ctx, solana.StakeDeactivate, "Deactivate a delegated stake account"),
+		stakeOperationCMD(ctx, solana.StakeWithdraw, "Withdraw lamports from an inactive stake account"),
+		stakeOperationCMD(ctx, solana.StakeSplit, "Split lamports of a stake account into a new one"),
+		stakeOperationCMD(ctx, solana.StakeMerge, "Merge a stake account into another"),
+	)
+
+	return cmd
+}
+
+func stakeOperationCMD(ctx context.Context, operation solana.StakeOperation, short string) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.stakeOperationCMD")
+	defer span.Done()
+
+	req := &solana.StakeRequest{Operation: operation}
+
+	cmd := &cobra.Command{
+		Use:   string(operation),
+		Short: short,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			estimate, err := m.EstimateStake(ctx, req)
+			if err != nil {
+				return err
+			}
+
+			if err := writeResult(os.Stderr, outputFormat, estimate); err != nil {
+				return err
+			}
+
+			if ok, err := confirm(ctx, m, "%s stake account %s moving %v SOL, %s", operation, estimate.StakeAddress, lamportsToSOL(estimate.AmountLamports), feeDescription(estimate.TotalLamports, estimate.PriorityFee)); !ok || err != nil {
+				return err
+			}
+
+			res, err := m.Stake(ctx, req)
+			if err != nil {
+				return printDryRun(err)
+			}
+
+			return printResult(res)
+		},
+	}
+
+	cmd.Flags().StringVar(&req.OwnerKeyFilename, "owner-key-file", "", "Enter name for a file with owner account key details, the stake and withdraw authority")
+	cmd.MarkFlagRequired("owner-key-file")
+
+	switch operation {
+	case solana.StakeCreate:
+		cmd.Flags().Uint64Var(&req.AmountLamports, "amount-lamports", 0, "Lamports to fund the stake account with, rent reserve included")
+		cmd.MarkFlagRequired("amount-lamports")
+		cmd.Flags().StringVar(&req.Seed, "seed", "", "Derive the stake account from the owner key with this seed instead of a new key")
+		cmd.Flags().StringVar(&req.VoteAddress, "vote-address", "", "Validator vote account to delegate to")
+		markAddressFlags(cmd, "vote-address")
+
+	case solana.StakeDelegate:
+		cmd.Flags().StringVar(&req.StakeAddress, "stake-address", "", "Stake account address")
+		cmd.MarkFlagRequired("stake-address")
+		cmd.Flags().StringVar(&req.VoteAddress, "vote-address", "", "Validator vote account to delegate to")
+		cmd.MarkFlagRequired("vote-address")
+		markAddressFlags(cmd, "stake-address", "vote-address")
+
+	case solana.StakeDeactivate:
+		cmd.Flags().StringVar(&req.StakeAddress, "stake-address", "", "Stake account address")
+		cmd.MarkFlagRequired("stake-address")
+		markAddressFlags(cmd, "stake-address")
+
+	case solana.StakeWithdraw:
+		cmd.Flags().StringVar(&req.StakeAddress, "stake-address", "", "Stake account address")
+		cmd.MarkFlagRequired("stake-address")
+		cmd.Flags().Uint64Var(&req.AmountLamports, "amount-lamports", 0, "Lamports to withdraw")
+		cmd.Flags().BoolVar(&req.Max, "max", false, "Withdraw the whole balance and close the stake account")
+		cmd.MarkFlagsOneRequired("amount-lamports", "max")
+		cmd.MarkFlagsMutuallyExclusive("amount-lamports", "max")
+		cmd.Flags().StringVar(&req.ToAddress, "to-address", "", "Recipient of the withdrawal, the owner by default")
+		markAddressFlags(cmd, "stake-address", "to-address")
+
+	case solana.StakeSplit:
+		cmd.Flags().StringVar(&req.StakeAddress, "stake-address", "", "Stake account address")
+		cmd.MarkFlagRequired("stake-address")
+		cmd.Flags().Uint64Var(&req.AmountLamports, "amount-lamports", 0, "Lamports moved to the new stake account")
+		cmd.MarkFlagRequired("amount-lamports")
+		cmd.Flags().StringVar(&req.Seed, "seed", "", "Derive the new stake account from the owner key with this seed instead of a new key")
+		markAddressFlags(cmd, "stake-address")
+
+	case solana.StakeMerge:
+		cmd.Flags().StringVar(&req.StakeAddress, "stake-address", "", "Stake account merged into")
+		cmd.MarkFlagRequired("stake-address")
+		cmd.Flags().StringVar(&req.SourceAddress, "source-address", "", "Stake account merged and closed")
+		cmd.MarkFlagRequired("source-address")
+		markAddressFlags(cmd, "stake-address", "source-address")
+	}
+
+	return cmd
+}
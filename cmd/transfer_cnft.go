@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+func transferCNFTCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.transferCNFTCMD")
+	defer span.Done()
+
+	var (
+		ownerKeyFilename string
+		assetID          string
+		toAddress        string
+		memo             string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "transfer_cnft",
+		Short: "Transfer compressed NFT to account",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			req := &solana.TransferCompressedNFTRequest{
+				OwnerKeyFilename: ownerKeyFilename,
+				AssetID:          assetID,
+				TargetAddress:    toAddress,
+				Memo:             memo,
+			}
+
+			recipient, err := m.ResolveRecipient(ctx, toAddress, "")
+			if err != nil {
+				return err
+			}
+			printRecipientWarnings(recipient)
+
+			fee, err := m.EstimateTransferCompressedNFT(ctx, req)
+			if err != nil {
+				return err
+			}
+
+			lookAlikes, err := m.FindLookAlikes(ctx, ownerKeyFilename, toAddress)
+			if err != nil {
+				return err
+			}
+			if ok, err := confirmLookAlikes(ctx, m, lookAlikes); !ok || err != nil {
+				return err
+			}
+
+			if ok, err := confirm(ctx, m, "Transfer compressed NFT %s to %s, %s", assetID, toAddress, feeDescription(fee.TotalLamports, fee.PriorityFee)); !ok || err != nil {
+				return err
+			}
+
+			res, err := m.TransferCompressedNFT(ctx, req)
+			if err != nil {
+				return printDryRun(err)
+			}
+
+			return printResult(res)
+		},
+	}
+
+	cmd.Flags().StringVar(&ownerKeyFilename, "owner-key-file", "", "Enter name for a file with owner account key details")
+	cmd.MarkFlagRequired("owner-key-file")
+
+	cmd.Flags().StringVar(&assetID, "asset-id", "", "Compressed NFT asset id")
+	cmd.MarkFlagRequired("asset-id")
+
+	cmd.Flags().StringVar(&toAddress, "to-address", "", "Recipient address")
+	cmd.MarkFlagRequired("to-address")
+
+	cmd.Flags().StringVar(&memo, "memo", "", "Memo attached to the transfer")
+
+	markAddressFlags(cmd, "to-address")
+
+	return cmd
+}
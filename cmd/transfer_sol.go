@@ -2,8 +2,6 @@ package cmd
 
 import (
 	"context"
-	"fmt"
-	"log"
 
 	"github.com/spf13/cobra"
 
@@ -18,35 +16,64 @@ func transferSOLCMD(ctx context.Context) *cobra.Command {
 	var (
 		ownerKeyFilename string
 		amountLamports   uint64
+		sendMax          bool
+		keepRentReserve  bool
 		toAddress        string
+		memo             string
+		references       []string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "transfer_sol",
 		Short: "Transfer SOL to account",
-		Run: func(cmd *cobra.Command, args []string) {
-			ctx = context.Background()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
 
 			m, err := solana.New(ctx)
 			if err != nil {
-				log.Fatalln(err)
+				return err
 			}
 
-			fmt.Printf("Transfer %v SOL to %s ARE YOU SURE? (type \"yes\")\n", float64(amountLamports)/1000000000, toAddress)
-			var check string
-			fmt.Scanln(&check)
-			if check != "yes" {
-				fmt.Println("Exiting...")
-				return
-			}
-
-			if err = m.TransferSOL(ctx, &solana.TransferSOLRequest{
+			req := &solana.TransferSOLRequest{
 				OwnerKeyFilename: ownerKeyFilename,
 				AmountLamports:   amountLamports,
+				Max:              sendMax,
+				KeepRentReserve:  keepRentReserve,
 				TargetAddress:    toAddress,
-			}); err != nil {
-				log.Fatalln(err)
+				Memo:             memo,
+				References:       references,
+			}
+
+			recipient, err := m.ResolveRecipient(ctx, toAddress, "")
+			if err != nil {
+				return err
+			}
+			printRecipientWarnings(recipient)
+
+			estimate, err := m.EstimateTransferSOL(ctx, req)
+			if err != nil {
+				return err
+			}
+			req.Estimate = estimate
+
+			lookAlikes, err := m.FindLookAlikes(ctx, ownerKeyFilename, toAddress)
+			if err != nil {
+				return err
+			}
+			if ok, err := confirmLookAlikes(ctx, m, lookAlikes); !ok || err != nil {
+				return err
+			}
+
+			if ok, err := confirm(ctx, m, "Transfer %v SOL to %s, %s", lamportsToSOL(estimate.AmountLamports), toAddress, feeDescription(estimate.TotalLamports, estimate.PriorityFee)); !ok || err != nil {
+				return err
+			}
+
+			res, err := m.TransferSOL(ctx, req)
+			if err != nil {
+				return printDryRun(err)
 			}
+
+			return printResult(res)
 		},
 	}
 
@@ -54,10 +81,18 @@ func transferSOLCMD(ctx context.Context) *cobra.Command {
 	cmd.MarkFlagRequired("owner-key-file")
 
 	cmd.Flags().Uint64Var(&amountLamports, "amount-lamports", 0, "Enter amount Lamports for the transfer")
-	cmd.MarkFlagRequired("amount-lamports")
+	cmd.Flags().BoolVar(&sendMax, "max", false, "Transfer the whole balance minus fees")
+	cmd.Flags().BoolVar(&keepRentReserve, "keep-rent-reserve", false, "With --max, keep the sender account rent-exempt")
+	cmd.MarkFlagsOneRequired("amount-lamports", "max")
+	cmd.MarkFlagsMutuallyExclusive("amount-lamports", "max")
 
 	cmd.Flags().StringVar(&toAddress, "to-address", "", "Recipient address for the transfer")
 	cmd.MarkFlagRequired("to-address")
 
+	cmd.Flags().StringVar(&memo, "memo", "", "Memo attached to the transfer")
+	cmd.Flags().StringSliceVar(&references, "reference", nil, "Read-only reference key to find the transfer by, can be repeated")
+
+	markAddressFlags(cmd, "to-address", "reference")
+
 	return cmd
 }
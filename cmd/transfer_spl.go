@@ -2,8 +2,6 @@ package cmd
 
 import (
 	"context"
-	"fmt"
-	"log"
 
 	"github.com/spf13/cobra"
 
@@ -20,35 +18,70 @@ func transferSPLCMD(ctx context.Context) *cobra.Command {
 		amountTokens     uint64
 		toAddress        string
 		tokenMint        string
+		memo             string
+		references       []string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "transfer_spl",
 		Short: "Transfer SOL token to account",
-		Run: func(cmd *cobra.Command, args []string) {
-			ctx = context.Background()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
 
 			m, err := solana.New(ctx)
 			if err != nil {
-				log.Fatalln(err)
+				return err
 			}
 
-			fmt.Printf("Transfer %v SPL to %s ARE YOU SURE? (type \"yes\")\n", amountTokens, toAddress)
-			var check string
-			fmt.Scanln(&check)
-			if check != "yes" {
-				fmt.Println("Exiting...")
-				return
+			if tokenMint == "" {
+				contact, err := m.ContactByAddress(ctx, toAddress)
+				if err != nil {
+					return err
+				}
+				if contact == nil || contact.DefaultToken == "" {
+					return usageError("--token-mint is required unless the recipient contact has a default token")
+				}
+				tokenMint = contact.DefaultToken
 			}
 
-			if err = m.TransferSPLToken(ctx, &solana.TransferSPLTokenRequest{
+			req := &solana.TransferSPLTokenRequest{
 				OwnerKeyFilename: ownerKeyFilename,
 				TargetAddress:    toAddress,
 				Amount:           amountTokens,
 				TokenMint:        tokenMint,
-			}); err != nil {
-				log.Fatalln(err)
+				Memo:             memo,
+				References:       references,
 			}
+
+			recipient, err := m.ResolveRecipient(ctx, toAddress, tokenMint)
+			if err != nil {
+				return err
+			}
+			printRecipientWarnings(recipient)
+
+			fee, err := m.EstimateTransferSPLToken(ctx, req)
+			if err != nil {
+				return err
+			}
+
+			lookAlikes, err := m.FindLookAlikes(ctx, ownerKeyFilename, toAddress)
+			if err != nil {
+				return err
+			}
+			if ok, err := confirmLookAlikes(ctx, m, lookAlikes); !ok || err != nil {
+				return err
+			}
+
+			if ok, err := confirm(ctx, m, "Transfer %v SPL to %s, %s", amountTokens, toAddress, feeDescription(fee.TotalLamports, fee.PriorityFee)); !ok || err != nil {
+				return err
+			}
+
+			res, err := m.TransferSPLToken(ctx, req)
+			if err != nil {
+				return printDryRun(err)
+			}
+
+			return printResult(res)
 		},
 	}
 
@@ -61,8 +94,12 @@ func transferSPLCMD(ctx context.Context) *cobra.Command {
 	cmd.Flags().StringVar(&toAddress, "to-address", "", "Recipient address")
 	cmd.MarkFlagRequired("to-address")
 
-	cmd.Flags().StringVar(&tokenMint, "token-mint", "", "Token mint")
-	cmd.MarkFlagRequired("to-address")
+	cmd.Flags().StringVar(&tokenMint, "token-mint", "", "Token mint, defaults to the recipient contact's default token")
+
+	cmd.Flags().StringVar(&memo, "memo", "", "Memo attached to the transfer")
+	cmd.Flags().StringSliceVar(&references, "reference", nil, "Read-only reference key to find the transfer by, can be repeated")
+
+	markAddressFlags(cmd, "to-address", "token-mint", "reference")
 
 	return cmd
 }
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+// watchResult keeps the same columns for every event in csv and table
+// output.
+type watchResult struct {
+	*solana.WatchEvent
+}
+
+func (r *watchResult) header() []string {
+	return []string{"time", "kind", "slot", "account", "mint", "balance", "change", "signature", "memos", "error"}
+}
+
+func (r *watchResult) rows() [][]string {
+	slot := ""
+	if r.Slot != 0 {
+		slot = strconv.FormatUint(r.Slot, 10)
+	}
+
+	return [][]string{{r.Time.Format(time.RFC3339), r.Kind, slot, r.Account, r.Mint, r.Balance, r.Change, r.Signature, strings.Join(r.Memos, "; "), r.Error}}
+}
+
+func watchCMD(ctx context.Context) *cobra.Command {
+	span, _ := tracer.Start(ctx, "cmd.watchCMD")
+	defer span.Done()
+
+	var (
+		ownerKeyFilename string
+		address          string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Stream SOL and token balance changes of an account",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if (ownerKeyFilename == "") == (address == "") {
+				return usageError("exactly one of --owner-key-file and --address is required")
+			}
+
+			m, err := solana.New(ctx)
+			if err != nil {
+				return err
+			}
+
+			// A failed write, such as a closed pipe, ends the watch.
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			var (
+				stream   = newResultStream(os.Stdout)
+				writeErr error
+			)
+			if err := m.Watch(ctx, &solana.WatchRequest{
+				OwnerKeyFilename: ownerKeyFilename,
+				Address:          address,
+			}, func(event *solana.WatchEvent) {
+				if writeErr != nil {
+					return
+				}
+				if writeErr = stream.write(&watchResult{event}); writeErr != nil {
+					cancel()
+				}
+			}); err != nil {
+				return err
+			}
+
+			return writeErr
+		},
+	}
+
+	cmd.Flags().StringVar(&ownerKeyFilename, "owner-key-file", "", "Path to the owner's private key file")
+	cmd.Flags().StringVar(&address, "address", "", "Account address to watch instead of a key file")
+
+	markAddressFlags(cmd, "address")
+
+	return cmd
+}
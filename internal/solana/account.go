@@ -3,7 +3,9 @@ package solana
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/blocto/solana-go-sdk/common"
 	"github.com/blocto/solana-go-sdk/program/metaplex/token_metadata"
@@ -47,6 +49,18 @@ func (m *Module) CreateAccount(ctx context.Context, outputKeyFilename string) (*
 		}
 	}
 
+	if err := writeKeyFile(outputKeyFilename, &account); err != nil {
+		return nil, err
+	}
+
+	m.log.Info(ctx, "created account output keyfile",
+		"output_key_filename", outputKeyFilename,
+	)
+
+	return &account, nil
+}
+
+func writeKeyFile(keyFilename string, account *types.Account) error {
 	keyContent := keyFileContent{
 		PublicKey:  account.PublicKey.ToBase58(),
 		PrivateKey: base58.Encode(account.PrivateKey),
@@ -54,18 +68,14 @@ func (m *Module) CreateAccount(ctx context.Context, outputKeyFilename string) (*
 
 	data, err := json.MarshalIndent(keyContent, "", "  ")
 	if err != nil {
-		return nil, errors.Wrap(err, "marshal keys")
+		return errors.Wrap(err, "marshal keys")
 	}
 
-	if err := os.WriteFile(outputKeyFilename, data, 0600); err != nil {
-		return nil, errors.Wrap(err, "write output key file")
+	if err := os.WriteFile(keyFilename, data, 0600); err != nil {
+		return errors.Wrap(err, "write output key file")
 	}
 
-	m.log.Info(ctx, "created account output keyfile",
-		"output_key_filename", outputKeyFilename,
-	)
-
-	return &account, nil
+	return nil
 }
 
 func loadFromKeyFile(ctx context.Context, keyFilename string) (*types.Account, error) {
@@ -93,7 +103,11 @@ func loadFromKeyFile(ctx context.Context, keyFilename string) (*types.Account, e
 type SolanaAccountOwnedToken struct {
 	PublicKey     string `json:"public_key"`
 	MintPublicKey string `json:"mint_public_key"`
-	Amount        uint64 `json:"amount"`
+	// MintLabel is the address book label of the mint.
+	MintLabel string `json:"mint_label,omitempty"`
+	Amount    uint64 `json:"amount"`
+	Decimals  uint8  `json:"decimals"`
+	UiAmount  string `json:"ui_amount"`
 
 	Name   string `json:"name"`
 	Symbol string `json:"symbol"`
@@ -101,73 +115,299 @@ type SolanaAccountOwnedToken struct {
 }
 type SolanaAccountInfoResponse struct {
 	PublicKey       string                     `json:"public_key"`
+	Label           string                     `json:"label,omitempty"`
+	Owner           string                     `json:"owner,omitempty"`
 	Balance         uint64                     `json:"balance"`
 	IsSystem        bool                       `json:"is_system"`
 	IsSmartContract bool                       `json:"is_smart_contract"`
 	RentEpoch       uint64                     `json:"rent_epoch"`
-	Data            string                     `json:"data"`
+	Data            *DecodedAccount            `json:"data,omitempty"`
 	OwnedTokens     []*SolanaAccountOwnedToken `json:"owned_tokens"`
+	Stakes          []*StakeAccount            `json:"stakes,omitempty"`
+	// Warnings name the parts of the report that could not be loaded.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
-func (m *Module) SolanaAccountInfo(ctx context.Context, keyFilename string) (*SolanaAccountInfoResponse, error) {
+type SolanaAccountInfoRequest struct {
+	// KeyFilenames are only read for their public keys.
+	KeyFilenames []string
+	Addresses    []string
+	// AddressesFilename lists one address or @label per line, blank lines
+	// and lines starting with # are skipped.
+	AddressesFilename string
+}
+
+type SolanaAccountTokenTotal struct {
+	MintPublicKey string `json:"mint_public_key"`
+	MintLabel     string `json:"mint_label,omitempty"`
+	Symbol        string `json:"symbol,omitempty"`
+	Amount        uint64 `json:"amount"`
+}
+
+type SolanaAccountInfoReport struct {
+	Accounts     []*SolanaAccountInfoResponse `json:"accounts"`
+	TotalBalance uint64                       `json:"total_balance"`
+	TotalTokens  []*SolanaAccountTokenTotal   `json:"total_tokens"`
+}
+
+// SolanaAccountInfo reports every requested account and the totals across
+// them. It only reads public data, no private key is needed.
+func (m *Module) SolanaAccountInfo(ctx context.Context, req *SolanaAccountInfoRequest) (*SolanaAccountInfoReport, error) {
 	_, span := tracer.Start(ctx, "pkg.payment.SolanaAccountInfo")
 	defer span.End()
 
-	ownerAccount, err := loadFromKeyFile(ctx, keyFilename)
+	addresses, err := m.accountInfoAddresses(ctx, req)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to load owner account")
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return nil, errorf(ErrorKindInvalidInput, "no accounts requested")
 	}
 
-	accountInfo, err := m.solanaClient.GetAccountInfo(ctx, ownerAccount.PublicKey.ToBase58())
+	labels, err := m.contactLabels()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get account info")
+		return nil, err
 	}
 
-	isSystem := accountInfo.Owner.String() == common.SystemProgramID.String()
+	res := &SolanaAccountInfoReport{Accounts: make([]*SolanaAccountInfoResponse, len(addresses))}
+	err = runConcurrently(len(addresses), m.config.RpcConcurrency, func(i int) error {
+		info, err := m.accountInfo(ctx, addresses[i], labels)
+		if err != nil {
+			return errors.Wrapf(err, "account %s", addresses[i])
+		}
+		res.Accounts[i] = info
 
-	tokenAccountList, err := m.solanaClient.GetTokenAccountsByOwnerByProgram(ctx, ownerAccount.PublicKey.ToBase58(), common.TokenProgramID.String())
+		return nil
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get token accounts by owner public key")
+		return nil, err
 	}
 
-	tokenList := make([]*SolanaAccountOwnedToken, len(tokenAccountList))
-	for i, tokenAccount := range tokenAccountList {
-		metadataKey, _, err := common.FindProgramAddress([][]byte{[]byte("metadata"), common.MetaplexTokenMetaProgramID.Bytes(), tokenAccount.Mint.Bytes()}, common.MetaplexTokenMetaProgramID)
+	totals := map[string]*SolanaAccountTokenTotal{}
+	for _, info := range res.Accounts {
+		res.TotalBalance += info.Balance
+		for _, token := range info.OwnedTokens {
+			total, ok := totals[token.MintPublicKey]
+			if !ok {
+				total = &SolanaAccountTokenTotal{
+					MintPublicKey: token.MintPublicKey,
+					MintLabel:     token.MintLabel,
+					Symbol:        token.Symbol,
+				}
+				totals[token.MintPublicKey] = total
+				res.TotalTokens = append(res.TotalTokens, total)
+			}
+			total.Amount += token.Amount
+		}
+	}
+
+	return res, nil
+}
+
+// accountInfoAddresses collects the requested addresses in order, without
+// duplicates.
+func (m *Module) accountInfoAddresses(ctx context.Context, req *SolanaAccountInfoRequest) ([]string, error) {
+	var (
+		res  []string
+		seen = map[string]bool{}
+	)
+	add := func(address string) error {
+		address, err := m.ResolveAddress(ctx, address)
 		if err != nil {
-			return nil, errors.Wrap(err, "calculate metadata key")
+			return err
+		}
+		if _, err := ParseAddress(address); err != nil {
+			return err
 		}
+		if !seen[address] {
+			seen[address] = true
+			res = append(res, address)
+		}
+
+		return nil
+	}
 
-		metadataAccount, err := m.solanaClient.GetAccountInfo(ctx, metadataKey.ToBase58())
+	for _, keyFilename := range req.KeyFilenames {
+		address, err := keyFilePublicKey(keyFilename)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to get account metadata")
+			return nil, err
 		}
+		if err := add(address); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, address := range req.Addresses {
+		if err := add(address); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.AddressesFilename != "" {
+		data, err := os.ReadFile(req.AddressesFilename)
+		if err != nil {
+			return nil, errors.Wrap(err, "read addresses file")
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if err := add(line); err != nil {
+				return nil, err
+			}
+		}
+	}
 
+	return res, nil
+}
+
+// keyFilePublicKey reads the public key of a key file without restoring the
+// private key.
+func keyFilePublicKey(keyFilename string) (string, error) {
+	data, err := os.ReadFile(keyFilename)
+	if err != nil {
+		return "", errors.Wrap(err, "read key file")
+	}
+
+	var keys keyFileContent
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return "", errors.Wrap(err, "unmarshal key file")
+	}
+	if keys.PublicKey == "" {
+		return "", errorf(ErrorKindInvalidInput, "key file %s has no public key", keyFilename)
+	}
+
+	return keys.PublicKey, nil
+}
+
+func (m *Module) accountInfo(ctx context.Context, address string, labels map[string]string) (*SolanaAccountInfoResponse, error) {
+	accountInfo, err := m.solanaClient.GetAccountInfo(ctx, address)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get account info")
+	}
+
+	isSystem := accountInfo.Owner.String() == common.SystemProgramID.String()
+
+	tokenAccountList, err := m.solanaClient.GetTokenAccountsByOwnerByProgram(ctx, address, common.TokenProgramID.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get token accounts by owner public key")
+	}
+
+	var (
+		mints     []string
+		seenMints = map[string]bool{}
+	)
+	for _, tokenAccount := range tokenAccountList {
+		if mint := tokenAccount.Mint.ToBase58(); !seenMints[mint] {
+			seenMints[mint] = true
+			mints = append(mints, mint)
+		}
+	}
+
+	details, err := m.tokenDetails(ctx, mints)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenList := make([]*SolanaAccountOwnedToken, len(tokenAccountList))
+	for i, tokenAccount := range tokenAccountList {
+		mint := tokenAccount.Mint.ToBase58()
 		tokenList[i] = &SolanaAccountOwnedToken{
 			PublicKey:     tokenAccount.PublicKey.ToBase58(),
-			MintPublicKey: tokenAccount.Mint.ToBase58(),
+			MintPublicKey: mint,
+			MintLabel:     labels[mint],
 			Amount:        tokenAccount.Amount,
 		}
 
-		if len(metadataAccount.Data) > 0 {
-			md, err := token_metadata.MetadataDeserialize(metadataAccount.Data)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to decode metadata")
+		if mintInfo := details[mint]; mintInfo != nil {
+			tokenList[i].Decimals = mintInfo.Decimals
+			tokenList[i].UiAmount = formatTokenAmount(tokenAccount.Amount, mintInfo.Decimals)
+			if mintInfo.Metadata != nil {
+				tokenList[i].Name = mintInfo.Metadata.Name
+				tokenList[i].Symbol = mintInfo.Metadata.Symbol
+				tokenList[i].Uri = mintInfo.Metadata.Uri
 			}
-			tokenList[i].Name = md.Data.Name
-			tokenList[i].Symbol = md.Data.Symbol
-			tokenList[i].Uri = md.Data.Uri
+		}
+	}
 
+	// Many RPC providers restrict getProgramAccounts on the stake program,
+	// which leaves out the stakes rather than the whole report.
+	var warnings []string
+	stakes, err := m.stakeAccounts(ctx, address, labels)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.Wrap(err, "failed to get stake accounts")
 		}
+		m.log.Error(ctx, "failed to get stake accounts", err, address)
+		warnings = append(warnings, fmt.Sprintf("stake accounts unavailable: %v", err))
+	}
+
+	var owner string
+	if accountInfo.Lamports > 0 {
+		owner = accountInfo.Owner.ToBase58()
 	}
 
 	res := &SolanaAccountInfoResponse{
-		PublicKey:       ownerAccount.PublicKey.ToBase58(),
+		Owner:           owner,
+		PublicKey:       address,
+		Label:           labels[address],
 		Balance:         accountInfo.Lamports,
 		IsSystem:        isSystem,
 		IsSmartContract: accountInfo.Executable,
 		RentEpoch:       accountInfo.RentEpoch,
-		Data:            string(accountInfo.Data),
+		Data:            decodeAccount(common.PublicKeyFromString(address), &accountInfo),
 		OwnedTokens:     tokenList,
+		Stakes:          stakes,
+		Warnings:        warnings,
+	}
+
+	return res, nil
+}
+
+// tokenDetails decodes the mints and their Metaplex metadata, fetched
+// together through getMultipleAccounts. The chunks are fetched one after
+// another, as accounts are already looked up RpcConcurrency at a time. A mint
+// that fails to decode is left out, its tokens are reported without decimals
+// and metadata.
+func (m *Module) tokenDetails(ctx context.Context, mints []string) (map[string]*MintInfoResponse, error) {
+	addresses := make([]string, 0, 2*len(mints))
+	addresses = append(addresses, mints...)
+	for _, mint := range mints {
+		metadataKey, err := token_metadata.GetTokenMetaPubkey(common.PublicKeyFromString(mint))
+		if err != nil {
+			return nil, errors.Wrap(err, "calculate metadata key")
+		}
+		addresses = append(addresses, metadataKey.ToBase58())
+	}
+
+	accounts, err := m.fetchMultipleAccounts(ctx, addresses, 1)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get mints and metadata")
+	}
+
+	res := make(map[string]*MintInfoResponse, len(mints))
+	for i, mint := range mints {
+		mintAccount, metadataAccount := accounts[i], accounts[len(mints)+i]
+		if mintAccount == nil {
+			continue
+		}
+
+		mintInfo, err := decodeMint(common.PublicKeyFromString(mint), mintAccount.Owner, mintAccount.Data)
+		if err != nil {
+			m.log.Error(ctx, "failed to decode mint", err, mint)
+			continue
+		}
+
+		if metadataAccount != nil && len(metadataAccount.Data) > 0 {
+			metadata, err := decodeMintMetadata(common.PublicKeyFromString(addresses[len(mints)+i]), metadataAccount.Data)
+			if err != nil {
+				m.log.Error(ctx, "failed to decode mint metadata", err, mint)
+				continue
+			}
+			mintInfo.Metadata = metadata
+		}
+		res[mint] = mintInfo
 	}
 
 	return res, nil
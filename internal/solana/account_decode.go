@@ -0,0 +1,305 @@
+package solana
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/address_lookup_table"
+	"github.com/blocto/solana-go-sdk/program/metaplex/token_metadata"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/pkg/errors"
+)
+
+// DecodedAccount is the data of an account, parsed by the decoder of its
+// owner program when there is one.
+type DecodedAccount struct {
+	Program string `json:"program"`
+	Type    string `json:"type"`
+	Parsed  any    `json:"parsed,omitempty"`
+	// Base64 is the raw data, set when it is not parsed.
+	Base64 string `json:"base64,omitempty"`
+	// Error is why the decoder of the owner program gave up.
+	Error string `json:"error,omitempty"`
+}
+
+type accountDecoder struct {
+	program string
+	// decode returns the account type and its parsed data.
+	decode func(address, owner common.PublicKey, data []byte) (string, any, error)
+}
+
+var accountDecoders = map[common.PublicKey]*accountDecoder{
+	common.SystemProgramID:             {program: "system", decode: decodeSystemAccount},
+	common.TokenProgramID:              {program: "token", decode: decodeTokenProgramAccount},
+	common.Token2022ProgramID:          {program: "token-2022", decode: decodeTokenProgramAccount},
+	common.MetaplexTokenMetaProgramID:  {program: "token-metadata", decode: decodeTokenMetadataAccount},
+	common.StakeProgramID:              {program: "stake", decode: decodeStakeProgramAccount},
+	common.AddressLookupTableProgramID: {program: "address-lookup-table", decode: decodeLookupTableAccount},
+}
+
+// decodeAccount parses the account with the decoder of its owner program and
+// falls back to base64 data. Missing accounts decode to nil.
+func decodeAccount(address common.PublicKey, account *client.AccountInfo) *DecodedAccount {
+	if account == nil || account.Lamports == 0 {
+		return nil
+	}
+
+	res := &DecodedAccount{Program: account.Owner.ToBase58(), Type: "unknown"}
+
+	decoder, ok := accountDecoders[account.Owner]
+	if !ok {
+		res.Base64 = base64.StdEncoding.EncodeToString(account.Data)
+		return res
+	}
+	res.Program = decoder.program
+
+	accountType, parsed, err := decoder.decode(address, account.Owner, account.Data)
+	if err != nil {
+		res.Base64 = base64.StdEncoding.EncodeToString(account.Data)
+		res.Error = err.Error()
+		return res
+	}
+	res.Type = accountType
+	res.Parsed = parsed
+
+	return res
+}
+
+type NonceAccountData struct {
+	Authority            string `json:"authority"`
+	Nonce                string `json:"nonce"`
+	LamportsPerSignature uint64 `json:"lamports_per_signature"`
+	Initialized          bool   `json:"initialized"`
+}
+
+func decodeSystemAccount(_, _ common.PublicKey, data []byte) (string, any, error) {
+	switch len(data) {
+	case 0:
+		return "wallet", nil, nil
+	case system.NonceAccountSize:
+		nonce, err := system.NonceAccountDeserialize(data)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return "nonce", &NonceAccountData{
+			Authority:            nonce.AuthorizedPubkey.ToBase58(),
+			Nonce:                nonce.Nonce.ToBase58(),
+			LamportsPerSignature: nonce.FeeCalculator.LamportsPerSignature,
+			Initialized:          nonce.State == 1,
+		}, nil
+	default:
+		return "", nil, errors.Errorf("unexpected system account size %d", len(data))
+	}
+}
+
+type TokenAccountData struct {
+	Mint            string `json:"mint"`
+	Owner           string `json:"owner"`
+	Amount          uint64 `json:"amount"`
+	State           string `json:"state"`
+	Delegate        string `json:"delegate,omitempty"`
+	DelegatedAmount uint64 `json:"delegated_amount,omitempty"`
+	// NativeRentReserve is set for wrapped SOL accounts.
+	NativeRentReserve *uint64           `json:"native_rent_reserve,omitempty"`
+	CloseAuthority    string            `json:"close_authority,omitempty"`
+	Extensions        []*TokenExtension `json:"extensions,omitempty"`
+}
+
+// decodeTokenProgramAccount tells mints from token accounts by size, and by
+// the account type byte for Token-2022 accounts with extensions.
+func decodeTokenProgramAccount(address, owner common.PublicKey, data []byte) (string, any, error) {
+	accountType := byte(0)
+	switch {
+	case len(data) == token.MintAccountSize:
+		accountType = token2022AccountTypeMint
+	case len(data) == token.TokenAccountSize:
+		accountType = token2022AccountTypeAccount
+	case owner == common.Token2022ProgramID && len(data) > token2022AccountTypeOffset:
+		accountType = data[token2022AccountTypeOffset]
+	}
+
+	switch accountType {
+	case token2022AccountTypeMint:
+		mint, err := decodeMint(address, owner, data)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return "mint", mint, nil
+	case token2022AccountTypeAccount:
+		account, err := decodeTokenAccount(owner, data)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return "account", account, nil
+	default:
+		return "", nil, errors.Errorf("unexpected token program account size %d", len(data))
+	}
+}
+
+func decodeTokenAccount(owner common.PublicKey, data []byte) (*TokenAccountData, error) {
+	account, err := token.TokenAccountFromData(data[:token.TokenAccountSize])
+	if err != nil {
+		return nil, errors.Wrap(err, "decode token account")
+	}
+
+	res := &TokenAccountData{
+		Mint:              account.Mint.ToBase58(),
+		Owner:             account.Owner.ToBase58(),
+		Amount:            account.Amount,
+		State:             tokenAccountStateName(byte(account.State)),
+		DelegatedAmount:   account.DelegatedAmount,
+		NativeRentReserve: account.IsNative,
+	}
+	if account.Delegate != nil {
+		res.Delegate = account.Delegate.ToBase58()
+	}
+	if account.CloseAuthority != nil {
+		res.CloseAuthority = account.CloseAuthority.ToBase58()
+	}
+
+	if owner == common.Token2022ProgramID {
+		res.Extensions, err = decodeToken2022Extensions(data, token2022AccountTypeAccount)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode token-2022 extensions")
+		}
+	}
+
+	return res, nil
+}
+
+type MasterEditionData struct {
+	Supply    uint64  `json:"supply"`
+	MaxSupply *uint64 `json:"max_supply,omitempty"`
+}
+
+type EditionData struct {
+	Parent  string `json:"parent"`
+	Edition uint64 `json:"edition"`
+}
+
+func decodeTokenMetadataAccount(address, _ common.PublicKey, data []byte) (string, any, error) {
+	if len(data) == 0 {
+		return "", nil, errors.New("empty token metadata account")
+	}
+
+	switch token_metadata.Key(data[0]) {
+	case token_metadata.KeyMetadataV1:
+		md, err := decodeMintMetadata(address, data)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return "metadata", md, nil
+	case token_metadata.KeyMasterEditionV1, token_metadata.KeyMasterEditionV2:
+		// Key, supply and an optional max supply lead both versions.
+		if len(data) < 10 {
+			return "", nil, errors.New("master edition data is too short")
+		}
+		res := &MasterEditionData{Supply: binary.LittleEndian.Uint64(data[1:9])}
+		if data[9] == 1 {
+			if len(data) < 18 {
+				return "", nil, errors.New("master edition data is too short")
+			}
+			maxSupply := binary.LittleEndian.Uint64(data[10:18])
+			res.MaxSupply = &maxSupply
+		}
+
+		return "master_edition", res, nil
+	case token_metadata.KeyEditionV1:
+		if len(data) < 41 {
+			return "", nil, errors.New("edition data is too short")
+		}
+
+		return "edition", &EditionData{
+			Parent:  common.PublicKeyFromBytes(data[1:33]).ToBase58(),
+			Edition: binary.LittleEndian.Uint64(data[33:41]),
+		}, nil
+	default:
+		return "", nil, errors.Errorf("unsupported token metadata account key %d", data[0])
+	}
+}
+
+type StakeAccountData struct {
+	State             string  `json:"state"`
+	RentExemptReserve uint64  `json:"rent_exempt_reserve,omitempty"`
+	Staker            string  `json:"staker,omitempty"`
+	Withdrawer        string  `json:"withdrawer,omitempty"`
+	Voter             string  `json:"voter,omitempty"`
+	DelegatedLamports uint64  `json:"delegated_lamports,omitempty"`
+	ActivationEpoch   *uint64 `json:"activation_epoch,omitempty"`
+	DeactivationEpoch *uint64 `json:"deactivation_epoch,omitempty"`
+}
+
+func decodeStakeProgramAccount(_, _ common.PublicKey, data []byte) (string, any, error) {
+	state, err := decodeStakeAccount(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	res := &StakeAccountData{}
+	switch state.kind {
+	case stakeKindUninitialized:
+		res.State = "uninitialized"
+		return "stake", res, nil
+	case stakeKindRewardsPool:
+		res.State = "rewards_pool"
+		return "stake", res, nil
+	case stakeKindInitialized:
+		res.State = StakeStateInitialized
+	case stakeKindDelegated:
+		res.State = "delegated"
+		res.Voter = state.voter.ToBase58()
+		res.DelegatedLamports = state.delegatedLamports
+		if state.activationEpoch != math.MaxUint64 {
+			res.ActivationEpoch = &state.activationEpoch
+		}
+		if state.deactivationEpoch != math.MaxUint64 {
+			res.DeactivationEpoch = &state.deactivationEpoch
+		}
+	default:
+		return "", nil, errors.Errorf("unsupported stake account state %d", state.kind)
+	}
+	res.RentExemptReserve = state.rentExemptReserve
+	res.Staker = state.staker.ToBase58()
+	res.Withdrawer = state.withdrawer.ToBase58()
+
+	return "stake", res, nil
+}
+
+type LookupTableData struct {
+	Authority        string `json:"authority,omitempty"`
+	LastExtendedSlot uint64 `json:"last_extended_slot"`
+	// DeactivationSlot is set once the table is deactivated.
+	DeactivationSlot *uint64  `json:"deactivation_slot,omitempty"`
+	Addresses        []string `json:"addresses"`
+}
+
+func decodeLookupTableAccount(_, owner common.PublicKey, data []byte) (string, any, error) {
+	table, err := address_lookup_table.DeserializeLookupTable(data, owner)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "decode address lookup table")
+	}
+
+	res := &LookupTableData{
+		LastExtendedSlot: table.LastExtendedSlot,
+		Addresses:        make([]string, 0, len(table.Addresses)),
+	}
+	if table.Authority != nil {
+		res.Authority = table.Authority.ToBase58()
+	}
+	if table.DeactivationSlot != math.MaxUint64 {
+		res.DeactivationSlot = &table.DeactivationSlot
+	}
+	for _, address := range table.Addresses {
+		res.Addresses = append(res.Addresses, address.ToBase58())
+	}
+
+	return "lookup_table", res, nil
+}
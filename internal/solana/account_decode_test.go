@@ -0,0 +1,85 @@
+package solana
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/address_lookup_table"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_decodeAccount(t *testing.T) {
+	address := types.NewAccount().PublicKey
+	mint := types.NewAccount().PublicKey
+	owner := types.NewAccount().PublicKey
+
+	require.Nil(t, decodeAccount(address, &client.AccountInfo{}))
+
+	wallet := decodeAccount(address, &client.AccountInfo{Lamports: 1, Owner: common.SystemProgramID})
+	require.Equal(t, "system", wallet.Program)
+	require.Equal(t, "wallet", wallet.Type)
+
+	nonceData := make([]byte, system.NonceAccountSize)
+	binary.LittleEndian.PutUint32(nonceData[4:], 1)
+	copy(nonceData[8:], owner.Bytes())
+	binary.LittleEndian.PutUint64(nonceData[72:], 5000)
+	nonce := decodeAccount(address, &client.AccountInfo{Lamports: 1, Owner: common.SystemProgramID, Data: nonceData})
+	require.Equal(t, "nonce", nonce.Type)
+	require.Equal(t, &NonceAccountData{
+		Authority:            owner.ToBase58(),
+		Nonce:                common.PublicKey{}.ToBase58(),
+		LamportsPerSignature: 5000,
+		Initialized:          true,
+	}, nonce.Parsed)
+
+	tokenData := make([]byte, token.TokenAccountSize)
+	copy(tokenData, mint.Bytes())
+	copy(tokenData[32:], owner.Bytes())
+	binary.LittleEndian.PutUint64(tokenData[64:], 1500)
+	tokenData[108] = byte(token.TokenAccountStateInitialized)
+	tokenAccount := decodeAccount(address, &client.AccountInfo{Lamports: 1, Owner: common.TokenProgramID, Data: tokenData})
+	require.Equal(t, "account", tokenAccount.Type)
+	require.Equal(t, &TokenAccountData{
+		Mint:   mint.ToBase58(),
+		Owner:  owner.ToBase58(),
+		Amount: 1500,
+		State:  "initialized",
+	}, tokenAccount.Parsed)
+
+	editionData := []byte{6, 3, 0, 0, 0, 0, 0, 0, 0, 1, 10, 0, 0, 0, 0, 0, 0, 0}
+	edition := decodeAccount(address, &client.AccountInfo{Lamports: 1, Owner: common.MetaplexTokenMetaProgramID, Data: editionData})
+	require.Equal(t, "master_edition", edition.Type)
+	maxSupply := uint64(10)
+	require.Equal(t, &MasterEditionData{Supply: 3, MaxSupply: &maxSupply}, edition.Parsed)
+
+	tableData := make([]byte, address_lookup_table.LOOKUP_TABLE_META_SIZE+32)
+	binary.LittleEndian.PutUint32(tableData, uint32(address_lookup_table.ProgramStateLookupTable))
+	binary.LittleEndian.PutUint64(tableData[4:], math.MaxUint64)
+	binary.LittleEndian.PutUint64(tableData[12:], 42)
+	tableData[21] = 1
+	copy(tableData[22:], owner.Bytes())
+	copy(tableData[address_lookup_table.LOOKUP_TABLE_META_SIZE:], mint.Bytes())
+	table := decodeAccount(address, &client.AccountInfo{Lamports: 1, Owner: common.AddressLookupTableProgramID, Data: tableData})
+	require.Equal(t, "lookup_table", table.Type)
+	require.Equal(t, &LookupTableData{
+		Authority:        owner.ToBase58(),
+		LastExtendedSlot: 42,
+		Addresses:        []string{mint.ToBase58()},
+	}, table.Parsed)
+
+	unknown := decodeAccount(address, &client.AccountInfo{Lamports: 1, Owner: owner, Data: []byte{1, 2, 3}})
+	require.Equal(t, owner.ToBase58(), unknown.Program)
+	require.Equal(t, "unknown", unknown.Type)
+	require.Equal(t, "AQID", unknown.Base64)
+
+	broken := decodeAccount(address, &client.AccountInfo{Lamports: 1, Owner: common.TokenProgramID, Data: []byte{1, 2, 3}})
+	require.Equal(t, "token", broken.Program)
+	require.Equal(t, "AQID", broken.Base64)
+	require.NotEmpty(t, broken.Error)
+}
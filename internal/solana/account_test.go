@@ -3,8 +3,10 @@ package solana
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/blocto/solana-go-sdk/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -26,3 +28,30 @@ func Test_loadFromKeyFile(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, account)
 }
+
+func TestModule_accountInfoAddresses(t *testing.T) {
+	dir := t.TempDir()
+	wallet := types.NewAccount()
+	other := types.NewAccount().PublicKey.ToBase58()
+	exchange := types.NewAccount().PublicKey.ToBase58()
+
+	keyFilename := filepath.Join(dir, "key.json")
+	require.NoError(t, writeKeyFile(keyFilename, &wallet))
+
+	addressesFilename := filepath.Join(dir, "addresses.txt")
+	require.NoError(t, os.WriteFile(addressesFilename, []byte("# watchlist\n"+other+"\n\n@exchange\n"+wallet.PublicKey.ToBase58()+"\n"), 0600))
+
+	m := &Module{config: &config{ContactsFilename: filepath.Join(dir, "contacts.json")}}
+	require.NoError(t, saveContacts(m.config.ContactsFilename, []*Contact{{Label: "exchange", Address: exchange}}))
+
+	addresses, err := m.accountInfoAddresses(context.Background(), &SolanaAccountInfoRequest{
+		KeyFilenames:      []string{keyFilename},
+		Addresses:         []string{other},
+		AddressesFilename: addressesFilename,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{wallet.PublicKey.ToBase58(), other, exchange}, addresses)
+
+	_, err = m.accountInfoAddresses(context.Background(), &SolanaAccountInfoRequest{Addresses: []string{"not an address"}})
+	require.Error(t, err)
+}
@@ -0,0 +1,26 @@
+package solana
+
+import (
+	"strconv"
+	"strings"
+)
+
+// formatTokenAmount renders a raw token amount with its decimals applied,
+// without going through floats.
+func formatTokenAmount(amount uint64, decimals uint8) string {
+	s := strconv.FormatUint(amount, 10)
+	if decimals == 0 {
+		return s
+	}
+
+	if len(s) <= int(decimals) {
+		s = strings.Repeat("0", int(decimals)-len(s)+1) + s
+	}
+
+	whole, fraction := s[:len(s)-int(decimals)], strings.TrimRight(s[len(s)-int(decimals):], "0")
+	if fraction == "" {
+		return whole
+	}
+
+	return whole + "." + fraction
+}
@@ -0,0 +1,15 @@
+package solana
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_formatTokenAmount(t *testing.T) {
+	require.Equal(t, "3000000", formatTokenAmount(3000000, 0))
+	require.Equal(t, "1.5", formatTokenAmount(1500000000, 9))
+	require.Equal(t, "0.000000001", formatTokenAmount(1, 9))
+	require.Equal(t, "0", formatTokenAmount(0, 6))
+	require.Equal(t, "12", formatTokenAmount(1200, 2))
+}
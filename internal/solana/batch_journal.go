@@ -0,0 +1,127 @@
+package solana
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	batchStatusSent      = "sent"
+	batchStatusConfirmed = "confirmed"
+	batchStatusFailed    = "failed"
+)
+
+type batchJournalEntry struct {
+	Key       string    `json:"key"`
+	Address   string    `json:"address"`
+	Mint      string    `json:"mint,omitempty"`
+	Amount    uint64    `json:"amount"`
+	Signature string    `json:"signature"`
+	Blockhash string    `json:"blockhash"`
+	Status    string    `json:"status"`
+	Time      time.Time `json:"time"`
+}
+
+// batchJournal is an append-only JSON lines file; the last entry of a key is
+// its current state.
+type batchJournal struct {
+	mu      sync.Mutex
+	f       *os.File
+	entries map[string]*batchJournalEntry
+}
+
+// loadBatchJournal reads the journal without opening it for writing, so
+// records are only kept in memory.
+func loadBatchJournal(filename string) (*batchJournal, error) {
+	j := &batchJournal{entries: map[string]*batchJournalEntry{}}
+
+	if f, err := os.Open(filename); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+
+			var entry batchJournalEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				f.Close()
+				return nil, errors.Wrap(err, "unmarshal journal entry")
+			}
+			j.entries[entry.Key] = &entry
+		}
+		f.Close()
+
+		if err := scanner.Err(); err != nil {
+			return nil, errors.Wrap(err, "read journal file")
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "open journal file")
+	}
+
+	return j, nil
+}
+
+func openBatchJournal(filename string) (*batchJournal, error) {
+	j, err := loadBatchJournal(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "open journal file for writing")
+	}
+	j.f = f
+
+	return j, nil
+}
+
+func (j *batchJournal) get(key string) *batchJournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.entries[key]
+}
+
+// record appends the entries and syncs the file, so a crash right after
+// sending still leaves the signature on disk.
+func (j *batchJournal) record(entries ...*batchJournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, entry := range entries {
+		entry.Time = time.Now().UTC()
+		j.entries[entry.Key] = entry
+
+		if j.f == nil {
+			continue
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return errors.Wrap(err, "marshal journal entry")
+		}
+		if _, err := j.f.Write(append(data, '\n')); err != nil {
+			return errors.Wrap(err, "write journal entry")
+		}
+	}
+
+	if j.f == nil {
+		return nil
+	}
+
+	return errors.Wrap(j.f.Sync(), "sync journal file")
+}
+
+func (j *batchJournal) Close() error {
+	if j.f == nil {
+		return nil
+	}
+
+	return j.f.Close()
+}
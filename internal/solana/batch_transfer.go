@@ -0,0 +1,606 @@
+package solana
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/associated_token_account"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+// getMultipleAccountsLimit is the maximum number of addresses per
+// getMultipleAccounts call.
+const getMultipleAccountsLimit = 100
+
+type BatchTransferRecipient struct {
+	Address string
+	Amount  uint64
+	Line    int
+}
+
+// ParseBatchTransferCSV reads "address,amount" rows. Amounts are lamports for
+// SOL and base units for SPL tokens. A header row is skipped. Addresses pass
+// through resolve when set, to turn @labels into addresses.
+func ParseBatchTransferCSV(r io.Reader, resolve func(address string) (string, error)) ([]*BatchTransferRecipient, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+
+	var (
+		res  []*BatchTransferRecipient
+		seen = map[string]int{}
+	)
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "read csv")
+		}
+		if len(record) < 2 {
+			return nil, errorf(ErrorKindInvalidInput, "line %d: expected address and amount", line)
+		}
+
+		address, amountStr := strings.TrimSpace(record[0]), strings.TrimSpace(record[1])
+		amount, err := strconv.ParseUint(amountStr, 10, 64)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, errors.Wrapf(err, "line %d: parse amount", line)
+		}
+		if amount == 0 {
+			return nil, errorf(ErrorKindInvalidInput, "line %d: amount must be positive", line)
+		}
+
+		if resolve != nil {
+			if address, err = resolve(address); err != nil {
+				return nil, errors.Wrapf(err, "line %d", line)
+			}
+		}
+		if decoded, err := base58.Decode(address); err != nil || len(decoded) != common.PublicKeyLength {
+			return nil, errorf(ErrorKindInvalidInput, "line %d: invalid address %q", line, address)
+		}
+		if prev, ok := seen[address]; ok {
+			return nil, errorf(ErrorKindInvalidInput, "line %d: duplicate recipient %s, first seen on line %d", line, address, prev)
+		}
+		seen[address] = line
+
+		res = append(res, &BatchTransferRecipient{
+			Address: address,
+			Amount:  amount,
+			Line:    line,
+		})
+	}
+
+	return res, nil
+}
+
+type BatchTransferRequest struct {
+	OwnerKeyFilename string
+	// TokenMint selects an SPL transfer, SOL is sent when empty.
+	TokenMint       string
+	Recipients      []*BatchTransferRecipient
+	JournalFilename string
+	Concurrency     int
+	// Memo is attached to every transaction of the batch when set.
+	Memo string
+}
+
+type BatchTransferFailure struct {
+	Signature  string   `json:"signature"`
+	Recipients []string `json:"recipients"`
+	Error      string   `json:"error"`
+}
+
+type BatchTransferResult struct {
+	Recipients   int                     `json:"recipients"`
+	AlreadyPaid  int                     `json:"already_paid"`
+	Paid         int                     `json:"paid"`
+	Transactions []string                `json:"transactions"`
+	Failed       []*BatchTransferFailure `json:"failed"`
+	// Simulations holds one report per transaction in dry-run mode.
+	Simulations []*SimulationReport `json:"simulations,omitempty"`
+}
+
+type batchTransferItem struct {
+	recipient    *BatchTransferRecipient
+	key          string
+	instructions []types.Instruction
+}
+
+type batchTransferBatch struct {
+	items        []*batchTransferItem
+	instructions []types.Instruction
+}
+
+// BatchTransfer pays every recipient, packing as many transfers into each
+// transaction as fit. Progress is kept in the journal so a rerun only pays
+// recipients without a confirmed transfer.
+func (m *Module) BatchTransfer(ctx context.Context, req *BatchTransferRequest) (*BatchTransferResult, error) {
+	_, span := tracer.Start(ctx, "internal.solana.BatchTransfer")
+	defer span.End()
+
+	fromAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load sender account")
+	}
+
+	openJournal := openBatchJournal
+	if m.dryRun {
+		openJournal = loadBatchJournal
+	}
+	journal, err := openJournal(req.JournalFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "open journal")
+	}
+	defer journal.Close()
+
+	res := &BatchTransferResult{Recipients: len(req.Recipients)}
+
+	var pending []*BatchTransferRecipient
+	for _, recipient := range req.Recipients {
+		paid, err := m.batchRecipientPaid(ctx, journal, batchJournalKey(req.TokenMint, recipient.Address))
+		if err != nil {
+			return nil, errors.Wrapf(err, "check journal for %s", recipient.Address)
+		}
+		if paid {
+			res.AlreadyPaid++
+			continue
+		}
+		pending = append(pending, recipient)
+	}
+	m.log.Info(ctx, "batch transfer recipients",
+		"total", len(req.Recipients),
+		"already_paid", res.AlreadyPaid,
+	)
+	if len(pending) == 0 {
+		return res, nil
+	}
+
+	items, err := m.batchTransferItems(ctx, fromAccount, req.TokenMint, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	memos, err := memoInstructions(req.Memo, fromAccount.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	batches, err := packBatchTransfer(fromAccount.PublicKey, memos, items)
+	if err != nil {
+		return nil, err
+	}
+	m.log.Info(ctx, "batch transfer transactions", len(batches))
+
+	if m.dryRun {
+		for _, batch := range batches {
+			tx, err := m.signTransaction(ctx, []types.Account{*fromAccount}, fromAccount.PublicKey, batch.instructions)
+			if err != nil {
+				return nil, err
+			}
+
+			report, err := m.simulateTransaction(ctx, tx)
+			if err != nil {
+				return nil, err
+			}
+			res.Simulations = append(res.Simulations, report)
+		}
+
+		return res, nil
+	}
+
+	concurrency := req.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		jobs = make(chan *batchTransferBatch)
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for batch := range jobs {
+				txSignature, err := m.sendBatchTransfer(ctx, journal, fromAccount, req.TokenMint, batch)
+
+				mu.Lock()
+				if err != nil {
+					failure := &BatchTransferFailure{
+						Signature: txSignature,
+						Error:     err.Error(),
+					}
+					for _, item := range batch.items {
+						failure.Recipients = append(failure.Recipients, item.recipient.Address)
+					}
+					res.Failed = append(res.Failed, failure)
+				} else {
+					res.Paid += len(batch.items)
+					res.Transactions = append(res.Transactions, txSignature)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, batch := range batches {
+		jobs <- batch
+	}
+	close(jobs)
+	wg.Wait()
+
+	return res, nil
+}
+
+type BatchTransferEstimate struct {
+	Transactions  int    `json:"transactions"`
+	BaseFee       uint64 `json:"base_fee_lamports"`
+	PriorityFee   uint64 `json:"priority_fee_lamports"`
+	TotalLamports uint64 `json:"total_fee_lamports"`
+}
+
+// EstimateBatchTransfer prices the transactions paying every recipient,
+// including those a rerun would skip as already paid.
+func (m *Module) EstimateBatchTransfer(ctx context.Context, req *BatchTransferRequest) (*BatchTransferEstimate, error) {
+	_, span := tracer.Start(ctx, "internal.solana.EstimateBatchTransfer")
+	defer span.End()
+
+	fromAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load sender account")
+	}
+
+	items, err := m.batchTransferItems(ctx, fromAccount, req.TokenMint, req.Recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	memos, err := memoInstructions(req.Memo, fromAccount.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	batches, err := packBatchTransfer(fromAccount.PublicKey, memos, items)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &BatchTransferEstimate{Transactions: len(batches)}
+	for _, batch := range batches {
+		instructions, err := m.withComputeBudget(ctx, fromAccount.PublicKey, batch.instructions)
+		if err != nil {
+			return nil, errors.Wrap(err, "apply compute budget")
+		}
+
+		fee, err := m.estimateFee(ctx, fromAccount.PublicKey, instructions)
+		if err != nil {
+			return nil, err
+		}
+		res.BaseFee += fee.BaseFee
+		res.PriorityFee += fee.PriorityFee
+		res.TotalLamports += fee.TotalLamports
+	}
+
+	return res, nil
+}
+
+func batchJournalKey(mint, address string) string {
+	if mint == "" {
+		mint = "SOL"
+	}
+
+	return fmt.Sprintf("%s:%s", mint, address)
+}
+
+// batchRecipientPaid resolves a journal entry against the chain. A sent
+// transaction whose blockhash is still valid may land at any moment, so it
+// is neither paid nor safe to resend. Failed entries are checked as well, as
+// journals of older versions marked transactions failed on any send error.
+func (m *Module) batchRecipientPaid(ctx context.Context, journal *batchJournal, key string) (bool, error) {
+	entry := journal.get(key)
+	if entry == nil {
+		return false, nil
+	}
+
+	if entry.Status == batchStatusConfirmed {
+		return true, nil
+	}
+
+	status, err := m.solanaClient.GetSignatureStatusWithConfig(ctx, entry.Signature, client.GetSignatureStatusesConfig{
+		SearchTransactionHistory: true,
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "get signature status")
+	}
+	if status != nil {
+		if status.Err != nil {
+			return false, nil
+		}
+
+		confirmed := *entry
+		confirmed.Status = batchStatusConfirmed
+
+		return true, journal.record(&confirmed)
+	}
+
+	valid, err := m.solanaClient.IsBlockhashValid(ctx, entry.Blockhash)
+	if err != nil {
+		return false, errors.Wrap(err, "check blockhash validity")
+	}
+	if valid {
+		return false, errorf(ErrorKindTimeout, "transaction %s may still land, rerun once its blockhash expires", entry.Signature)
+	}
+
+	return false, nil
+}
+
+// cannotLand reports a send error after which the transaction never lands:
+// the node refused it in preflight simulation, or the send was interrupted
+// before anything went out.
+func cannotLand(err error) bool {
+	var programErr *ProgramError
+	if errors.As(err, &programErr) {
+		return true
+	}
+
+	var interruptedErr *InterruptedError
+	if errors.As(err, &interruptedErr) {
+		return !interruptedErr.Sent
+	}
+
+	var rpcErr *rpc.JsonRpcError
+
+	return errors.As(err, &rpcErr) && rpcErr.Code == rpcSimulationFailed
+}
+
+func (m *Module) batchTransferItems(ctx context.Context, fromAccount *types.Account, tokenMint string, recipients []*BatchTransferRecipient) ([]*batchTransferItem, error) {
+	items := make([]*batchTransferItem, len(recipients))
+
+	addresses := make([]string, len(recipients))
+	for i, recipient := range recipients {
+		addresses[i] = recipient.Address
+	}
+	resolved, err := m.resolveRecipients(ctx, addresses, tokenMint)
+	if err != nil {
+		return nil, err
+	}
+	asset := tokenMint
+	if asset == "" {
+		asset = AssetSOL
+	}
+	amounts := map[string]uint64{}
+	for i, recipient := range resolved {
+		for _, warning := range recipient.Warnings {
+			m.log.Info(ctx, "recipient warning", recipient.Address, warning)
+		}
+		for _, address := range recipient.limitAddresses() {
+			amounts[address] += recipients[i].Amount
+		}
+	}
+	if err := m.checkContactLimits(asset, amounts); err != nil {
+		return nil, err
+	}
+
+	if tokenMint == "" {
+		var total uint64
+		for i, recipient := range recipients {
+			total += recipient.Amount
+			items[i] = &batchTransferItem{
+				recipient: recipient,
+				key:       batchJournalKey(tokenMint, recipient.Address),
+				instructions: []types.Instruction{
+					system.Transfer(system.TransferParam{
+						From:   fromAccount.PublicKey,
+						To:     resolved[i].pubKey,
+						Amount: recipient.Amount,
+					}),
+				},
+			}
+		}
+
+		balance, err := m.solanaClient.GetBalance(ctx, fromAccount.PublicKey.ToBase58())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get sender balance")
+		}
+		if balance < total {
+			return nil, &InsufficientBalanceError{Address: fromAccount.PublicKey.ToBase58(), Asset: AssetSOL, Purpose: "batch transfer before fees", Have: balance, Need: total}
+		}
+
+		return items, nil
+	}
+
+	tokenMintPubKey, err := ParseAddress(tokenMint)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid token mint")
+	}
+
+	fromTokenAccount, _, err := common.FindAssociatedTokenAddress(fromAccount.PublicKey, tokenMintPubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find sender token account")
+	}
+
+	tokenBalance, err := m.solanaClient.GetTokenAccountBalance(ctx, fromTokenAccount.ToBase58())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get sender token balance")
+	}
+
+	var total uint64
+	destinations := make([]common.PublicKey, len(recipients))
+	// Recipients that already are token accounts need no ATA.
+	direct := make([]bool, len(recipients))
+	var ataAddresses []string
+	for i, recipient := range recipients {
+		total += recipient.Amount
+
+		destinations[i], direct[i], err = resolved[i].tokenDestination(tokenMintPubKey)
+		if err != nil {
+			return nil, err
+		}
+		if !direct[i] {
+			ataAddresses = append(ataAddresses, destinations[i].ToBase58())
+		}
+	}
+	if tokenBalance.Amount < total {
+		return nil, &InsufficientBalanceError{Address: fromTokenAccount.ToBase58(), Asset: tokenMint, Purpose: "batch transfer", Have: tokenBalance.Amount, Need: total}
+	}
+
+	ataAccounts, err := m.getMultipleAccounts(ctx, ataAddresses)
+	if err != nil {
+		return nil, errors.Wrap(err, "get recipient token accounts")
+	}
+
+	for i, recipient := range recipients {
+		var instructions []types.Instruction
+		if !direct[i] {
+			account := ataAccounts[0]
+			ataAccounts = ataAccounts[1:]
+
+			if account == nil || account.Owner != common.TokenProgramID {
+				instructions = append(instructions, associated_token_account.Create(associated_token_account.CreateParam{
+					Funder:                 fromAccount.PublicKey,
+					Owner:                  resolved[i].pubKey,
+					Mint:                   tokenMintPubKey,
+					AssociatedTokenAccount: destinations[i],
+				}))
+			}
+		}
+		instructions = append(instructions, token.Transfer(token.TransferParam{
+			From:   fromTokenAccount,
+			To:     destinations[i],
+			Amount: recipient.Amount,
+			Auth:   fromAccount.PublicKey,
+		}))
+
+		items[i] = &batchTransferItem{
+			recipient:    recipient,
+			key:          batchJournalKey(tokenMint, recipient.Address),
+			instructions: instructions,
+		}
+	}
+
+	return items, nil
+}
+
+// packBatchTransfer greedily fills transactions up to the size limit, keeping
+// each recipient's instructions together. Every transaction starts with the
+// header instructions, and room is left for the compute budget instructions
+// added when signing.
+func packBatchTransfer(feePayer common.PublicKey, header []types.Instruction, items []*batchTransferItem) ([]*batchTransferBatch, error) {
+	var (
+		res     []*batchTransferBatch
+		current = &batchTransferBatch{instructions: header}
+	)
+	for _, item := range items {
+		instructions := append(append([]types.Instruction{}, current.instructions...), item.instructions...)
+
+		size, err := transactionSize(feePayer, append(computeBudgetPlaceholder(), instructions...))
+		if err != nil {
+			return nil, err
+		}
+
+		if size > maxTransactionSize {
+			if len(current.items) == 0 {
+				return nil, errorf(ErrorKindInvalidInput, "transfer to %s does not fit into a transaction", item.recipient.Address)
+			}
+
+			res = append(res, current)
+			current = &batchTransferBatch{}
+			instructions = append(append([]types.Instruction{}, header...), item.instructions...)
+		}
+
+		current.items = append(current.items, item)
+		current.instructions = instructions
+	}
+
+	if len(current.items) > 0 {
+		res = append(res, current)
+	}
+
+	return res, nil
+}
+
+// sendBatchTransfer journals the signature before sending, the signature
+// being known as soon as the transaction is signed.
+func (m *Module) sendBatchTransfer(ctx context.Context, journal *batchJournal, fromAccount *types.Account, tokenMint string, batch *batchTransferBatch) (string, error) {
+	tx, err := m.signTransaction(ctx, []types.Account{*fromAccount}, fromAccount.PublicKey, batch.instructions)
+	if err != nil {
+		return "", err
+	}
+
+	entries := make([]*batchJournalEntry, len(batch.items))
+	for i, item := range batch.items {
+		entries[i] = &batchJournalEntry{
+			Key:       item.key,
+			Address:   item.recipient.Address,
+			Mint:      tokenMint,
+			Amount:    item.recipient.Amount,
+			Signature: tx.Signature,
+			Blockhash: tx.Blockhash,
+			Status:    batchStatusSent,
+		}
+	}
+	if err := journal.record(entries...); err != nil {
+		return tx.Signature, errors.Wrap(err, "journal transaction")
+	}
+
+	markFailed := func() {
+		for _, entry := range entries {
+			entry.Status = batchStatusFailed
+		}
+		if err := journal.record(entries...); err != nil {
+			m.log.Error(ctx, "failed to journal failed transaction", err, tx.Signature)
+		}
+	}
+
+	if err := m.broadcastTransaction(ctx, tx); err != nil {
+		// Any other send error may come after the node accepted the
+		// transaction, the entries stay "sent" for the resume.
+		if cannotLand(err) {
+			markFailed()
+		}
+
+		return tx.Signature, err
+	}
+	m.log.Info(ctx, "batch transaction signature", tx.Signature, "recipients", len(batch.items))
+
+	res, err := m.confirmTransaction(ctx, tx)
+	if err != nil {
+		// The outcome is unknown, the entries stay "sent" and are checked on resume.
+		return tx.Signature, err
+	}
+	if err := res.Err(); err != nil {
+		markFailed()
+
+		return tx.Signature, err
+	}
+
+	for _, entry := range entries {
+		entry.Status = batchStatusConfirmed
+	}
+	if err := journal.record(entries...); err != nil {
+		return tx.Signature, errors.Wrap(err, "journal confirmed transaction")
+	}
+
+	return tx.Signature, nil
+}
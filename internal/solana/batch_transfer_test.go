@@ -0,0 +1,68 @@
+package solana
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBatchTransferCSV(t *testing.T) {
+	a, b := types.NewAccount().PublicKey.ToBase58(), types.NewAccount().PublicKey.ToBase58()
+
+	res, err := ParseBatchTransferCSV(strings.NewReader("address,amount\n"+a+",10\n# comment\n"+b+", 20\n"), nil)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	require.Equal(t, a, res[0].Address)
+	require.Equal(t, uint64(20), res[1].Amount)
+
+	_, err = ParseBatchTransferCSV(strings.NewReader(a+",10\n"+a+",5\n"), nil)
+	require.Error(t, err)
+
+	_, err = ParseBatchTransferCSV(strings.NewReader("not-an-address,10\n"), nil)
+	require.Error(t, err)
+}
+
+func Test_packBatchTransfer(t *testing.T) {
+	feePayer := types.NewAccount().PublicKey
+
+	items := make([]*batchTransferItem, 50)
+	for i := range items {
+		items[i] = &batchTransferItem{
+			recipient: &BatchTransferRecipient{Address: types.NewAccount().PublicKey.ToBase58()},
+			instructions: []types.Instruction{system.Transfer(system.TransferParam{
+				From:   feePayer,
+				To:     common.PublicKeyFromString(types.NewAccount().PublicKey.ToBase58()),
+				Amount: 1,
+			})},
+		}
+	}
+
+	batches, err := packBatchTransfer(feePayer, nil, items)
+	require.NoError(t, err)
+	require.Greater(t, len(batches), 1)
+
+	var total int
+	for _, batch := range batches {
+		size, err := transactionSize(feePayer, append(computeBudgetPlaceholder(), batch.instructions...))
+		require.NoError(t, err)
+		require.LessOrEqual(t, size, maxTransactionSize)
+		total += len(batch.items)
+	}
+	require.Equal(t, len(items), total)
+}
+
+func Test_cannotLand(t *testing.T) {
+	require.True(t, cannotLand(&ProgramError{Name: "InsufficientFunds"}))
+	require.True(t, cannotLand(errors.Wrap(&rpc.JsonRpcError{Code: rpcSimulationFailed}, "failed to send transaction")))
+	require.False(t, cannotLand(errors.Wrap(&rpc.JsonRpcError{Code: -32005}, "failed to send transaction")))
+	require.False(t, cannotLand(errors.Wrap(errors.New("rpc: call error, err: failed to do request, err: connection reset by peer"), "failed to send transaction")))
+	require.False(t, cannotLand(&InterruptedError{Signature: "sig", Sent: true}))
+	require.True(t, cannotLand(&InterruptedError{err: context.Canceled}))
+}
@@ -0,0 +1,399 @@
+package solana
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/metaplex/token_metadata"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/near/borsh-go"
+	"github.com/pkg/errors"
+)
+
+var (
+	bubblegumProgramID          = common.PublicKeyFromString("BGUMAp9Gq7iTEuizy4pqaxsTyUCBK68MDfK752saRPUY")
+	accountCompressionProgramID = common.PublicKeyFromString("cmtDvXumGCrqC1Age74AVPhSRVXJMd8PJS91L8KbNCK")
+	noopProgramID               = common.PublicKeyFromString("noopb9bkMVfRPU8AsbpTUg8AQkHtKwMYZiFUjNRtMmV")
+)
+
+const (
+	// merkleTreeHeaderSize is the account type byte, the header version byte
+	// and the V1 header (buffer size, depth, authority, creation slot, padding).
+	merkleTreeHeaderSize = 1 + 1 + 4 + 4 + 32 + 8 + 6
+
+	// treeConfigSize is the Bubblegum TreeConfig account size including
+	// the Anchor discriminator and reserved padding.
+	treeConfigSize = 96
+)
+
+// validMerkleTreeSizes lists the (max depth, max buffer size) pairs
+// supported by the SPL Account Compression program.
+var validMerkleTreeSizes = map[uint32][]uint32{
+	3:  {8},
+	5:  {8},
+	14: {64, 256, 1024, 2048},
+	15: {64},
+	16: {64},
+	17: {64},
+	18: {64},
+	19: {64},
+	20: {64, 256, 1024, 2048},
+	24: {64, 256, 512, 1024, 2048},
+	26: {512, 1024, 2048},
+	30: {512, 1024, 2048},
+}
+
+func validateMerkleTreeSize(maxDepth, maxBufferSize, canopyDepth uint32) error {
+	bufferSizes, ok := validMerkleTreeSizes[maxDepth]
+	if !ok {
+		return errorf(ErrorKindInvalidInput, "unsupported max depth %d", maxDepth)
+	}
+
+	supported := false
+	for _, size := range bufferSizes {
+		if size == maxBufferSize {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return errorf(ErrorKindInvalidInput, "unsupported max buffer size %d for max depth %d (supported: %v)", maxBufferSize, maxDepth, bufferSizes)
+	}
+
+	if canopyDepth >= maxDepth {
+		return errorf(ErrorKindInvalidInput, "canopy depth %d must be less than max depth %d", canopyDepth, maxDepth)
+	}
+
+	return nil
+}
+
+// merkleTreeAccountSize mirrors getConcurrentMerkleTreeAccountSize from the
+// SPL Account Compression SDK.
+func merkleTreeAccountSize(maxDepth, maxBufferSize, canopyDepth uint32) uint64 {
+	// Both a change log and the rightmost proof hold a root/leaf, a path
+	// of maxDepth nodes, an index and padding.
+	pathSize := 32 + 32*uint64(maxDepth) + 4 + 4
+	treeSize := 8 + 8 + 8 + uint64(maxBufferSize)*pathSize + pathSize
+
+	var canopySize uint64
+	if canopyDepth > 0 {
+		canopySize = ((uint64(1) << (canopyDepth + 1)) - 2) * 32
+	}
+
+	return merkleTreeHeaderSize + treeSize + canopySize
+}
+
+// merkleTreeCanopyDepth recovers the canopy depth of an existing tree from its
+// account data, which is needed to know how much of a proof to send.
+func merkleTreeCanopyDepth(data []byte) (maxDepth uint32, canopyDepth uint32, err error) {
+	if len(data) < merkleTreeHeaderSize {
+		return 0, 0, errors.New("merkle tree account data is too short")
+	}
+
+	maxBufferSize := binary.LittleEndian.Uint32(data[2:6])
+	maxDepth = binary.LittleEndian.Uint32(data[6:10])
+
+	size := merkleTreeAccountSize(maxDepth, maxBufferSize, 0)
+	if uint64(len(data)) < size {
+		return 0, 0, errors.Errorf("merkle tree account data is too short: %d bytes, header needs %d", len(data), size)
+	}
+
+	canopyBytes := uint64(len(data)) - size
+	if canopyBytes == 0 {
+		return maxDepth, 0, nil
+	}
+
+	nodes := canopyBytes/32 + 2
+	if canopyBytes%32 != 0 || nodes&(nodes-1) != 0 {
+		return 0, 0, errors.Errorf("unexpected canopy size %d", canopyBytes)
+	}
+
+	return maxDepth, uint32(bits.TrailingZeros64(nodes)) - 1, nil
+}
+
+func anchorDiscriminator(name string) []byte {
+	h := sha256.Sum256([]byte(fmt.Sprintf("global:%s", name)))
+
+	return h[:8]
+}
+
+func findTreeConfigAddress(merkleTree common.PublicKey) (common.PublicKey, error) {
+	address, _, err := common.FindProgramAddress([][]byte{merkleTree.Bytes()}, bubblegumProgramID)
+
+	return address, err
+}
+
+func findCompressedAssetAddress(merkleTree common.PublicKey, nonce uint64) (common.PublicKey, error) {
+	nonceBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(nonceBytes, nonce)
+
+	address, _, err := common.FindProgramAddress([][]byte{[]byte("asset"), merkleTree.Bytes(), nonceBytes}, bubblegumProgramID)
+
+	return address, err
+}
+
+func findBubblegumCollectionSignerAddress() (common.PublicKey, error) {
+	address, _, err := common.FindProgramAddress([][]byte{[]byte("collection_cpi")}, bubblegumProgramID)
+
+	return address, err
+}
+
+type treeConfig struct {
+	TreeCreator       common.PublicKey
+	TreeDelegate      common.PublicKey
+	TotalMintCapacity uint64
+	NumMinted         uint64
+	IsPublic          bool
+}
+
+func treeConfigDeserialize(data []byte) (*treeConfig, error) {
+	if len(data) < 8+32+32+8+8+1 {
+		return nil, errors.New("tree config account data is too short")
+	}
+
+	data = data[8:]
+
+	return &treeConfig{
+		TreeCreator:       common.PublicKeyFromBytes(data[0:32]),
+		TreeDelegate:      common.PublicKeyFromBytes(data[32:64]),
+		TotalMintCapacity: binary.LittleEndian.Uint64(data[64:72]),
+		NumMinted:         binary.LittleEndian.Uint64(data[72:80]),
+		IsPublic:          data[80] == 1,
+	}, nil
+}
+
+type bubblegumTokenProgramVersion borsh.Enum
+
+const (
+	bubblegumTokenProgramVersionOriginal bubblegumTokenProgramVersion = iota
+	bubblegumTokenProgramVersionToken2022
+)
+
+type bubblegumCollection struct {
+	Verified bool
+	Key      common.PublicKey
+}
+
+type bubblegumUses struct {
+	UseMethod token_metadata.UseMethod
+	Remaining uint64
+	Total     uint64
+}
+
+type bubblegumCreator struct {
+	Address  common.PublicKey
+	Verified bool
+	Share    uint8
+}
+
+// bubblegumMetadataArgs is the Bubblegum MetadataArgs layout, which differs
+// from the Token Metadata DataV2 in field order and extra flags.
+type bubblegumMetadataArgs struct {
+	Name                 string
+	Symbol               string
+	Uri                  string
+	SellerFeeBasisPoints uint16
+	PrimarySaleHappened  bool
+	IsMutable            bool
+	EditionNonce         *uint8
+	TokenStandard        *token_metadata.TokenStandard
+	Collection           *bubblegumCollection
+	Uses                 *bubblegumUses
+	TokenProgramVersion  bubblegumTokenProgramVersion
+	Creators             []bubblegumCreator
+}
+
+type createTreeParam struct {
+	TreeConfig    common.PublicKey
+	MerkleTree    common.PublicKey
+	Payer         common.PublicKey
+	TreeCreator   common.PublicKey
+	MaxDepth      uint32
+	MaxBufferSize uint32
+	Public        *bool
+}
+
+func createTreeInstruction(param createTreeParam) (types.Instruction, error) {
+	args, err := borsh.Serialize(struct {
+		MaxDepth      uint32
+		MaxBufferSize uint32
+		Public        *bool
+	}{
+		MaxDepth:      param.MaxDepth,
+		MaxBufferSize: param.MaxBufferSize,
+		Public:        param.Public,
+	})
+	if err != nil {
+		return types.Instruction{}, errors.Wrap(err, "serialize create tree args")
+	}
+
+	return types.Instruction{
+		ProgramID: bubblegumProgramID,
+		Accounts: []types.AccountMeta{
+			{PubKey: param.TreeConfig, IsSigner: false, IsWritable: true},
+			{PubKey: param.MerkleTree, IsSigner: false, IsWritable: true},
+			{PubKey: param.Payer, IsSigner: true, IsWritable: true},
+			{PubKey: param.TreeCreator, IsSigner: true, IsWritable: false},
+			{PubKey: noopProgramID, IsSigner: false, IsWritable: false},
+			{PubKey: accountCompressionProgramID, IsSigner: false, IsWritable: false},
+			{PubKey: common.SystemProgramID, IsSigner: false, IsWritable: false},
+		},
+		Data: append(anchorDiscriminator("create_tree"), args...),
+	}, nil
+}
+
+type mintCompressedParam struct {
+	TreeConfig   common.PublicKey
+	LeafOwner    common.PublicKey
+	LeafDelegate common.PublicKey
+	MerkleTree   common.PublicKey
+	Payer        common.PublicKey
+	TreeDelegate common.PublicKey
+	Metadata     bubblegumMetadataArgs
+
+	// Collection is only used by mint_to_collection_v1.
+	CollectionAuthority common.PublicKey
+	CollectionMint      common.PublicKey
+}
+
+func (p mintCompressedParam) leadingAccounts() []types.AccountMeta {
+	return []types.AccountMeta{
+		{PubKey: p.TreeConfig, IsSigner: false, IsWritable: true},
+		{PubKey: p.LeafOwner, IsSigner: false, IsWritable: false},
+		{PubKey: p.LeafDelegate, IsSigner: false, IsWritable: false},
+		{PubKey: p.MerkleTree, IsSigner: false, IsWritable: true},
+		{PubKey: p.Payer, IsSigner: true, IsWritable: true},
+		{PubKey: p.TreeDelegate, IsSigner: true, IsWritable: false},
+	}
+}
+
+// verifiedCreatorAccounts returns the remaining accounts Bubblegum expects
+// for every creator marked as verified: each one has to sign the mint.
+func (p mintCompressedParam) verifiedCreatorAccounts() []types.AccountMeta {
+	var res []types.AccountMeta
+	for _, creator := range p.Metadata.Creators {
+		if creator.Verified {
+			res = append(res, types.AccountMeta{PubKey: creator.Address, IsSigner: true, IsWritable: false})
+		}
+	}
+
+	return res
+}
+
+func mintV1Instruction(param mintCompressedParam) (types.Instruction, error) {
+	args, err := borsh.Serialize(param.Metadata)
+	if err != nil {
+		return types.Instruction{}, errors.Wrap(err, "serialize metadata args")
+	}
+
+	accounts := append(param.leadingAccounts(),
+		types.AccountMeta{PubKey: noopProgramID, IsSigner: false, IsWritable: false},
+		types.AccountMeta{PubKey: accountCompressionProgramID, IsSigner: false, IsWritable: false},
+		types.AccountMeta{PubKey: common.SystemProgramID, IsSigner: false, IsWritable: false},
+	)
+
+	return types.Instruction{
+		ProgramID: bubblegumProgramID,
+		Accounts:  append(accounts, param.verifiedCreatorAccounts()...),
+		Data:      append(anchorDiscriminator("mint_v1"), args...),
+	}, nil
+}
+
+func mintToCollectionV1Instruction(param mintCompressedParam) (types.Instruction, error) {
+	args, err := borsh.Serialize(param.Metadata)
+	if err != nil {
+		return types.Instruction{}, errors.Wrap(err, "serialize metadata args")
+	}
+
+	collectionMetadata, err := token_metadata.GetTokenMetaPubkey(param.CollectionMint)
+	if err != nil {
+		return types.Instruction{}, errors.Wrap(err, "calculate collection metadata key")
+	}
+
+	collectionEdition, err := token_metadata.GetMasterEdition(param.CollectionMint)
+	if err != nil {
+		return types.Instruction{}, errors.Wrap(err, "calculate collection master edition key")
+	}
+
+	bubblegumSigner, err := findBubblegumCollectionSignerAddress()
+	if err != nil {
+		return types.Instruction{}, errors.Wrap(err, "calculate bubblegum collection signer")
+	}
+
+	accounts := append(param.leadingAccounts(),
+		types.AccountMeta{PubKey: param.CollectionAuthority, IsSigner: true, IsWritable: false},
+		// No delegated collection authority record: Bubblegum takes its own
+		// program ID as the "none" placeholder.
+		types.AccountMeta{PubKey: bubblegumProgramID, IsSigner: false, IsWritable: false},
+		types.AccountMeta{PubKey: param.CollectionMint, IsSigner: false, IsWritable: false},
+		types.AccountMeta{PubKey: collectionMetadata, IsSigner: false, IsWritable: true},
+		types.AccountMeta{PubKey: collectionEdition, IsSigner: false, IsWritable: false},
+		types.AccountMeta{PubKey: bubblegumSigner, IsSigner: false, IsWritable: false},
+		types.AccountMeta{PubKey: noopProgramID, IsSigner: false, IsWritable: false},
+		types.AccountMeta{PubKey: accountCompressionProgramID, IsSigner: false, IsWritable: false},
+		types.AccountMeta{PubKey: common.MetaplexTokenMetaProgramID, IsSigner: false, IsWritable: false},
+		types.AccountMeta{PubKey: common.SystemProgramID, IsSigner: false, IsWritable: false},
+	)
+
+	return types.Instruction{
+		ProgramID: bubblegumProgramID,
+		Accounts:  append(accounts, param.verifiedCreatorAccounts()...),
+		Data:      append(anchorDiscriminator("mint_to_collection_v1"), args...),
+	}, nil
+}
+
+type transferCompressedParam struct {
+	TreeConfig    common.PublicKey
+	LeafOwner     common.PublicKey
+	LeafDelegate  common.PublicKey
+	NewLeafOwner  common.PublicKey
+	MerkleTree    common.PublicKey
+	Root          [32]byte
+	DataHash      [32]byte
+	CreatorHash   [32]byte
+	Nonce         uint64
+	Index         uint32
+	ProofAccounts []common.PublicKey
+}
+
+func transferCompressedInstruction(param transferCompressedParam) (types.Instruction, error) {
+	args, err := borsh.Serialize(struct {
+		Root        [32]byte
+		DataHash    [32]byte
+		CreatorHash [32]byte
+		Nonce       uint64
+		Index       uint32
+	}{
+		Root:        param.Root,
+		DataHash:    param.DataHash,
+		CreatorHash: param.CreatorHash,
+		Nonce:       param.Nonce,
+		Index:       param.Index,
+	})
+	if err != nil {
+		return types.Instruction{}, errors.Wrap(err, "serialize transfer args")
+	}
+
+	accounts := []types.AccountMeta{
+		{PubKey: param.TreeConfig, IsSigner: false, IsWritable: false},
+		{PubKey: param.LeafOwner, IsSigner: true, IsWritable: false},
+		{PubKey: param.LeafDelegate, IsSigner: false, IsWritable: false},
+		{PubKey: param.NewLeafOwner, IsSigner: false, IsWritable: false},
+		{PubKey: param.MerkleTree, IsSigner: false, IsWritable: true},
+		{PubKey: noopProgramID, IsSigner: false, IsWritable: false},
+		{PubKey: accountCompressionProgramID, IsSigner: false, IsWritable: false},
+		{PubKey: common.SystemProgramID, IsSigner: false, IsWritable: false},
+	}
+	for _, node := range param.ProofAccounts {
+		accounts = append(accounts, types.AccountMeta{PubKey: node, IsSigner: false, IsWritable: false})
+	}
+
+	return types.Instruction{
+		ProgramID: bubblegumProgramID,
+		Accounts:  accounts,
+		Data:      append(anchorDiscriminator("transfer"), args...),
+	}, nil
+}
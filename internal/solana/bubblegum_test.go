@@ -0,0 +1,41 @@
+package solana
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_merkleTreeAccountSize(t *testing.T) {
+	require.Equal(t, uint64(31800), merkleTreeAccountSize(14, 64, 0))
+	require.Equal(t, uint64(31800+(1<<11-2)*32), merkleTreeAccountSize(14, 64, 10))
+}
+
+func Test_merkleTreeCanopyDepth(t *testing.T) {
+	for _, canopyDepth := range []uint32{0, 1, 5, 10} {
+		data := make([]byte, merkleTreeAccountSize(14, 64, canopyDepth))
+		data[2], data[6] = 64, 14
+
+		maxDepth, res, err := merkleTreeCanopyDepth(data)
+		require.NoError(t, err)
+		require.Equal(t, uint32(14), maxDepth)
+		require.Equal(t, canopyDepth, res)
+	}
+	// The header claims a bigger tree than the data holds.
+	data := make([]byte, merkleTreeAccountSize(14, 64, 0))
+	data[2], data[6] = 64, 20
+	_, _, err := merkleTreeCanopyDepth(data)
+	require.ErrorContains(t, err, "too short")
+}
+
+func Test_validateMerkleTreeSize(t *testing.T) {
+	require.NoError(t, validateMerkleTreeSize(14, 64, 0))
+	require.Equal(t, ErrorKindInvalidInput, KindOf(validateMerkleTreeSize(14, 65, 0)))
+	require.Equal(t, ErrorKindInvalidInput, KindOf(validateMerkleTreeSize(13, 64, 0)))
+	require.Equal(t, ErrorKindInvalidInput, KindOf(validateMerkleTreeSize(14, 64, 14)))
+}
+
+func Test_anchorDiscriminator(t *testing.T) {
+	require.Equal(t, []byte{145, 98, 192, 118, 184, 147, 118, 104}, anchorDiscriminator("mint_v1"))
+	require.Equal(t, []byte{163, 52, 200, 231, 140, 3, 69, 186}, anchorDiscriminator("transfer"))
+}
@@ -0,0 +1,485 @@
+package solana
+
+import (
+	"context"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+type CreateMerkleTreeRequest struct {
+	OwnerKeyFilename string
+	MaxDepth         uint32
+	MaxBufferSize    uint32
+	CanopyDepth      uint32
+	Public           bool
+}
+
+type MerkleTreeCostEstimate struct {
+	MaxDepth       uint32 `json:"max_depth"`
+	MaxBufferSize  uint32 `json:"max_buffer_size"`
+	CanopyDepth    uint32 `json:"canopy_depth"`
+	Capacity       uint64 `json:"capacity"`
+	AccountSize    uint64 `json:"account_size"`
+	TreeRent       uint64 `json:"tree_rent_lamports"`
+	TreeConfigRent uint64 `json:"tree_config_rent_lamports"`
+	// TransactionFee is only priced when the owner key file is known.
+	TransactionFee    *TransactionFee `json:"transaction_fee,omitempty"`
+	TotalLamports     uint64          `json:"total_lamports"`
+	LamportsPerNFTMax uint64          `json:"lamports_per_nft_at_capacity"`
+}
+
+type CreateMerkleTreeResult struct {
+	TransactionResult
+	TreeAddress  string `json:"tree_address"`
+	Capacity     uint64 `json:"capacity"`
+	RentLamports uint64 `json:"rent_lamports"`
+}
+
+func (m *Module) EstimateMerkleTreeCost(ctx context.Context, req *CreateMerkleTreeRequest) (*MerkleTreeCostEstimate, error) {
+	_, span := tracer.Start(ctx, "internal.solana.EstimateMerkleTreeCost")
+	defer span.End()
+
+	if err := validateMerkleTreeSize(req.MaxDepth, req.MaxBufferSize, req.CanopyDepth); err != nil {
+		return nil, errors.Wrap(err, "validate tree size")
+	}
+
+	accountSize := merkleTreeAccountSize(req.MaxDepth, req.MaxBufferSize, req.CanopyDepth)
+
+	treeRent, err := m.solanaClient.GetMinimumBalanceForRentExemption(ctx, accountSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "get merkle tree rent exemption")
+	}
+
+	treeConfigRent, err := m.solanaClient.GetMinimumBalanceForRentExemption(ctx, treeConfigSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "get tree config rent exemption")
+	}
+
+	capacity := uint64(1) << req.MaxDepth
+	total := treeRent + treeConfigRent
+
+	estimate := &MerkleTreeCostEstimate{
+		MaxDepth:          req.MaxDepth,
+		MaxBufferSize:     req.MaxBufferSize,
+		CanopyDepth:       req.CanopyDepth,
+		Capacity:          capacity,
+		AccountSize:       accountSize,
+		TreeRent:          treeRent,
+		TreeConfigRent:    treeConfigRent,
+		TotalLamports:     total,
+		LamportsPerNFTMax: total / capacity,
+	}
+
+	if req.OwnerKeyFilename != "" {
+		ownerAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load owner account")
+		}
+
+		// A throwaway tree key, the fee does not depend on it.
+		instructions, err := m.createMerkleTreeInstructions(ctx, req, estimate, ownerAccount, types.NewAccount().PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if estimate.TransactionFee, err = m.estimateFee(ctx, ownerAccount.PublicKey, instructions); err != nil {
+			return nil, err
+		}
+		estimate.TotalLamports += estimate.TransactionFee.TotalLamports
+	}
+
+	return estimate, nil
+}
+
+// CreateMerkleTree allocates a concurrent Merkle tree account and registers it
+// with Bubblegum, the owner becoming both tree creator and tree delegate.
+func (m *Module) CreateMerkleTree(ctx context.Context, req *CreateMerkleTreeRequest) (*CreateMerkleTreeResult, error) {
+	_, span := tracer.Start(ctx, "internal.solana.CreateMerkleTree")
+	defer span.End()
+
+	ownerAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load owner account")
+	}
+
+	estimate, err := m.EstimateMerkleTreeCost(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "estimate tree cost")
+	}
+
+	ownerBalance, err := m.solanaClient.GetBalance(ctx, ownerAccount.PublicKey.ToBase58())
+	if err != nil {
+		return nil, errors.Wrap(err, "get owner balance")
+	}
+	if ownerBalance < estimate.TotalLamports {
+		return nil, &InsufficientBalanceError{Address: ownerAccount.PublicKey.ToBase58(), Asset: AssetSOL, Purpose: "tree rent and fee", Have: ownerBalance, Need: estimate.TotalLamports}
+	}
+
+	treeAccount := types.NewAccount()
+	m.log.Info(ctx, "merkle tree address", treeAccount.PublicKey.ToBase58())
+
+	instructions, err := m.createMerkleTreeInstructions(ctx, req, estimate, ownerAccount, treeAccount.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	confirmation, err := m.sendAndConfirm(ctx, []types.Account{*ownerAccount, treeAccount}, ownerAccount.PublicKey, instructions)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &CreateMerkleTreeResult{
+		TransactionResult: TransactionResult{Signature: confirmation.Signature},
+		TreeAddress:       treeAccount.PublicKey.ToBase58(),
+		Capacity:          estimate.Capacity,
+		RentLamports:      estimate.TreeRent + estimate.TreeConfigRent,
+	}
+	if estimate.TransactionFee != nil {
+		res.FeeLamports = estimate.TransactionFee.TotalLamports
+	}
+
+	return res, nil
+}
+
+func (m *Module) createMerkleTreeInstructions(ctx context.Context, req *CreateMerkleTreeRequest, estimate *MerkleTreeCostEstimate, ownerAccount *types.Account, treeAddress common.PublicKey) ([]types.Instruction, error) {
+	treeConfigAddress, err := findTreeConfigAddress(treeAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "calculate tree config address")
+	}
+
+	allocateTreeInstruction := system.CreateAccount(system.CreateAccountParam{
+		From:     ownerAccount.PublicKey,
+		New:      treeAddress,
+		Lamports: estimate.TreeRent,
+		Space:    estimate.AccountSize,
+		Owner:    accountCompressionProgramID,
+	})
+
+	public := req.Public
+	createTreeInstruction, err := createTreeInstruction(createTreeParam{
+		TreeConfig:    treeConfigAddress,
+		MerkleTree:    treeAddress,
+		Payer:         ownerAccount.PublicKey,
+		TreeCreator:   ownerAccount.PublicKey,
+		MaxDepth:      req.MaxDepth,
+		MaxBufferSize: req.MaxBufferSize,
+		Public:        &public,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "build create tree instruction")
+	}
+
+	return m.withComputeBudget(ctx, ownerAccount.PublicKey, []types.Instruction{
+		allocateTreeInstruction,
+		createTreeInstruction,
+	})
+}
+
+type MintCompressedNFTRequest struct {
+	OwnerKeyFilename string
+	TreeAddress      string
+	// RecipientAddress defaults to the owner.
+	RecipientAddress string
+	// CollectionMint is optional; the owner has to be its update authority.
+	CollectionMint string
+
+	Name                 string
+	Symbol               string
+	Uri                  string
+	SellerFeeBasisPoints uint16
+}
+
+type MintCompressedNFTResult struct {
+	TransactionResult
+	AssetID     string `json:"asset_id"`
+	TreeAddress string `json:"tree_address"`
+	Owner       string `json:"owner"`
+}
+
+// MintCompressedNFT mints a compressed NFT into an existing tree and returns
+// its asset ID.
+func (m *Module) MintCompressedNFT(ctx context.Context, req *MintCompressedNFTRequest) (*MintCompressedNFTResult, error) {
+	_, span := tracer.Start(ctx, "internal.solana.MintCompressedNFT")
+	defer span.End()
+
+	ownerAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load owner account")
+	}
+
+	treePubKey, err := ParseAddress(req.TreeAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid tree address")
+	}
+
+	recipientPubKey := ownerAccount.PublicKey
+	if req.RecipientAddress != "" {
+		if recipientPubKey, err = ParseAddress(req.RecipientAddress); err != nil {
+			return nil, errors.Wrap(err, "invalid recipient address")
+		}
+	}
+
+	var collectionMint common.PublicKey
+	if req.CollectionMint != "" {
+		if collectionMint, err = ParseAddress(req.CollectionMint); err != nil {
+			return nil, errors.Wrap(err, "invalid collection mint")
+		}
+	}
+
+	treeConfigAddress, err := findTreeConfigAddress(treePubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "calculate tree config address")
+	}
+
+	treeConfigAccount, err := m.solanaClient.GetAccountInfo(ctx, treeConfigAddress.ToBase58())
+	if err != nil {
+		return nil, errors.Wrap(err, "get tree config")
+	}
+	if treeConfigAccount.Owner == (common.PublicKey{}) && treeConfigAccount.Lamports == 0 {
+		return nil, &AccountNotFoundError{Address: req.TreeAddress, What: "tree config of"}
+	}
+	if treeConfigAccount.Owner != bubblegumProgramID {
+		return nil, errorf(ErrorKindInvalidInput, "%s is not a Bubblegum tree", req.TreeAddress)
+	}
+
+	config, err := treeConfigDeserialize(treeConfigAccount.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode tree config")
+	}
+	if config.NumMinted >= config.TotalMintCapacity {
+		return nil, errorf(ErrorKindInvalidInput, "tree is full: %d of %d minted", config.NumMinted, config.TotalMintCapacity)
+	}
+
+	metadata := bubblegumMetadataArgs{
+		Name:                 req.Name,
+		Symbol:               req.Symbol,
+		Uri:                  req.Uri,
+		SellerFeeBasisPoints: req.SellerFeeBasisPoints,
+		IsMutable:            true,
+		TokenProgramVersion:  bubblegumTokenProgramVersionOriginal,
+		Creators: []bubblegumCreator{
+			{
+				Address:  ownerAccount.PublicKey,
+				Verified: true,
+				Share:    100,
+			},
+		},
+	}
+
+	param := mintCompressedParam{
+		TreeConfig:   treeConfigAddress,
+		LeafOwner:    recipientPubKey,
+		LeafDelegate: recipientPubKey,
+		MerkleTree:   treePubKey,
+		Payer:        ownerAccount.PublicKey,
+		TreeDelegate: ownerAccount.PublicKey,
+	}
+
+	var mintInstruction types.Instruction
+	if req.CollectionMint != "" {
+		param.CollectionMint = collectionMint
+		param.CollectionAuthority = ownerAccount.PublicKey
+		metadata.Collection = &bubblegumCollection{
+			Key: param.CollectionMint,
+		}
+		param.Metadata = metadata
+
+		mintInstruction, err = mintToCollectionV1Instruction(param)
+	} else {
+		param.Metadata = metadata
+
+		mintInstruction, err = mintV1Instruction(param)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "build mint instruction")
+	}
+
+	// The leaf nonce is the number of assets minted into the tree so far,
+	// so the asset ID is known before the transaction lands.
+	assetID, err := findCompressedAssetAddress(treePubKey, config.NumMinted)
+	if err != nil {
+		return nil, errors.Wrap(err, "calculate asset id")
+	}
+	m.log.Info(ctx, "compressed NFT asset id", assetID.ToBase58())
+
+	confirmation, err := m.sendAndConfirm(ctx, []types.Account{*ownerAccount}, ownerAccount.PublicKey, []types.Instruction{mintInstruction})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MintCompressedNFTResult{
+		TransactionResult: TransactionResult{Signature: confirmation.Signature},
+		AssetID:           assetID.ToBase58(),
+		TreeAddress:       req.TreeAddress,
+		Owner:             recipientPubKey.ToBase58(),
+	}, nil
+}
+
+type TransferCompressedNFTRequest struct {
+	OwnerKeyFilename string
+	AssetID          string
+	TargetAddress    string
+	// Memo is sent through the SPL Memo program when set.
+	Memo string
+}
+
+// EstimateTransferCompressedNFT prices the transfer, priority fee included.
+func (m *Module) EstimateTransferCompressedNFT(ctx context.Context, req *TransferCompressedNFTRequest) (*TransactionFee, error) {
+	_, span := tracer.Start(ctx, "internal.solana.EstimateTransferCompressedNFT")
+	defer span.End()
+
+	ownerAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load owner account")
+	}
+
+	instructions, err := m.transferCompressedNFTInstructions(ctx, req, ownerAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.estimateFee(ctx, ownerAccount.PublicKey, instructions)
+}
+
+// TransferCompressedNFT moves a compressed NFT owned by the key file account.
+// The leaf data and proof come from the DAS API.
+func (m *Module) TransferCompressedNFT(ctx context.Context, req *TransferCompressedNFTRequest) (*TransferResult, error) {
+	_, span := tracer.Start(ctx, "internal.solana.TransferCompressedNFT")
+	defer span.End()
+
+	ownerAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load owner account")
+	}
+
+	instructions, err := m.transferCompressedNFTInstructions(ctx, req, ownerAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	confirmation, fee, err := m.sendAndConfirmPriced(ctx, []types.Account{*ownerAccount}, ownerAccount.PublicKey, instructions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransferResult{
+		TransactionResult: TransactionResult{Signature: confirmation.Signature, FeeLamports: fee.TotalLamports},
+		From:              ownerAccount.PublicKey.ToBase58(),
+		To:                req.TargetAddress,
+		Asset:             req.AssetID,
+		Amount:            1,
+	}, nil
+}
+
+func (m *Module) transferCompressedNFTInstructions(ctx context.Context, req *TransferCompressedNFTRequest, ownerAccount *types.Account) ([]types.Instruction, error) {
+	asset, err := m.getAsset(ctx, req.AssetID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get asset")
+	}
+	if !asset.Compression.Compressed {
+		return nil, errorf(ErrorKindInvalidInput, "asset %s is not compressed", req.AssetID)
+	}
+	if asset.Ownership.Owner != ownerAccount.PublicKey.ToBase58() {
+		return nil, errorf(ErrorKindInvalidInput, "asset %s is owned by %s", req.AssetID, asset.Ownership.Owner)
+	}
+
+	recipient, err := m.ResolveRecipient(ctx, req.TargetAddress, "")
+	if err != nil {
+		return nil, err
+	}
+
+	proof, err := m.getAssetProof(ctx, req.AssetID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get asset proof")
+	}
+
+	treePubKey := common.PublicKeyFromString(asset.Compression.Tree)
+
+	treeAccount, err := m.solanaClient.GetAccountInfo(ctx, treePubKey.ToBase58())
+	if err != nil {
+		return nil, errors.Wrap(err, "get merkle tree account")
+	}
+
+	_, canopyDepth, err := merkleTreeCanopyDepth(treeAccount.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "read merkle tree canopy")
+	}
+
+	// Nodes covered by the canopy are stored on chain and must be left out.
+	proofLength := len(proof.Proof) - int(canopyDepth)
+	if proofLength < 0 {
+		proofLength = 0
+	}
+	proofAccounts := make([]common.PublicKey, proofLength)
+	for i := range proofAccounts {
+		proofAccounts[i] = common.PublicKeyFromString(proof.Proof[i])
+	}
+
+	root, err := decodeHash(proof.Root)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode root")
+	}
+	dataHash, err := decodeHash(asset.Compression.DataHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode data hash")
+	}
+	creatorHash, err := decodeHash(asset.Compression.CreatorHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode creator hash")
+	}
+
+	treeConfigAddress, err := findTreeConfigAddress(treePubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "calculate tree config address")
+	}
+
+	leafDelegate := ownerAccount.PublicKey
+	if asset.Ownership.Delegate != nil && *asset.Ownership.Delegate != "" {
+		leafDelegate = common.PublicKeyFromString(*asset.Ownership.Delegate)
+	}
+
+	transferInstruction, err := transferCompressedInstruction(transferCompressedParam{
+		TreeConfig:    treeConfigAddress,
+		LeafOwner:     ownerAccount.PublicKey,
+		LeafDelegate:  leafDelegate,
+		NewLeafOwner:  recipient.pubKey,
+		MerkleTree:    treePubKey,
+		Root:          root,
+		DataHash:      dataHash,
+		CreatorHash:   creatorHash,
+		Nonce:         asset.Compression.LeafID,
+		Index:         uint32(asset.Compression.LeafID),
+		ProofAccounts: proofAccounts,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "build transfer instruction")
+	}
+
+	memos, err := memoInstructions(req.Memo, ownerAccount.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.withComputeBudget(ctx, ownerAccount.PublicKey, append([]types.Instruction{transferInstruction}, memos...))
+}
+
+func decodeHash(s string) ([32]byte, error) {
+	var res [32]byte
+
+	data, err := base58.Decode(s)
+	if err != nil {
+		return res, err
+	}
+	if len(data) != len(res) {
+		return res, errors.Errorf("unexpected hash length %d", len(data))
+	}
+	copy(res[:], data)
+
+	return res, nil
+}
@@ -0,0 +1,367 @@
+package solana
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/compute_budget"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ComputeUnitLimitSimulate sizes the limit from a simulation.
+	ComputeUnitLimitSimulate = "simulate"
+	// ComputeUnitPriceAuto derives the price from recent prioritization fees.
+	ComputeUnitPriceAuto = "auto"
+
+	maxComputeUnitLimit = 1_400_000
+	// defaultInstructionComputeUnits is what the runtime allows per
+	// instruction when no limit is requested.
+	defaultInstructionComputeUnits = 200_000
+	// getRecentPrioritizationFees accepts at most 128 accounts.
+	prioritizationFeesAccountsLimit = 128
+)
+
+// ComputeBudget configures the compute budget instructions added to every
+// transaction. Empty fields leave the runtime defaults.
+type ComputeBudget struct {
+	// UnitLimit is a number of compute units or ComputeUnitLimitSimulate.
+	UnitLimit string
+	// UnitPrice is the priority fee in micro-lamports per compute unit or
+	// ComputeUnitPriceAuto.
+	UnitPrice string
+	// PriorityFeePercentile of recent fees used by ComputeUnitPriceAuto.
+	PriorityFeePercentile int
+}
+
+type computeBudget struct {
+	unitLimit     uint32
+	simulateLimit bool
+	unitPrice     uint64
+	autoPrice     bool
+	percentile    int
+}
+
+func parseComputeBudget(b *ComputeBudget) (*computeBudget, error) {
+	res := &computeBudget{percentile: b.PriorityFeePercentile}
+	if res.percentile < 1 || res.percentile > 100 {
+		return nil, errorf(ErrorKindInvalidInput, "priority fee percentile must be between 1 and 100, got %d", res.percentile)
+	}
+
+	switch b.UnitLimit {
+	case "":
+	case ComputeUnitLimitSimulate:
+		res.simulateLimit = true
+	default:
+		limit, err := strconv.ParseUint(b.UnitLimit, 10, 32)
+		if err != nil || limit == 0 || limit > maxComputeUnitLimit {
+			return nil, errorf(ErrorKindInvalidInput, "compute unit limit must be %q or between 1 and %d, got %q", ComputeUnitLimitSimulate, maxComputeUnitLimit, b.UnitLimit)
+		}
+		res.unitLimit = uint32(limit)
+	}
+
+	switch b.UnitPrice {
+	case "":
+	case ComputeUnitPriceAuto:
+		res.autoPrice = true
+	default:
+		price, err := strconv.ParseUint(b.UnitPrice, 10, 64)
+		if err != nil {
+			return nil, errorf(ErrorKindInvalidInput, "compute unit price must be %q or micro-lamports, got %q", ComputeUnitPriceAuto, b.UnitPrice)
+		}
+		res.unitPrice = price
+	}
+
+	return res, nil
+}
+
+// SetComputeBudget overrides the configured compute budget, fields left
+// empty keep the configured value.
+func (m *Module) SetComputeBudget(b *ComputeBudget) error {
+	c := *m.config
+	if b.UnitLimit != "" {
+		c.ComputeUnitLimit = b.UnitLimit
+	}
+	if b.UnitPrice != "" {
+		c.ComputeUnitPrice = b.UnitPrice
+	}
+	if b.PriorityFeePercentile != 0 {
+		c.PriorityFeePercentile = b.PriorityFeePercentile
+	}
+
+	budget, err := parseComputeBudget(c.computeBudgetConfig())
+	if err != nil {
+		return err
+	}
+
+	m.config = &c
+	m.computeBudget = budget
+
+	return nil
+}
+
+// withComputeBudget prepends the compute budget instructions. Instructions
+// that already carry them are returned as is, so a prepared list can be
+// priced and then sent.
+func (m *Module) withComputeBudget(ctx context.Context, feePayer common.PublicKey, instructions []types.Instruction) ([]types.Instruction, error) {
+	budget := m.computeBudget
+	if budget == nil || hasComputeBudget(instructions) {
+		return instructions, nil
+	}
+
+	unitPrice := budget.unitPrice
+	if budget.autoPrice {
+		var err error
+		if unitPrice, err = m.recentPriorityFee(ctx, feePayer, instructions, budget.percentile); err != nil {
+			return nil, err
+		}
+	}
+
+	unitLimit := budget.unitLimit
+	if budget.simulateLimit {
+		var err error
+		if unitLimit, err = m.simulateComputeUnits(ctx, feePayer, instructions, unitPrice); err != nil {
+			return nil, err
+		}
+	}
+
+	var res []types.Instruction
+	if unitLimit > 0 {
+		res = append(res, compute_budget.SetComputeUnitLimit(compute_budget.SetComputeUnitLimitParam{Units: unitLimit}))
+	}
+	if unitPrice > 0 {
+		res = append(res, compute_budget.SetComputeUnitPrice(compute_budget.SetComputeUnitPriceParam{MicroLamports: unitPrice}))
+	}
+	if len(res) > 0 {
+		m.log.Info(ctx, "compute budget", "unit_limit", unitLimit, "unit_price", unitPrice)
+	}
+
+	return append(res, instructions...), nil
+}
+
+// recentPriorityFee picks the percentile of the fees recently paid to lock
+// the writable accounts of the transaction.
+func (m *Module) recentPriorityFee(ctx context.Context, feePayer common.PublicKey, instructions []types.Instruction, percentile int) (uint64, error) {
+	accounts := writableAccounts(feePayer, instructions)
+	if len(accounts) > prioritizationFeesAccountsLimit {
+		accounts = accounts[:prioritizationFeesAccountsLimit]
+	}
+
+	recent, err := m.solanaClient.GetRecentPrioritizationFees(ctx, accounts)
+	if err != nil {
+		return 0, errors.Wrap(err, "get recent prioritization fees")
+	}
+
+	fees := make([]uint64, len(recent))
+	for i, fee := range recent {
+		fees[i] = fee.PrioritizationFee
+	}
+
+	return feePercentile(fees, percentile), nil
+}
+
+// simulateComputeUnits runs the transaction with the maximum limit and sizes
+// the limit from the units consumed plus a 10% margin.
+func (m *Module) simulateComputeUnits(ctx context.Context, feePayer common.PublicKey, instructions []types.Instruction, unitPrice uint64) (uint32, error) {
+	simulated := []types.Instruction{
+		compute_budget.SetComputeUnitLimit(compute_budget.SetComputeUnitLimitParam{Units: maxComputeUnitLimit}),
+	}
+	if unitPrice > 0 {
+		simulated = append(simulated, compute_budget.SetComputeUnitPrice(compute_budget.SetComputeUnitPriceParam{MicroLamports: unitPrice}))
+	}
+
+	simulated = append(simulated, instructions...)
+	res, err := m.simulate(ctx, feePayer, simulated, nil)
+	if err != nil {
+		return 0, err
+	}
+	if res.Err != nil {
+		if programErr := decodeProgramError(res.Err, instructionProgramIDs(simulated), res.Logs); programErr != nil {
+			return 0, programErr
+		}
+
+		return 0, errorf(ErrorKindTransactionFailed, "transaction simulation failed: %s", formatTransactionError(res.Err))
+	}
+	if res.UnitConsumed == nil {
+		return 0, errors.New("simulation did not report consumed compute units")
+	}
+
+	return computeUnitLimitFromSimulation(*res.UnitConsumed), nil
+}
+
+// simulate runs unsigned instructions against the latest bank state.
+func (m *Module) simulate(ctx context.Context, feePayer common.PublicKey, instructions []types.Instruction, addresses []string) (*client.SimulateTransaction, error) {
+	tx, err := types.NewTransaction(types.NewTransactionParam{
+		Message: types.NewMessage(types.NewMessageParam{
+			FeePayer: feePayer,
+			// Replaced by the node.
+			RecentBlockhash: common.PublicKey{}.ToBase58(),
+			Instructions:    instructions,
+		}),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create transaction")
+	}
+
+	res, err := m.solanaClient.SimulateTransactionWithConfig(ctx, tx, client.SimulateTransactionConfig{
+		Commitment:             m.config.commitment(),
+		ReplaceRecentBlockhash: true,
+		Addresses:              addresses,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "simulate transaction")
+	}
+
+	return &res, nil
+}
+
+func computeUnitLimitFromSimulation(unitsConsumed uint64) uint32 {
+	limit := unitsConsumed + unitsConsumed/10
+	if limit > maxComputeUnitLimit {
+		return maxComputeUnitLimit
+	}
+
+	return uint32(limit)
+}
+
+func feePercentile(fees []uint64, percentile int) uint64 {
+	if len(fees) == 0 {
+		return 0
+	}
+
+	sorted := append([]uint64{}, fees...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	idx := int(math.Ceil(float64(percentile)/100*float64(len(sorted)))) - 1
+	idx = max(0, min(idx, len(sorted)-1))
+
+	return sorted[idx]
+}
+
+func writableAccounts(feePayer common.PublicKey, instructions []types.Instruction) []common.PublicKey {
+	res := []common.PublicKey{feePayer}
+	seen := map[common.PublicKey]bool{feePayer: true}
+
+	for _, instruction := range instructions {
+		for _, account := range instruction.Accounts {
+			if account.IsWritable && !seen[account.PubKey] {
+				seen[account.PubKey] = true
+				res = append(res, account.PubKey)
+			}
+		}
+	}
+
+	return res
+}
+
+// computeBudgetPlaceholder has the size of the largest compute budget
+// withComputeBudget may prepend.
+func computeBudgetPlaceholder() []types.Instruction {
+	return []types.Instruction{
+		compute_budget.SetComputeUnitLimit(compute_budget.SetComputeUnitLimitParam{}),
+		compute_budget.SetComputeUnitPrice(compute_budget.SetComputeUnitPriceParam{}),
+	}
+}
+
+func hasComputeBudget(instructions []types.Instruction) bool {
+	for _, instruction := range instructions {
+		if instruction.ProgramID == common.ComputeBudgetProgramID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TransactionFee splits the fee of a transaction into the base fee and the
+// priority fee.
+type TransactionFee struct {
+	BaseFee          uint64 `json:"base_fee_lamports"`
+	PriorityFee      uint64 `json:"priority_fee_lamports"`
+	ComputeUnitLimit uint32 `json:"compute_unit_limit"`
+	ComputeUnitPrice uint64 `json:"compute_unit_price_micro_lamports"`
+	TotalLamports    uint64 `json:"total_lamports"`
+}
+
+// estimateFee prices the message built from the instructions, compute budget
+// instructions included.
+func (m *Module) estimateFee(ctx context.Context, feePayer common.PublicKey, instructions []types.Instruction) (*TransactionFee, error) {
+	recentBlockhash, err := m.solanaClient.GetLatestBlockhash(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get recent blockhash")
+	}
+
+	return m.messageFee(ctx, types.NewMessage(types.NewMessageParam{
+		FeePayer:        feePayer,
+		RecentBlockhash: recentBlockhash.Blockhash,
+		Instructions:    instructions,
+	}))
+}
+
+func (m *Module) messageFee(ctx context.Context, message types.Message) (*TransactionFee, error) {
+	fee, err := m.solanaClient.GetFeeForMessage(ctx, message)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get transaction fee")
+	}
+	if fee == nil {
+		return nil, errors.New("transaction fee is not available for the blockhash")
+	}
+
+	res := transactionComputeBudget(message.DecompileInstructions())
+	res.TotalLamports = *fee
+	res.PriorityFee = priorityFee(res.ComputeUnitLimit, res.ComputeUnitPrice)
+	if res.PriorityFee <= res.TotalLamports {
+		res.BaseFee = res.TotalLamports - res.PriorityFee
+	}
+
+	return res, nil
+}
+
+// transactionComputeBudget reads the limit and price requested by the
+// instructions, falling back to the runtime default limit.
+func transactionComputeBudget(instructions []types.Instruction) *TransactionFee {
+	res := &TransactionFee{}
+
+	var (
+		limitSet bool
+		others   uint32
+	)
+	for _, instruction := range instructions {
+		if instruction.ProgramID != common.ComputeBudgetProgramID {
+			others++
+			continue
+		}
+		if len(instruction.Data) == 0 {
+			continue
+		}
+
+		switch compute_budget.Instruction(instruction.Data[0]) {
+		case compute_budget.InstructionSetComputeUnitLimit:
+			if len(instruction.Data) >= 5 {
+				res.ComputeUnitLimit = binary.LittleEndian.Uint32(instruction.Data[1:5])
+				limitSet = true
+			}
+		case compute_budget.InstructionSetComputeUnitPrice:
+			if len(instruction.Data) >= 9 {
+				res.ComputeUnitPrice = binary.LittleEndian.Uint64(instruction.Data[1:9])
+			}
+		}
+	}
+
+	if !limitSet {
+		res.ComputeUnitLimit = min(others*defaultInstructionComputeUnits, maxComputeUnitLimit)
+	}
+
+	return res
+}
+
+// priorityFee is the limit times the price in micro-lamports, rounded up.
+func priorityFee(unitLimit uint32, unitPrice uint64) uint64 {
+	return (uint64(unitLimit)*unitPrice + 999_999) / 1_000_000
+}
@@ -0,0 +1,67 @@
+package solana
+
+import (
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/compute_budget"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseComputeBudget(t *testing.T) {
+	budget, err := parseComputeBudget(&ComputeBudget{UnitLimit: "300000", UnitPrice: "auto", PriorityFeePercentile: 75})
+	require.NoError(t, err)
+	require.Equal(t, &computeBudget{unitLimit: 300000, autoPrice: true, percentile: 75}, budget)
+
+	budget, err = parseComputeBudget(&ComputeBudget{UnitLimit: "simulate", UnitPrice: "1000", PriorityFeePercentile: 50})
+	require.NoError(t, err)
+	require.Equal(t, &computeBudget{simulateLimit: true, unitPrice: 1000, percentile: 50}, budget)
+
+	_, err = parseComputeBudget(&ComputeBudget{UnitLimit: "1400001", PriorityFeePercentile: 75})
+	require.Error(t, err)
+	_, err = parseComputeBudget(&ComputeBudget{UnitPrice: "fast", PriorityFeePercentile: 75})
+	require.Error(t, err)
+	_, err = parseComputeBudget(&ComputeBudget{PriorityFeePercentile: 0})
+	require.Error(t, err)
+}
+
+func Test_feePercentile(t *testing.T) {
+	require.Equal(t, uint64(0), feePercentile(nil, 75))
+
+	fees := []uint64{50, 0, 10, 40, 20, 30, 0, 0}
+	require.Equal(t, uint64(0), feePercentile(fees, 25))
+	require.Equal(t, uint64(20), feePercentile(fees, 60))
+	require.Equal(t, uint64(30), feePercentile(fees, 75))
+	require.Equal(t, uint64(50), feePercentile(fees, 100))
+	require.Equal(t, []uint64{50, 0, 10, 40, 20, 30, 0, 0}, fees)
+}
+
+func Test_transactionComputeBudget(t *testing.T) {
+	from, to := types.NewAccount().PublicKey, types.NewAccount().PublicKey
+	transfer := system.Transfer(system.TransferParam{From: from, To: to, Amount: 1})
+
+	res := transactionComputeBudget([]types.Instruction{transfer, transfer})
+	require.Equal(t, uint32(400000), res.ComputeUnitLimit)
+	require.Zero(t, res.ComputeUnitPrice)
+
+	res = transactionComputeBudget([]types.Instruction{
+		compute_budget.SetComputeUnitLimit(compute_budget.SetComputeUnitLimitParam{Units: 1000}),
+		compute_budget.SetComputeUnitPrice(compute_budget.SetComputeUnitPriceParam{MicroLamports: 2500}),
+		transfer,
+	})
+	require.Equal(t, uint32(1000), res.ComputeUnitLimit)
+	require.Equal(t, uint64(2500), res.ComputeUnitPrice)
+	require.Equal(t, uint64(3), priorityFee(res.ComputeUnitLimit, res.ComputeUnitPrice))
+
+	require.True(t, hasComputeBudget(append(computeBudgetPlaceholder(), transfer)))
+	require.False(t, hasComputeBudget([]types.Instruction{transfer}))
+
+	require.Equal(t, []common.PublicKey{from, to}, writableAccounts(from, []types.Instruction{transfer, transfer}))
+}
+
+func Test_computeUnitLimitFromSimulation(t *testing.T) {
+	require.Equal(t, uint32(330), computeUnitLimitFromSimulation(300))
+	require.Equal(t, uint32(maxComputeUnitLimit), computeUnitLimitFromSimulation(1_390_000))
+}
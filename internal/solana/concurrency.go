@@ -0,0 +1,52 @@
+package solana
+
+import (
+	"sync"
+)
+
+// runConcurrently calls fn for every index in [0, n) with at most limit
+// calls in flight. Once a call fails no new ones start, and the first error
+// is returned.
+func runConcurrently(n, limit int, fn func(i int) error) error {
+	if limit < 1 {
+		limit = 1
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		jobs     = make(chan int)
+	)
+	for w := 0; w < min(limit, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				mu.Lock()
+				failed := firstErr != nil
+				mu.Unlock()
+				if failed {
+					continue
+				}
+
+				if err := fn(i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return firstErr
+}
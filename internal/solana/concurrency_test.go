@@ -0,0 +1,45 @@
+package solana
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_runConcurrently(t *testing.T) {
+	var (
+		inFlight, peak atomic.Int32
+		done           = make([]bool, 50)
+	)
+	err := runConcurrently(len(done), 4, func(i int) error {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			old := peak.Load()
+			if current <= old || peak.CompareAndSwap(old, current) {
+				break
+			}
+		}
+
+		done[i] = true
+		return nil
+	})
+	require.NoError(t, err)
+	require.LessOrEqual(t, peak.Load(), int32(4))
+	for _, ok := range done {
+		require.True(t, ok)
+	}
+
+	failure := errors.New("rate limited")
+	err = runConcurrently(10, 3, func(i int) error {
+		if i == 2 {
+			return failure
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, failure)
+
+	require.NoError(t, runConcurrently(0, 4, func(int) error { return nil }))
+}
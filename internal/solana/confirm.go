@@ -0,0 +1,262 @@
+package solana
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/pkg/errors"
+)
+
+type ConfirmationStatus string
+
+const (
+	ConfirmationConfirmed ConfirmationStatus = "confirmed"
+	ConfirmationFailed    ConfirmationStatus = "failed"
+	ConfirmationExpired   ConfirmationStatus = "expired"
+)
+
+type ConfirmationResult struct {
+	Signature  string             `json:"signature"`
+	Status     ConfirmationStatus `json:"status"`
+	Commitment rpc.Commitment     `json:"commitment,omitempty"`
+	Slot       uint64             `json:"slot,omitempty"`
+	// Error is the decoded transaction error of a failed transaction.
+	Error string `json:"error,omitempty"`
+
+	programErr *ProgramError
+}
+
+// Err reports a failed or expired transaction as an error.
+func (r *ConfirmationResult) Err() error {
+	switch r.Status {
+	case ConfirmationConfirmed:
+		return nil
+	case ConfirmationFailed:
+		if r.programErr != nil {
+			return r.programErr
+		}
+
+		return errorf(ErrorKindTransactionFailed, "transaction %s failed: %s", r.Signature, r.Error)
+	case ConfirmationExpired:
+		return &TransactionExpiredError{Signature: r.Signature}
+	default:
+		return errors.Errorf("transaction %s has unknown status %s", r.Signature, r.Status)
+	}
+}
+
+var commitmentLevels = map[rpc.Commitment]int{
+	rpc.CommitmentProcessed: 1,
+	rpc.CommitmentConfirmed: 2,
+	rpc.CommitmentFinalized: 3,
+}
+
+func commitmentReached(status *rpc.Commitment, target rpc.Commitment) bool {
+	if status == nil {
+		return false
+	}
+
+	return commitmentLevels[*status] >= commitmentLevels[target]
+}
+
+// confirmTransaction waits until the transaction reaches the configured
+// commitment, fails, or its blockhash expires. It listens for a signature
+// notification and polls the status only when PubSub is unavailable or the
+// subscription was interrupted. While the blockhash is valid the transaction
+// is rebroadcast, since RPC nodes drop transactions under load. Cancelling
+// parent stops waiting with an *InterruptedError.
+func (m *Module) confirmTransaction(parent context.Context, tx *signedTransaction) (*ConfirmationResult, error) {
+	ctx, cancel := context.WithTimeout(parent, m.config.ConfirmTimeout)
+	defer cancel()
+
+	m.log.Info(ctx, "waiting for confirmation", tx.Signature, "commitment", m.config.commitment())
+
+	var notifications <-chan json.RawMessage
+	sub, err := m.subscribeSignature(ctx, tx.Signature)
+	if err != nil {
+		m.log.Error(ctx, "signature subscription unavailable, polling instead", err)
+	} else {
+		defer sub.Close()
+		notifications = sub.Notifications()
+	}
+
+	ticker := time.NewTicker(m.config.ConfirmPollInterval)
+	defer ticker.Stop()
+
+	poll, landed := true, false
+	for {
+		if poll {
+			status, err := m.solanaClient.GetSignatureStatus(ctx, tx.Signature)
+			if err != nil {
+				m.log.Error(ctx, "failed to get signature status", err, tx.Signature)
+			}
+			if status != nil {
+				landed = true
+				if res := m.signatureStatusResult(ctx, tx, status); res != nil {
+					return res, nil
+				}
+			}
+		}
+
+		if !landed {
+			if res := m.checkBlockhashExpiry(ctx, tx); res != nil {
+				return res, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if err := parent.Err(); err != nil {
+				return nil, &InterruptedError{Signature: tx.Signature, Sent: true, err: err}
+			}
+
+			return nil, errors.Wrapf(ctx.Err(), "wait for confirmation of %s", tx.Signature)
+		case data := <-notifications:
+			return m.signatureNotificationResult(ctx, tx, data)
+		case <-ticker.C:
+			poll = sub == nil || sub.stale()
+		}
+	}
+}
+
+// signatureStatusResult returns the outcome once the status is final for the
+// configured commitment.
+func (m *Module) signatureStatusResult(ctx context.Context, tx *signedTransaction, status *rpc.SignatureStatus) *ConfirmationResult {
+	signature := tx.Signature
+	res := &ConfirmationResult{Signature: signature, Slot: status.Slot}
+	if status.ConfirmationStatus != nil {
+		res.Commitment = *status.ConfirmationStatus
+	}
+
+	if status.Err != nil {
+		res.Status = ConfirmationFailed
+		res.Error = formatTransactionError(status.Err)
+		if programErr := decodeProgramError(status.Err, messageProgramIDs(tx.Transaction.Message), nil); programErr != nil {
+			programErr.Signature = signature
+			res.programErr = programErr
+			res.Error = programErr.Detail()
+		}
+		m.log.Error(ctx, "transaction failed", signature, res.Error)
+
+		return res
+	}
+
+	if commitmentReached(status.ConfirmationStatus, m.config.commitment()) {
+		res.Status = ConfirmationConfirmed
+		m.log.Info(ctx, "transaction has been confirmed", signature, "commitment", res.Commitment)
+
+		return res
+	}
+
+	return nil
+}
+
+func (m *Module) signatureNotificationResult(ctx context.Context, tx *signedTransaction, data json.RawMessage) (*ConfirmationResult, error) {
+	notification, err := decodeNotification[signatureNotification](data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The notification is sent once the subscribed commitment is reached.
+	commitment := m.config.commitment()
+	status := &rpc.SignatureStatus{
+		Slot:               notification.Context.Slot,
+		ConfirmationStatus: &commitment,
+		Err:                notification.Value.Err,
+	}
+
+	return m.signatureStatusResult(ctx, tx, status), nil
+}
+
+// checkBlockhashExpiry returns the expired outcome once the blockhash can no
+// longer land, and rebroadcasts the transaction otherwise.
+func (m *Module) checkBlockhashExpiry(ctx context.Context, tx *signedTransaction) *ConfirmationResult {
+	blockHeight, err := m.blockHeight(ctx)
+	if err != nil {
+		m.log.Error(ctx, "failed to get block height", err)
+
+		return nil
+	}
+
+	if blockHeight > tx.LastValidBlockHeight {
+		// The transaction may have landed right before the blockhash expired.
+		// Only a successful lookup without a status proves it did not, since
+		// an expired transaction is reported as safe to send again.
+		status, err := m.solanaClient.GetSignatureStatus(ctx, tx.Signature)
+		if err != nil {
+			m.log.Error(ctx, "failed to get signature status", err, tx.Signature)
+
+			return nil
+		}
+		if status != nil {
+			return m.signatureStatusResult(ctx, tx, status)
+		}
+
+		m.log.Error(ctx, "transaction blockhash expired", tx.Signature, "last_valid_block_height", tx.LastValidBlockHeight)
+
+		return &ConfirmationResult{Signature: tx.Signature, Status: ConfirmationExpired}
+	}
+
+	if _, err := m.solanaClient.SendTransactionWithConfig(ctx, tx.Transaction, client.SendTransactionConfig{
+		SkipPreflight: true,
+	}); err != nil {
+		m.log.Error(ctx, "failed to rebroadcast transaction", err, tx.Signature)
+	}
+
+	return nil
+}
+
+func (m *Module) blockHeight(ctx context.Context) (uint64, error) {
+	res, err := m.solanaClient.RpcClient.GetBlockHeightWithConfig(ctx, rpc.GetBlockHeightConfig{
+		Commitment: m.config.commitment(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := res.GetError(); err != nil {
+		return 0, err
+	}
+
+	return res.Result, nil
+}
+
+// formatTransactionError renders the transaction error returned by the RPC,
+// e.g. {"InstructionError":[1,{"Custom":1}]}.
+func formatTransactionError(txErr any) string {
+	switch v := txErr.(type) {
+	case string:
+		return v
+	case map[string]any:
+		if instructionError, ok := v["InstructionError"].([]any); ok && len(instructionError) == 2 {
+			return fmt.Sprintf("instruction %v: %s", instructionError[0], formatInstructionError(instructionError[1]))
+		}
+	}
+
+	data, err := json.Marshal(txErr)
+	if err != nil {
+		return fmt.Sprint(txErr)
+	}
+
+	return string(data)
+}
+
+func formatInstructionError(instructionError any) string {
+	switch v := instructionError.(type) {
+	case string:
+		return v
+	case map[string]any:
+		if code, ok := v["Custom"].(float64); ok {
+			return fmt.Sprintf("custom program error 0x%x", uint32(code))
+		}
+	}
+
+	data, err := json.Marshal(instructionError)
+	if err != nil {
+		return fmt.Sprint(instructionError)
+	}
+
+	return string(data)
+}
@@ -0,0 +1,82 @@
+package solana
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/logger"
+)
+
+func Test_commitmentReached(t *testing.T) {
+	processed, confirmed, finalized := rpc.CommitmentProcessed, rpc.CommitmentConfirmed, rpc.CommitmentFinalized
+
+	require.False(t, commitmentReached(nil, rpc.CommitmentProcessed))
+	require.True(t, commitmentReached(&processed, rpc.CommitmentProcessed))
+	require.False(t, commitmentReached(&processed, rpc.CommitmentConfirmed))
+	require.True(t, commitmentReached(&finalized, rpc.CommitmentConfirmed))
+	require.False(t, commitmentReached(&confirmed, rpc.CommitmentFinalized))
+}
+
+func Test_formatTransactionError(t *testing.T) {
+	require.Equal(t, "instruction 1: custom program error 0x1", formatTransactionError(map[string]any{
+		"InstructionError": []any{float64(1), map[string]any{"Custom": float64(1)}},
+	}))
+	require.Equal(t, "instruction 0: InvalidAccountData", formatTransactionError(map[string]any{
+		"InstructionError": []any{float64(0), "InvalidAccountData"},
+	}))
+	require.Equal(t, "BlockhashNotFound", formatTransactionError("BlockhashNotFound"))
+	require.Equal(t, `{"InsufficientFundsForRent":{"account_index":2}}`, formatTransactionError(map[string]any{
+		"InsufficientFundsForRent": map[string]any{"account_index": float64(2)},
+	}))
+}
+
+func Test_ConfirmationResultErr(t *testing.T) {
+	require.NoError(t, (&ConfirmationResult{Status: ConfirmationConfirmed}).Err())
+	require.ErrorContains(t, (&ConfirmationResult{Signature: "sig", Status: ConfirmationFailed, Error: "boom"}).Err(), "sig failed: boom")
+	require.ErrorContains(t, (&ConfirmationResult{Signature: "sig", Status: ConfirmationExpired}).Err(), "expired")
+}
+
+func Test_checkBlockhashExpiry(t *testing.T) {
+	var statusErr bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int    `json:"id"`
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		res := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch {
+		case req.Method == "getBlockHeight":
+			res["result"] = 200
+		case statusErr:
+			res["error"] = map[string]any{"code": -32000, "message": "node is behind"}
+		default:
+			res["result"] = map[string]any{"context": map[string]any{"slot": 1}, "value": []any{nil}}
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	defer server.Close()
+
+	m := &Module{
+		config:       &config{ConfirmCommitment: "confirmed"},
+		log:          logger.New("test"),
+		solanaClient: client.NewClient(server.URL),
+	}
+	tx := &signedTransaction{Signature: "signature", LastValidBlockHeight: 100}
+
+	res := m.checkBlockhashExpiry(context.Background(), tx)
+	require.NotNil(t, res)
+	require.Equal(t, ConfirmationExpired, res.Status)
+
+	// A failed status lookup does not prove the transaction did not land.
+	statusErr = true
+	require.Nil(t, m.checkBlockhashExpiry(context.Background(), tx))
+}
@@ -0,0 +1,244 @@
+package solana
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+const (
+	// ContactPrefix marks a label where an address is expected: @exchange.
+	ContactPrefix = "@"
+	// AssetSOL keys SOL limits, token limits are keyed by mint.
+	AssetSOL = "SOL"
+
+	knownAddressContacts = "address book"
+)
+
+var contactLabelPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+type Contact struct {
+	Label   string `json:"label"`
+	Address string `json:"address"`
+	Note    string `json:"note,omitempty"`
+	// DefaultToken is the mint sent when a token transfer names none.
+	DefaultToken string `json:"default_token,omitempty"`
+	// Limits caps a single transfer per asset, SOL in lamports and tokens
+	// in base units.
+	Limits map[string]uint64 `json:"limits,omitempty"`
+}
+
+// AddContact stores a new labelled address in the address book.
+func (m *Module) AddContact(ctx context.Context, contact *Contact) error {
+	_, span := tracer.Start(ctx, "internal.solana.AddContact")
+	defer span.End()
+
+	if !contactLabelPattern.MatchString(contact.Label) {
+		return errorf(ErrorKindInvalidInput, "invalid label %q: use letters, digits, '_', '.' and '-'", contact.Label)
+	}
+	if _, err := ParseAddress(contact.Address); err != nil {
+		return err
+	}
+	if contact.DefaultToken != "" {
+		if _, err := ParseAddress(contact.DefaultToken); err != nil {
+			return errors.Wrap(err, "invalid default token")
+		}
+	}
+	for asset := range contact.Limits {
+		if asset == AssetSOL {
+			continue
+		}
+		if _, err := ParseAddress(asset); err != nil {
+			return errors.Wrap(err, "invalid limit mint")
+		}
+	}
+
+	contacts, err := loadContacts(m.config.ContactsFilename)
+	if err != nil {
+		return err
+	}
+	for _, existing := range contacts {
+		if existing.Label == contact.Label {
+			return errorf(ErrorKindInvalidInput, "contact @%s already exists", contact.Label)
+		}
+	}
+
+	return saveContacts(m.config.ContactsFilename, append(contacts, contact))
+}
+
+// ListContacts returns the address book sorted by label.
+func (m *Module) ListContacts(ctx context.Context) ([]*Contact, error) {
+	_, span := tracer.Start(ctx, "internal.solana.ListContacts")
+	defer span.End()
+
+	return loadContacts(m.config.ContactsFilename)
+}
+
+func (m *Module) RemoveContact(ctx context.Context, label string) error {
+	_, span := tracer.Start(ctx, "internal.solana.RemoveContact")
+	defer span.End()
+
+	label = strings.TrimPrefix(label, ContactPrefix)
+
+	contacts, err := loadContacts(m.config.ContactsFilename)
+	if err != nil {
+		return err
+	}
+	for i, contact := range contacts {
+		if contact.Label == label {
+			return saveContacts(m.config.ContactsFilename, append(contacts[:i], contacts[i+1:]...))
+		}
+	}
+
+	return errorf(ErrorKindInvalidInput, "contact @%s not found", label)
+}
+
+// Contact finds a contact by its label, with or without the @ prefix.
+func (m *Module) Contact(ctx context.Context, label string) (*Contact, error) {
+	_, span := tracer.Start(ctx, "internal.solana.Contact")
+	defer span.End()
+
+	contacts, err := loadContacts(m.config.ContactsFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	label = strings.TrimPrefix(label, ContactPrefix)
+	for _, contact := range contacts {
+		if contact.Label == label {
+			return contact, nil
+		}
+	}
+
+	return nil, errorf(ErrorKindInvalidInput, "contact @%s not found", label)
+}
+
+// ContactByAddress finds the contact of an address, nil when there is none.
+func (m *Module) ContactByAddress(ctx context.Context, address string) (*Contact, error) {
+	_, span := tracer.Start(ctx, "internal.solana.ContactByAddress")
+	defer span.End()
+
+	contacts, err := loadContacts(m.config.ContactsFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, contact := range contacts {
+		if contact.Address == address {
+			return contact, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ResolveAddress turns an @label into the contact's address and returns
+// anything else unchanged.
+func (m *Module) ResolveAddress(ctx context.Context, address string) (string, error) {
+	_, span := tracer.Start(ctx, "internal.solana.ResolveAddress")
+	defer span.End()
+
+	if !strings.HasPrefix(address, ContactPrefix) {
+		return address, nil
+	}
+
+	contact, err := m.Contact(ctx, address)
+	if err != nil {
+		return "", err
+	}
+
+	return contact.Address, nil
+}
+
+// contactLabels maps addresses to their labels.
+func (m *Module) contactLabels() (map[string]string, error) {
+	contacts, err := loadContacts(m.config.ContactsFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string, len(contacts))
+	for _, contact := range contacts {
+		res[contact.Address] = contact.Label
+	}
+
+	return res, nil
+}
+
+// checkContactLimits refuses transfers above the recipient contact's limit
+// for the asset. Addresses outside the address book have no limits.
+func (m *Module) checkContactLimits(asset string, transfers map[string]uint64) error {
+	contacts, err := loadContacts(m.config.ContactsFilename)
+	if err != nil {
+		return err
+	}
+
+	for address, amount := range transfers {
+		if err := checkContactLimit(contacts, address, asset, amount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkContactLimit(contacts []*Contact, address, asset string, amount uint64) error {
+	for _, contact := range contacts {
+		if contact.Address != address {
+			continue
+		}
+
+		if limit, ok := contact.Limits[asset]; ok && amount > limit {
+			return errorf(ErrorKindInvalidInput, "transfer of %d %s to @%s exceeds its limit of %d", amount, asset, contact.Label, limit)
+		}
+	}
+
+	return nil
+}
+
+// loadContacts reads the address book, a missing file being an empty one.
+func loadContacts(filename string) ([]*Contact, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read contacts file")
+	}
+
+	var res []*Contact
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, errors.Wrap(err, "unmarshal contacts file")
+	}
+
+	return res, nil
+}
+
+func saveContacts(filename string, contacts []*Contact) error {
+	sort.Slice(contacts, func(i, j int) bool {
+		return contacts[i].Label < contacts[j].Label
+	})
+
+	data, err := json.MarshalIndent(contacts, "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "marshal contacts")
+	}
+
+	tmpFilename := filename + ".tmp"
+	if err := os.WriteFile(tmpFilename, data, 0600); err != nil {
+		return errors.Wrap(err, "write contacts file")
+	}
+
+	if err := os.Rename(tmpFilename, filename); err != nil {
+		return errors.Wrap(err, "replace contacts file")
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package solana
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Contacts(t *testing.T) {
+	ctx := context.Background()
+	m := &Module{config: &config{ContactsFilename: filepath.Join(t.TempDir(), "contacts.json")}}
+
+	contacts, err := m.ListContacts(ctx)
+	require.NoError(t, err)
+	require.Empty(t, contacts)
+
+	exchange := types.NewAccount().PublicKey.ToBase58()
+	partner := types.NewAccount().PublicKey.ToBase58()
+	require.NoError(t, m.AddContact(ctx, &Contact{Label: "exchange", Address: exchange, Limits: map[string]uint64{AssetSOL: 100}}))
+	require.NoError(t, m.AddContact(ctx, &Contact{Label: "partner", Address: partner, Note: "monthly invoice"}))
+
+	require.ErrorContains(t, m.AddContact(ctx, &Contact{Label: "exchange", Address: partner}), "already exists")
+	require.Error(t, m.AddContact(ctx, &Contact{Label: "bad label", Address: partner}))
+	require.Error(t, m.AddContact(ctx, &Contact{Label: "typo", Address: "not-an-address"}))
+
+	address, err := m.ResolveAddress(ctx, "@partner")
+	require.NoError(t, err)
+	require.Equal(t, partner, address)
+
+	address, err = m.ResolveAddress(ctx, exchange)
+	require.NoError(t, err)
+	require.Equal(t, exchange, address)
+
+	_, err = m.ResolveAddress(ctx, "@unknown")
+	require.ErrorContains(t, err, "not found")
+
+	require.NoError(t, m.checkContactLimits(AssetSOL, map[string]uint64{exchange: 100, partner: 1000}))
+	require.ErrorContains(t, m.checkContactLimits(AssetSOL, map[string]uint64{exchange: 101}), "exceeds its limit")
+
+	// A contact saved under a token account, such as an exchange deposit
+	// account, keeps its limits when the account is the recipient.
+	depositOwner := types.NewAccount().PublicKey.ToBase58()
+	deposit := &Recipient{Address: exchange, Kind: RecipientTokenAccount, TokenOwner: depositOwner}
+	require.ErrorContains(t, m.checkContactLimits(AssetSOL, deposit.limitTransfers(101)), "@exchange exceeds its limit")
+	owned := &Recipient{Address: depositOwner, Kind: RecipientTokenAccount, TokenOwner: exchange}
+	require.ErrorContains(t, m.checkContactLimits(AssetSOL, owned.limitTransfers(101)), "@exchange exceeds its limit")
+
+	require.NoError(t, m.RemoveContact(ctx, "@exchange"))
+	require.Error(t, m.RemoveContact(ctx, "exchange"))
+
+	labels, err := m.contactLabels()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{partner: "partner"}, labels)
+}
@@ -0,0 +1,102 @@
+package solana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+// Digital Asset Standard (DAS) API types, limited to the fields used for
+// compressed NFT transfers.
+
+type dasAsset struct {
+	ID          string `json:"id"`
+	Compression struct {
+		Compressed  bool   `json:"compressed"`
+		DataHash    string `json:"data_hash"`
+		CreatorHash string `json:"creator_hash"`
+		Tree        string `json:"tree"`
+		LeafID      uint64 `json:"leaf_id"`
+	} `json:"compression"`
+	Ownership struct {
+		Owner    string  `json:"owner"`
+		Delegate *string `json:"delegate"`
+	} `json:"ownership"`
+}
+
+type dasAssetProof struct {
+	Root      string   `json:"root"`
+	Proof     []string `json:"proof"`
+	NodeIndex uint64   `json:"node_index"`
+	Leaf      string   `json:"leaf"`
+	TreeID    string   `json:"tree_id"`
+}
+
+type dasResponse[T any] struct {
+	Result *T `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// dasCall performs a DAS JSON-RPC request. DAS methods take named params,
+// which the SDK RPC client does not support.
+func dasCall[T any](ctx context.Context, url string, method string, params any) (*T, error) {
+	_, span := tracer.Start(ctx, "internal.solana.dasCall")
+	defer span.End()
+
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal DAS request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "create DAS request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "send DAS request")
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read DAS response")
+	}
+
+	var resp dasResponse[T]
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal DAS response (status %d)", res.StatusCode)
+	}
+	if resp.Error != nil {
+		return nil, errors.Errorf("DAS %s: %s (code %d)", method, resp.Error.Message, resp.Error.Code)
+	}
+	if resp.Result == nil {
+		return nil, errors.Errorf("DAS %s: empty result", method)
+	}
+
+	return resp.Result, nil
+}
+
+func (m *Module) getAsset(ctx context.Context, assetID string) (*dasAsset, error) {
+	return dasCall[dasAsset](ctx, m.config.dasApiUrl(), "getAsset", map[string]string{"id": assetID})
+}
+
+func (m *Module) getAssetProof(ctx context.Context, assetID string) (*dasAssetProof, error) {
+	return dasCall[dasAssetProof](ctx, m.config.dasApiUrl(), "getAssetProof", map[string]string{"id": assetID})
+}
@@ -0,0 +1,266 @@
+package solana
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/pkg/errors"
+)
+
+// ErrorKind groups failures by what the caller can do about them.
+type ErrorKind int
+
+const (
+	ErrorKindUnknown ErrorKind = iota
+	// ErrorKindInvalidInput is a request that cannot succeed as given.
+	ErrorKindInvalidInput
+	ErrorKindInsufficientFunds
+	// ErrorKindRPC is a node that failed to answer or returned an error.
+	ErrorKindRPC
+	// ErrorKindTransactionFailed is a transaction rejected by the cluster,
+	// in simulation or on chain.
+	ErrorKindTransactionFailed
+	// ErrorKindTimeout is a deadline hit or a transaction that expired
+	// before it was confirmed.
+	ErrorKindTimeout
+	// ErrorKindInterrupted is an operation cancelled by a signal.
+	ErrorKindInterrupted
+)
+
+// Error attaches a kind to an error of the module.
+type Error struct {
+	kind ErrorKind
+	err  error
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+func (e *Error) Kind() ErrorKind {
+	return e.kind
+}
+
+func errorf(kind ErrorKind, format string, args ...any) error {
+	return &Error{kind: kind, err: errors.Errorf(format, args...)}
+}
+
+// WithKind attaches a kind to err, for callers classifying their own errors.
+func WithKind(kind ErrorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{kind: kind, err: err}
+}
+
+// rpcSimulationFailed is the JSON-RPC error code of a transaction failing
+// preflight simulation.
+const rpcSimulationFailed = -32002
+
+// rpcErrorPrefix starts the errors of SDK calls that did not get a JSON-RPC
+// answer. The SDK formats the HTTP error into the message instead of wrapping
+// it.
+const rpcErrorPrefix = "rpc: "
+
+// KindOf returns the kind of the first error in the chain that has one, and
+// classifies RPC and deadline errors of the SDK otherwise.
+func KindOf(err error) ErrorKind {
+	if err == nil {
+		return ErrorKindUnknown
+	}
+
+	var kinded interface{ Kind() ErrorKind }
+	if errors.As(err, &kinded) {
+		return kinded.Kind()
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorKindInterrupted
+	}
+
+	var rpcErr *rpc.JsonRpcError
+	if errors.As(err, &rpcErr) {
+		if rpcErr.Code == rpcSimulationFailed {
+			return ErrorKindTransactionFailed
+		}
+
+		return ErrorKindRPC
+	}
+
+	if cause := errors.Cause(err).Error(); strings.HasPrefix(cause, rpcErrorPrefix) {
+		if strings.Contains(cause, context.DeadlineExceeded.Error()) {
+			return ErrorKindTimeout
+		}
+		if strings.Contains(cause, context.Canceled.Error()) {
+			return ErrorKindInterrupted
+		}
+
+		return ErrorKindRPC
+	}
+
+	return ErrorKindUnknown
+}
+
+// InsufficientBalanceError is an account without enough SOL or tokens for an
+// operation and its fees.
+type InsufficientBalanceError struct {
+	Address string
+	// Asset is "SOL" or the mint of the token.
+	Asset   string
+	Purpose string
+	// Have and Need are lamports for SOL and base units for tokens.
+	Have uint64
+	Need uint64
+}
+
+func (e *InsufficientBalanceError) Error() string {
+	unit := "lamports"
+	if e.Asset != AssetSOL {
+		unit = "base units"
+	}
+
+	return fmt.Sprintf("insufficient %s balance of %s for %s: have %d, need %d %s", e.Asset, e.Address, e.Purpose, e.Have, e.Need, unit)
+}
+
+func (e *InsufficientBalanceError) Kind() ErrorKind {
+	return ErrorKindInsufficientFunds
+}
+
+// AccountNotFoundError is an address with no account on chain.
+type AccountNotFoundError struct {
+	Address string
+	// What the account was expected to be, such as "mint" or "stake".
+	What string
+}
+
+func (e *AccountNotFoundError) Error() string {
+	if e.What == "" {
+		return fmt.Sprintf("account %s not found", e.Address)
+	}
+
+	return fmt.Sprintf("%s account %s not found", e.What, e.Address)
+}
+
+func (e *AccountNotFoundError) Kind() ErrorKind {
+	return ErrorKindInvalidInput
+}
+
+// InvalidMintError is an account that exists but is not a token mint.
+type InvalidMintError struct {
+	Address string
+	Reason  string
+}
+
+func (e *InvalidMintError) Error() string {
+	return fmt.Sprintf("%s is not a valid mint: %s", e.Address, e.Reason)
+}
+
+func (e *InvalidMintError) Kind() ErrorKind {
+	return ErrorKindInvalidInput
+}
+
+// TransactionExpiredError is a transaction whose blockhash expired before it
+// was confirmed. It may not have landed, so it is safe to send again.
+type TransactionExpiredError struct {
+	Signature string
+}
+
+func (e *TransactionExpiredError) Error() string {
+	return fmt.Sprintf("transaction %s expired before it was confirmed", e.Signature)
+}
+
+func (e *TransactionExpiredError) Kind() ErrorKind {
+	return ErrorKindTimeout
+}
+
+// InterruptedError is a transaction whose sending or confirmation was cut
+// short by a signal or a deadline. A sent transaction may still land, its
+// signature tells where to look for it.
+type InterruptedError struct {
+	Signature string
+	Sent      bool
+
+	err error
+}
+
+func (e *InterruptedError) Error() string {
+	if e.Sent {
+		return fmt.Sprintf("interrupted after sending transaction %s, it may still land: %v", e.Signature, e.err)
+	}
+
+	return fmt.Sprintf("interrupted before sending a transaction: %v", e.err)
+}
+
+func (e *InterruptedError) Unwrap() error {
+	return e.err
+}
+
+func (e *InterruptedError) Kind() ErrorKind {
+	if errors.Is(e.err, context.DeadlineExceeded) {
+		return ErrorKindTimeout
+	}
+
+	return ErrorKindInterrupted
+}
+
+// ProgramError is an instruction rejected by a program, with the custom
+// error code decoded for the System, SPL Token, Associated Token Account and
+// Token Metadata programs.
+type ProgramError struct {
+	// Signature is empty for errors from simulation.
+	Signature   string `json:"signature,omitempty"`
+	Instruction int    `json:"instruction"`
+	ProgramID   string `json:"program_id,omitempty"`
+	// Program names a known program.
+	Program string  `json:"program,omitempty"`
+	Code    *uint32 `json:"code,omitempty"`
+	Name    string  `json:"name,omitempty"`
+	Message string  `json:"message"`
+
+	insufficientFunds bool
+}
+
+// Detail describes the failing instruction without the signature.
+func (e *ProgramError) Detail() string {
+	program := e.Program
+	if program == "" {
+		program = e.ProgramID
+	}
+
+	res := fmt.Sprintf("instruction %d", e.Instruction)
+	if program != "" {
+		res += fmt.Sprintf(" (%s)", program)
+	}
+	res += ": " + e.Message
+	if e.Code != nil && e.Name != "" {
+		res += fmt.Sprintf(" (%s, custom program error 0x%x)", e.Name, *e.Code)
+	}
+
+	return res
+}
+
+func (e *ProgramError) Error() string {
+	if e.Signature == "" {
+		return "transaction simulation failed: " + e.Detail()
+	}
+
+	return fmt.Sprintf("transaction %s failed: %s", e.Signature, e.Detail())
+}
+
+func (e *ProgramError) Kind() ErrorKind {
+	if e.insufficientFunds {
+		return ErrorKindInsufficientFunds
+	}
+
+	return ErrorKindTransactionFailed
+}
@@ -0,0 +1,103 @@
+package solana
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{
+			name: "kind survives wrapping",
+			err:  errors.Wrap(errorf(ErrorKindInsufficientFunds, "insufficient balance"), "transfer"),
+			want: ErrorKindInsufficientFunds,
+		},
+		{
+			name: "confirmation deadline",
+			err:  errors.Wrap(context.DeadlineExceeded, "wait for confirmation"),
+			want: ErrorKindTimeout,
+		},
+		{
+			name: "preflight simulation failure",
+			err:  errors.Wrap(&rpc.JsonRpcError{Code: rpcSimulationFailed, Message: "Transaction simulation failed"}, "failed to send transaction"),
+			want: ErrorKindTransactionFailed,
+		},
+		{
+			name: "json-rpc error",
+			err:  errors.Wrap(&rpc.JsonRpcError{Code: -32005, Message: "Node is behind"}, "failed to get balance"),
+			want: ErrorKindRPC,
+		},
+		{
+			name: "http failure",
+			err:  errors.Wrap(fmt.Errorf("rpc: call error, err: failed to do request, err: connection refused, body: "), "failed to get balance"),
+			want: ErrorKindRPC,
+		},
+		{
+			name: "http deadline",
+			err:  errors.Wrap(fmt.Errorf("rpc: call error, err: failed to do request, err: Post \"url\": context deadline exceeded, body: "), "failed to get balance"),
+			want: ErrorKindTimeout,
+		},
+		{
+			name: "insufficient balance",
+			err:  errors.Wrap(&InsufficientBalanceError{Address: "addr", Asset: AssetSOL, Have: 1, Need: 2}, "transfer"),
+			want: ErrorKindInsufficientFunds,
+		},
+		{
+			name: "expired transaction",
+			err:  &TransactionExpiredError{Signature: "sig"},
+			want: ErrorKindTimeout,
+		},
+		{
+			name: "token insufficient funds program error",
+			err:  &ProgramError{Program: "token", Name: "InsufficientFunds", insufficientFunds: true},
+			want: ErrorKindInsufficientFunds,
+		},
+		{
+			name: "program error",
+			err:  errors.Wrap(&ProgramError{Program: "token", Name: "OwnerMismatch"}, "send"),
+			want: ErrorKindTransactionFailed,
+		},
+		{
+			name: "interrupted by a signal",
+			err:  errors.Wrap(&InterruptedError{Signature: "sig", Sent: true, err: context.Canceled}, "transfer"),
+			want: ErrorKindInterrupted,
+		},
+		{
+			name: "interrupted by the deadline",
+			err:  &InterruptedError{err: context.DeadlineExceeded},
+			want: ErrorKindTimeout,
+		},
+		{
+			name: "http request cancelled",
+			err:  errors.Wrap(fmt.Errorf("rpc: call error, err: failed to do request, err: Post \"url\": context canceled, body: "), "failed to get balance"),
+			want: ErrorKindInterrupted,
+		},
+		{
+			name: "unclassified",
+			err:  errors.New("read key file"),
+			want: ErrorKindUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, KindOf(tt.err))
+		})
+	}
+}
+
+func TestInterruptedError(t *testing.T) {
+	require.Equal(t, "interrupted after sending transaction sig, it may still land: context canceled",
+		(&InterruptedError{Signature: "sig", Sent: true, err: context.Canceled}).Error())
+	require.Equal(t, "interrupted before sending a transaction: context deadline exceeded",
+		(&InterruptedError{err: context.DeadlineExceeded}).Error())
+	require.ErrorIs(t, &InterruptedError{err: context.Canceled}, context.Canceled)
+}
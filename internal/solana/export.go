@@ -0,0 +1,362 @@
+package solana
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+type ExportRequest struct {
+	// OwnerKeyFilenames and Addresses are the wallets exported together.
+	OwnerKeyFilenames []string
+	Addresses         []string
+	// StateFilename keeps the last exported signature and the running token
+	// balances of every wallet, so the next export only adds new rows.
+	StateFilename string
+}
+
+// LedgerRow is the movement of one asset of one wallet in one transaction.
+// Amounts and balances are formatted with the asset's decimals.
+type LedgerRow struct {
+	Wallet            string    `json:"wallet"`
+	WalletLabel       string    `json:"wallet_label,omitempty"`
+	Time              time.Time `json:"time"`
+	Signature         string    `json:"signature"`
+	Status            string    `json:"status"`
+	Counterparty      string    `json:"counterparty,omitempty"`
+	CounterpartyLabel string    `json:"counterparty_label,omitempty"`
+	Asset             string    `json:"asset"`
+	Symbol            string    `json:"symbol,omitempty"`
+	AmountIn          string    `json:"amount_in"`
+	AmountOut         string    `json:"amount_out"`
+	Fee               string    `json:"fee"`
+	Memo              string    `json:"memo,omitempty"`
+	Balance           string    `json:"balance"`
+}
+
+type exportState struct {
+	Wallets map[string]*exportWalletState `json:"wallets"`
+}
+
+type exportWalletState struct {
+	LastSignature string `json:"last_signature"`
+	// TokenBalances are raw amounts by mint. SOL balances come from the
+	// transactions themselves.
+	TokenBalances map[string]uint64 `json:"token_balances,omitempty"`
+}
+
+// ExportLedger collects the movements of the wallets since the last export,
+// oldest first, and hands them to write. The state is only saved once write
+// succeeds, so a failed export is simply repeated.
+func (m *Module) ExportLedger(ctx context.Context, req *ExportRequest, write func(rows []*LedgerRow) error) error {
+	_, span := tracer.Start(ctx, "internal.solana.ExportLedger")
+	defer span.End()
+
+	state, err := loadExportState(req.StateFilename)
+	if err != nil {
+		return err
+	}
+
+	labels, err := m.contactLabels()
+	if err != nil {
+		return err
+	}
+
+	addresses := append([]string{}, req.Addresses...)
+	for _, keyFilename := range req.OwnerKeyFilenames {
+		ownerAccount, err := loadFromKeyFile(ctx, keyFilename)
+		if err != nil {
+			return errors.Wrap(err, "failed to load owner account")
+		}
+		addresses = append(addresses, ownerAccount.PublicKey.ToBase58())
+	}
+	if len(addresses) == 0 {
+		return errorf(ErrorKindInvalidInput, "no wallets to export")
+	}
+
+	var rows []*LedgerRow
+	for _, address := range addresses {
+		if _, err := ParseAddress(address); err != nil {
+			return err
+		}
+
+		walletState := state.Wallets[address]
+		if walletState == nil {
+			walletState = &exportWalletState{}
+			state.Wallets[address] = walletState
+		}
+		if walletState.TokenBalances == nil {
+			walletState.TokenBalances = map[string]uint64{}
+		}
+
+		walletRows, err := m.exportWallet(ctx, address, walletState, labels)
+		if err != nil {
+			return errors.Wrapf(err, "export %s", address)
+		}
+		m.log.Info(ctx, "exported wallet", address, "rows", len(walletRows))
+		rows = append(rows, walletRows...)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Time.Before(rows[j].Time)
+	})
+
+	if err := write(rows); err != nil {
+		return err
+	}
+
+	return state.save(req.StateFilename)
+}
+
+func (m *Module) exportWallet(ctx context.Context, address string, state *exportWalletState, labels map[string]string) ([]*LedgerRow, error) {
+	signatures, err := m.signaturesSince(ctx, address, state.LastSignature)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		entries      []*HistoryEntry
+		transactions []*client.Transaction
+	)
+	// Oldest first, for the running balances.
+	for i := len(signatures) - 1; i >= 0; i-- {
+		tx, err := m.solanaClient.GetTransactionWithConfig(ctx, signatures[i].Signature, client.GetTransactionConfig{
+			Commitment: rpc.CommitmentConfirmed,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get transaction %s", signatures[i].Signature)
+		}
+		if tx == nil {
+			continue
+		}
+
+		entries = append(entries, decodeHistoryEntry(address, signatures[i].Signature, tx))
+		transactions = append(transactions, tx)
+	}
+
+	if err := m.describeHistory(ctx, entries, labels); err != nil {
+		return nil, err
+	}
+
+	var res []*LedgerRow
+	for i, entry := range entries {
+		rows := ledgerRows(address, entry, transactions[i], state)
+		for _, row := range rows {
+			row.WalletLabel = labels[address]
+		}
+		res = append(res, rows...)
+	}
+	if len(signatures) > 0 {
+		state.LastSignature = signatures[0].Signature
+	}
+
+	return res, nil
+}
+
+// signaturesSince pages back to the given signature, newest first.
+func (m *Module) signaturesSince(ctx context.Context, address, until string) (rpc.GetSignaturesForAddress, error) {
+	var (
+		res    rpc.GetSignaturesForAddress
+		before string
+	)
+	for {
+		signatures, err := m.solanaClient.GetSignaturesForAddressWithConfig(ctx, address, client.GetSignaturesForAddressConfig{
+			Limit:      historyPageSize,
+			Before:     before,
+			Until:      until,
+			Commitment: rpc.CommitmentConfirmed,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get signatures")
+		}
+
+		res = append(res, signatures...)
+		if len(signatures) < historyPageSize {
+			return res, nil
+		}
+		before = signatures[len(signatures)-1].Signature
+	}
+}
+
+// ledgerRows turns the balance changes of the transaction into rows: the
+// exact pre and post balances of the meta are used rather than the decoded
+// instructions, so nothing an unknown program moved is missed. The decoded
+// actions only provide counterparties.
+func ledgerRows(address string, entry *HistoryEntry, tx *client.Transaction, state *exportWalletState) []*LedgerRow {
+	if tx.Meta == nil {
+		return nil
+	}
+
+	newRow := func(asset string) *LedgerRow {
+		row := &LedgerRow{
+			Wallet:    address,
+			Time:      entry.Time,
+			Signature: entry.Signature,
+			Status:    entry.Status,
+			Asset:     asset,
+			Memo:      strings.Join(entry.Memos, "; "),
+		}
+		for _, action := range entry.Actions {
+			if action.Asset != asset {
+				continue
+			}
+			if row.Symbol == "" {
+				row.Symbol = action.Symbol
+			}
+			if row.Counterparty == "" {
+				row.Counterparty = action.Counterparty
+				row.CounterpartyLabel = action.CounterpartyLabel
+			}
+		}
+		if asset == AssetSOL {
+			row.Symbol = AssetSOL
+		}
+
+		return row
+	}
+
+	var res []*LedgerRow
+
+	for i, account := range tx.AccountKeys {
+		if account.ToBase58() != address || i >= len(tx.Meta.PreBalances) || i >= len(tx.Meta.PostBalances) {
+			continue
+		}
+
+		var fee uint64
+		if entry.FeePayer {
+			fee = entry.Fee
+		}
+		// The fee has its own column, the amount is what moved besides it.
+		change := tx.Meta.PostBalances[i] - tx.Meta.PreBalances[i] + int64(fee)
+		if change == 0 && fee == 0 {
+			break
+		}
+
+		row := newRow(AssetSOL)
+		row.AmountIn, row.AmountOut = ledgerAmounts(change, solDecimals)
+		row.Fee = formatTokenAmount(fee, solDecimals)
+		row.Balance = formatTokenAmount(uint64(tx.Meta.PostBalances[i]), solDecimals)
+		res = append(res, row)
+
+		break
+	}
+
+	type tokenChange struct {
+		pre, post uint64
+		decimals  uint8
+	}
+	changes := map[string]*tokenChange{}
+	collect := func(balances []rpc.TransactionMetaTokenBalance, post bool) {
+		for _, balance := range balances {
+			if balance.Owner != address {
+				continue
+			}
+			amount, err := strconv.ParseUint(balance.UITokenAmount.Amount, 10, 64)
+			if err != nil {
+				continue
+			}
+
+			change := changes[balance.Mint]
+			if change == nil {
+				change = &tokenChange{decimals: balance.UITokenAmount.Decimals}
+				changes[balance.Mint] = change
+			}
+			if post {
+				change.post += amount
+			} else {
+				change.pre += amount
+			}
+		}
+	}
+	collect(tx.Meta.PreTokenBalances, false)
+	collect(tx.Meta.PostTokenBalances, true)
+
+	mints := make([]string, 0, len(changes))
+	for mint := range changes {
+		mints = append(mints, mint)
+	}
+	sort.Strings(mints)
+
+	for _, mint := range mints {
+		change := changes[mint]
+		if change.post == change.pre {
+			continue
+		}
+
+		balance := state.TokenBalances[mint]
+		if change.post > change.pre {
+			balance += change.post - change.pre
+		} else if diff := change.pre - change.post; diff <= balance {
+			balance -= diff
+		} else {
+			// History before the first export is missing.
+			balance = 0
+		}
+		state.TokenBalances[mint] = balance
+
+		row := newRow(mint)
+		row.AmountIn, row.AmountOut = ledgerAmounts(int64(change.post)-int64(change.pre), change.decimals)
+		row.Fee = "0"
+		row.Balance = formatTokenAmount(balance, change.decimals)
+		res = append(res, row)
+	}
+
+	return res
+}
+
+func ledgerAmounts(change int64, decimals uint8) (string, string) {
+	if change < 0 {
+		return "0", formatTokenAmount(uint64(-change), decimals)
+	}
+
+	return formatTokenAmount(uint64(change), decimals), "0"
+}
+
+func loadExportState(filename string) (*exportState, error) {
+	res := &exportState{Wallets: map[string]*exportWalletState{}}
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read export state file")
+	}
+
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, errors.Wrap(err, "unmarshal export state file")
+	}
+	if res.Wallets == nil {
+		res.Wallets = map[string]*exportWalletState{}
+	}
+
+	return res, nil
+}
+
+func (s *exportState) save(filename string) error {
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "marshal export state")
+	}
+
+	tmpFilename := filename + ".tmp"
+	if err := os.WriteFile(tmpFilename, data, 0600); err != nil {
+		return errors.Wrap(err, "write export state file")
+	}
+
+	if err := os.Rename(tmpFilename, filename); err != nil {
+		return errors.Wrap(err, "replace export state file")
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package solana
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ledgerRows(t *testing.T) {
+	wallet := types.NewAccount().PublicKey.ToBase58()
+	other := types.NewAccount().PublicKey.ToBase58()
+	mint := types.NewAccount().PublicKey.ToBase58()
+
+	tx := &client.Transaction{
+		AccountKeys: []common.PublicKey{common.PublicKeyFromString(wallet), common.PublicKeyFromString(other)},
+		Meta: &client.TransactionMeta{
+			Fee:          5000,
+			PreBalances:  []int64{1000000000, 0},
+			PostBalances: []int64{899995000, 100000000},
+			PreTokenBalances: []rpc.TransactionMetaTokenBalance{
+				{AccountIndex: 2, Mint: mint, Owner: wallet, UITokenAmount: rpc.TokenAccountBalance{Amount: "1000", Decimals: 2}},
+			},
+			PostTokenBalances: []rpc.TransactionMetaTokenBalance{
+				{AccountIndex: 2, Mint: mint, Owner: wallet, UITokenAmount: rpc.TokenAccountBalance{Amount: "750", Decimals: 2}},
+			},
+		},
+	}
+	entry := &HistoryEntry{
+		Signature: "sig",
+		Time:      time.Unix(1700000000, 0).UTC(),
+		Status:    HistoryStatusSuccess,
+		Fee:       5000,
+		FeePayer:  true,
+		Memos:     []string{"invoice 42"},
+		Actions: []*HistoryAction{
+			{Asset: AssetSOL, Counterparty: other, CounterpartyLabel: "partner"},
+			{Asset: mint, Symbol: "EXMPL", Counterparty: other},
+		},
+	}
+	state := &exportWalletState{TokenBalances: map[string]uint64{mint: 1200}}
+
+	rows := ledgerRows(wallet, entry, tx, state)
+	require.Len(t, rows, 2)
+
+	sol := rows[0]
+	require.Equal(t, AssetSOL, sol.Asset)
+	require.Equal(t, "0", sol.AmountIn)
+	require.Equal(t, "0.1", sol.AmountOut)
+	require.Equal(t, "0.000005", sol.Fee)
+	require.Equal(t, "0.899995", sol.Balance)
+	require.Equal(t, "partner", sol.CounterpartyLabel)
+	require.Equal(t, "invoice 42", sol.Memo)
+
+	token := rows[1]
+	require.Equal(t, mint, token.Asset)
+	require.Equal(t, "EXMPL", token.Symbol)
+	require.Equal(t, "2.5", token.AmountOut)
+	require.Equal(t, "0", token.Fee)
+	require.Equal(t, "9.5", token.Balance)
+	require.Equal(t, uint64(950), state.TokenBalances[mint])
+}
+
+func Test_exportState(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "ledger.csv.state.json")
+
+	state, err := loadExportState(filename)
+	require.NoError(t, err)
+	require.Empty(t, state.Wallets)
+
+	state.Wallets["wallet"] = &exportWalletState{LastSignature: "sig", TokenBalances: map[string]uint64{"mint": 5}}
+	require.NoError(t, state.save(filename))
+
+	loaded, err := loadExportState(filename)
+	require.NoError(t, err)
+	require.Equal(t, state, loaded)
+}
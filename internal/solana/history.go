@@ -0,0 +1,272 @@
+package solana
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/metaplex/token_metadata"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+const (
+	historyPageSize = 100
+
+	HistoryStatusSuccess = "success"
+	HistoryStatusFailed  = "failed"
+)
+
+type HistoryRequest struct {
+	OwnerKeyFilename string
+	// Address is used instead of the key file's account when set.
+	Address string
+	// Limit caps the number of returned entries.
+	Limit int
+	// Before continues from a signature, the Next of a previous response.
+	Before string
+	// From and To bound the block time when set.
+	From, To time.Time
+	// Mint keeps transactions moving the asset, "SOL" or a token mint.
+	Mint string
+	// Direction keeps transactions with an incoming or outgoing movement.
+	Direction string
+}
+
+type HistoryEntry struct {
+	Signature string           `json:"signature"`
+	Time      time.Time        `json:"time"`
+	Slot      uint64           `json:"slot"`
+	Status    string           `json:"status"`
+	Error     string           `json:"error,omitempty"`
+	Fee       uint64           `json:"fee_lamports"`
+	FeePayer  bool             `json:"fee_payer"`
+	Memos     []string         `json:"memos,omitempty"`
+	Actions   []*HistoryAction `json:"actions"`
+}
+
+type HistoryResponse struct {
+	Address string          `json:"address"`
+	Label   string          `json:"label,omitempty"`
+	Entries []*HistoryEntry `json:"entries"`
+	// Next is passed as Before to get the following page.
+	Next string `json:"next,omitempty"`
+}
+
+// History pages through the signatures of an address, newest first, and
+// decodes every transaction matching the filters.
+func (m *Module) History(ctx context.Context, req *HistoryRequest) (*HistoryResponse, error) {
+	_, span := tracer.Start(ctx, "internal.solana.History")
+	defer span.End()
+
+	if req.Direction != "" && req.Direction != HistoryDirectionIn && req.Direction != HistoryDirectionOut {
+		return nil, errors.Errorf("unsupported direction %q", req.Direction)
+	}
+
+	address := req.Address
+	if address == "" {
+		ownerAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load owner account")
+		}
+		address = ownerAccount.PublicKey.ToBase58()
+	}
+	if _, err := ParseAddress(address); err != nil {
+		return nil, err
+	}
+
+	labels, err := m.contactLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &HistoryResponse{Address: address, Label: labels[address]}
+	before := req.Before
+	for req.Limit <= 0 || len(res.Entries) < req.Limit {
+		signatures, err := m.solanaClient.GetSignaturesForAddressWithConfig(ctx, address, client.GetSignaturesForAddressConfig{
+			Limit:      historyPageSize,
+			Before:     before,
+			Commitment: rpc.CommitmentConfirmed,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get signatures")
+		}
+
+		done, err := m.historyPage(ctx, req, address, signatures, res)
+		if err != nil {
+			return nil, err
+		}
+		// A short page ends the history only once all of it was read, the
+		// limit may have stopped the walk before its last signature.
+		pageRead := len(signatures) == 0 || res.Next == signatures[len(signatures)-1].Signature
+		if done || (pageRead && len(signatures) < historyPageSize) {
+			res.Next = ""
+			break
+		}
+		before = signatures[len(signatures)-1].Signature
+	}
+
+	if err := m.describeHistory(ctx, res.Entries, labels); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// historyPage adds the matching transactions of a page to the response and
+// reports whether the history before From has been reached.
+func (m *Module) historyPage(ctx context.Context, req *HistoryRequest, address string, signatures rpc.GetSignaturesForAddress, res *HistoryResponse) (bool, error) {
+	for _, signature := range signatures {
+		if req.Limit > 0 && len(res.Entries) >= req.Limit {
+			return false, nil
+		}
+		res.Next = signature.Signature
+
+		var blockTime time.Time
+		if signature.BlockTime != nil {
+			blockTime = time.Unix(*signature.BlockTime, 0).UTC()
+		}
+		if !req.To.IsZero() && blockTime.After(req.To) {
+			continue
+		}
+		if !req.From.IsZero() && !blockTime.IsZero() && blockTime.Before(req.From) {
+			return true, nil
+		}
+
+		tx, err := m.solanaClient.GetTransactionWithConfig(ctx, signature.Signature, client.GetTransactionConfig{
+			Commitment: rpc.CommitmentConfirmed,
+		})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get transaction %s", signature.Signature)
+		}
+		if tx == nil {
+			continue
+		}
+
+		entry := decodeHistoryEntry(address, signature.Signature, tx)
+		if entry.matches(req.Mint, req.Direction) {
+			res.Entries = append(res.Entries, entry)
+		}
+	}
+
+	return false, nil
+}
+
+func decodeHistoryEntry(address, signature string, tx *client.Transaction) *HistoryEntry {
+	res := &HistoryEntry{
+		Signature: signature,
+		Slot:      tx.Slot,
+		Status:    HistoryStatusSuccess,
+		FeePayer:  len(tx.AccountKeys) > 0 && tx.AccountKeys[0].ToBase58() == address,
+	}
+	if tx.BlockTime != nil {
+		res.Time = time.Unix(*tx.BlockTime, 0).UTC()
+	}
+	if tx.Meta != nil {
+		res.Fee = tx.Meta.Fee
+		if tx.Meta.Err != nil {
+			res.Status = HistoryStatusFailed
+			res.Error = describeTransactionError(tx.Meta.Err, messageProgramIDs(tx.Transaction.Message), tx.Meta.LogMessages)
+		}
+	}
+
+	res.Actions = decodeHistoryActions(newHistoryTransaction(address, tx), tx)
+	for _, action := range res.Actions {
+		if action.Type == "memo" {
+			res.Memos = append(res.Memos, action.Memo)
+		}
+	}
+
+	return res
+}
+
+func (e *HistoryEntry) matches(mint, direction string) bool {
+	if mint == "" && direction == "" {
+		return true
+	}
+
+	for _, action := range e.Actions {
+		if action.RawAmount == 0 && action.Amount == "" {
+			continue
+		}
+		if (mint == "" || action.Asset == mint) && (direction == "" || action.Direction == direction) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// describeHistory resolves token symbols from Metaplex metadata and contact
+// labels, then renders the descriptions.
+func (m *Module) describeHistory(ctx context.Context, entries []*HistoryEntry, labels map[string]string) error {
+	symbols, err := m.tokenSymbols(ctx, entries)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		for _, action := range entry.Actions {
+			if action.Asset != AssetSOL {
+				action.Symbol = symbols[action.Asset]
+			} else {
+				action.Symbol = AssetSOL
+			}
+			action.CounterpartyLabel = labels[action.Counterparty]
+			action.describe()
+		}
+	}
+
+	return nil
+}
+
+func (m *Module) tokenSymbols(ctx context.Context, entries []*HistoryEntry) (map[string]string, error) {
+	var (
+		mints     []string
+		metadata  []string
+		seenMints = map[string]bool{}
+	)
+	for _, entry := range entries {
+		for _, action := range entry.Actions {
+			if action.Asset == "" || action.Asset == AssetSOL || seenMints[action.Asset] {
+				continue
+			}
+			seenMints[action.Asset] = true
+
+			mint, err := ParseAddress(action.Asset)
+			if err != nil {
+				continue
+			}
+			metadataKey, err := token_metadata.GetTokenMetaPubkey(mint)
+			if err != nil {
+				return nil, errors.Wrap(err, "calculate metadata key")
+			}
+			mints = append(mints, action.Asset)
+			metadata = append(metadata, metadataKey.ToBase58())
+		}
+	}
+
+	accounts, err := m.getMultipleAccounts(ctx, metadata)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get token metadata")
+	}
+
+	res := make(map[string]string, len(mints))
+	for i, account := range accounts {
+		if account == nil || account.Owner != common.MetaplexTokenMetaProgramID {
+			continue
+		}
+
+		md, err := token_metadata.MetadataDeserialize(account.Data)
+		if err != nil {
+			continue
+		}
+		res[mints[i]] = strings.TrimRight(md.Data.Symbol, "\x00 ")
+	}
+
+	return res, nil
+}
@@ -0,0 +1,465 @@
+package solana
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+)
+
+const (
+	HistoryDirectionIn   = "in"
+	HistoryDirectionOut  = "out"
+	HistoryDirectionSelf = "self"
+
+	solDecimals = 9
+)
+
+// HistoryAction is one decoded instruction. Amounts are set for the
+// instructions moving SOL or tokens.
+type HistoryAction struct {
+	Program   string `json:"program"`
+	Type      string `json:"type"`
+	Direction string `json:"direction,omitempty"`
+	// Asset is "SOL" or the token mint.
+	Asset             string `json:"asset,omitempty"`
+	Symbol            string `json:"symbol,omitempty"`
+	RawAmount         uint64 `json:"raw_amount,omitempty"`
+	Decimals          uint8  `json:"decimals,omitempty"`
+	Amount            string `json:"amount,omitempty"`
+	From              string `json:"from,omitempty"`
+	To                string `json:"to,omitempty"`
+	Counterparty      string `json:"counterparty,omitempty"`
+	CounterpartyLabel string `json:"counterparty_label,omitempty"`
+	Memo              string `json:"memo,omitempty"`
+	Description       string `json:"description"`
+}
+
+// historyTransaction carries what decoding needs beyond the instruction:
+// the account keys and the owner and mint of every token account, taken from
+// the token balances of the transaction meta.
+type historyTransaction struct {
+	wallet        string
+	accounts      []common.PublicKey
+	tokenAccounts map[int]*historyTokenAccount
+}
+
+type historyTokenAccount struct {
+	Mint     string
+	Owner    string
+	Decimals uint8
+}
+
+func newHistoryTransaction(wallet string, tx *client.Transaction) *historyTransaction {
+	res := &historyTransaction{
+		wallet:        wallet,
+		accounts:      tx.AccountKeys,
+		tokenAccounts: map[int]*historyTokenAccount{},
+	}
+
+	if tx.Meta != nil {
+		for _, balances := range [][]rpc.TransactionMetaTokenBalance{tx.Meta.PreTokenBalances, tx.Meta.PostTokenBalances} {
+			for _, balance := range balances {
+				res.tokenAccounts[int(balance.AccountIndex)] = &historyTokenAccount{
+					Mint:     balance.Mint,
+					Owner:    balance.Owner,
+					Decimals: balance.UITokenAmount.Decimals,
+				}
+			}
+		}
+	}
+
+	return res
+}
+
+// decodeHistoryActions decodes the top-level instructions. Inner instructions
+// are only decoded for programs this decoder does not know, so a swap shows
+// its token movements while an ATA creation is not repeated as the system
+// and token instructions it is made of.
+func decodeHistoryActions(htx *historyTransaction, tx *client.Transaction) []*HistoryAction {
+	inner := map[int][]types.CompiledInstruction{}
+	if tx.Meta != nil {
+		for _, instructions := range tx.Meta.InnerInstructions {
+			inner[int(instructions.Index)] = instructions.Instructions
+		}
+	}
+
+	var res []*HistoryAction
+	for i, instruction := range tx.Transaction.Message.Instructions {
+		action, known := htx.decode(instruction)
+		if action != nil {
+			res = append(res, action)
+		}
+		if known {
+			continue
+		}
+
+		for _, innerInstruction := range inner[i] {
+			if action, known := htx.decode(innerInstruction); known && action != nil {
+				res = append(res, action)
+			}
+		}
+	}
+
+	return res
+}
+
+// decode returns nil for instructions not worth an entry, such as compute
+// budget ones, and false for programs it does not know.
+func (htx *historyTransaction) decode(instruction types.CompiledInstruction) (*HistoryAction, bool) {
+	program := htx.account(instruction.ProgramIDIndex)
+
+	switch program {
+	case common.SystemProgramID.ToBase58():
+		return htx.decodeSystem(instruction), true
+	case common.TokenProgramID.ToBase58(), common.Token2022ProgramID.ToBase58():
+		return htx.decodeToken(instruction), true
+	case common.SPLAssociatedTokenAccountProgramID.ToBase58():
+		return htx.decodeAssociatedTokenAccount(instruction), true
+	case common.MemoProgramID.ToBase58():
+		return &HistoryAction{
+			Program: "memo",
+			Type:    "memo",
+			Memo:    string(instruction.Data),
+		}, true
+	case common.MetaplexTokenMetaProgramID.ToBase58():
+		return htx.decodeTokenMetadata(instruction), true
+	case common.ComputeBudgetProgramID.ToBase58():
+		return nil, true
+	}
+
+	return &HistoryAction{
+		Program: program,
+		Type:    "invoke",
+	}, false
+}
+
+var systemInstructionNames = map[uint32]string{
+	0:  "create_account",
+	1:  "assign",
+	2:  "transfer",
+	3:  "create_account_with_seed",
+	4:  "advance_nonce",
+	5:  "withdraw_nonce",
+	6:  "initialize_nonce",
+	7:  "authorize_nonce",
+	8:  "allocate",
+	9:  "allocate_with_seed",
+	10: "assign_with_seed",
+	11: "transfer_with_seed",
+	12: "upgrade_nonce",
+}
+
+func (htx *historyTransaction) decodeSystem(instruction types.CompiledInstruction) *HistoryAction {
+	res := &HistoryAction{Program: "system", Type: "unknown"}
+	data := instruction.Data
+	if len(data) < 4 {
+		return res
+	}
+
+	index := binary.LittleEndian.Uint32(data)
+	if name, ok := systemInstructionNames[index]; ok {
+		res.Type = name
+	}
+
+	var (
+		lamports uint64
+		from, to string
+	)
+	switch index {
+	case 0, 2:
+		if len(data) < 12 {
+			return res
+		}
+		lamports = binary.LittleEndian.Uint64(data[4:])
+		from, to = htx.instructionAccount(instruction, 0), htx.instructionAccount(instruction, 1)
+	case 3:
+		// base, then a length prefixed seed, then lamports.
+		if len(data) < 52 {
+			return res
+		}
+		// The length is bounded before converting, a huge one would wrap.
+		seedLen := binary.LittleEndian.Uint64(data[36:])
+		if seedLen > uint64(len(data)-52) {
+			return res
+		}
+		lamports = binary.LittleEndian.Uint64(data[44+int(seedLen):])
+		from, to = htx.instructionAccount(instruction, 0), htx.instructionAccount(instruction, 1)
+	case 11:
+		if len(data) < 12 {
+			return res
+		}
+		lamports = binary.LittleEndian.Uint64(data[4:])
+		from, to = htx.instructionAccount(instruction, 0), htx.instructionAccount(instruction, 2)
+	default:
+		return res
+	}
+
+	res.setTransfer(htx.wallet, from, to, from, to, AssetSOL, lamports, solDecimals)
+
+	return res
+}
+
+var tokenInstructionNames = map[byte]string{
+	0:  "initialize_mint",
+	1:  "initialize_account",
+	2:  "initialize_multisig",
+	3:  "transfer",
+	4:  "approve",
+	5:  "revoke",
+	6:  "set_authority",
+	7:  "mint_to",
+	8:  "burn",
+	9:  "close_account",
+	10: "freeze_account",
+	11: "thaw_account",
+	12: "transfer_checked",
+	13: "approve_checked",
+	14: "mint_to_checked",
+	15: "burn_checked",
+	16: "initialize_account2",
+	17: "sync_native",
+	18: "initialize_account3",
+	20: "initialize_mint2",
+}
+
+func (htx *historyTransaction) decodeToken(instruction types.CompiledInstruction) *HistoryAction {
+	res := &HistoryAction{Program: "spl-token", Type: "unknown"}
+	data := instruction.Data
+	if len(data) == 0 {
+		return res
+	}
+	if name, ok := tokenInstructionNames[data[0]]; ok {
+		res.Type = name
+	}
+
+	var amount uint64
+	if len(data) >= 9 {
+		amount = binary.LittleEndian.Uint64(data[1:])
+	}
+
+	switch data[0] {
+	case 3, 12:
+		// transfer_checked puts the mint between source and destination.
+		source, destination := 0, 1
+		if data[0] == 12 {
+			destination = 2
+		}
+		sourceAccount, destinationAccount := htx.tokenAccount(instruction, source), htx.tokenAccount(instruction, destination)
+		mint, decimals := htx.tokenMint(sourceAccount, destinationAccount)
+		if data[0] == 12 && len(data) >= 10 {
+			mint, decimals = htx.instructionAccount(instruction, 1), data[9]
+		}
+
+		res.setTransfer(htx.wallet,
+			htx.tokenAccountOwner(instruction, source, sourceAccount),
+			htx.tokenAccountOwner(instruction, destination, destinationAccount),
+			htx.instructionAccount(instruction, source),
+			htx.instructionAccount(instruction, destination),
+			mint, amount, decimals)
+	case 7, 14:
+		destinationAccount := htx.tokenAccount(instruction, 1)
+		_, decimals := htx.tokenMint(destinationAccount)
+		res.setTransfer(htx.wallet, "",
+			htx.tokenAccountOwner(instruction, 1, destinationAccount),
+			"", htx.instructionAccount(instruction, 1),
+			htx.instructionAccount(instruction, 0), amount, decimals)
+	case 8, 15:
+		sourceAccount := htx.tokenAccount(instruction, 0)
+		_, decimals := htx.tokenMint(sourceAccount)
+		res.setTransfer(htx.wallet,
+			htx.tokenAccountOwner(instruction, 0, sourceAccount), "",
+			htx.instructionAccount(instruction, 0), "",
+			htx.instructionAccount(instruction, 1), amount, decimals)
+	case 9:
+		res.From = htx.instructionAccount(instruction, 0)
+		res.To = htx.instructionAccount(instruction, 1)
+		if res.To == htx.wallet {
+			res.Direction = HistoryDirectionIn
+		}
+	}
+
+	return res
+}
+
+func (htx *historyTransaction) decodeAssociatedTokenAccount(instruction types.CompiledInstruction) *HistoryAction {
+	res := &HistoryAction{Program: "associated-token-account", Type: "create"}
+	if len(instruction.Data) > 0 {
+		switch instruction.Data[0] {
+		case 1:
+			res.Type = "create_idempotent"
+		case 2:
+			res.Type = "recover_nested"
+		}
+	}
+
+	res.From = htx.instructionAccount(instruction, 0)
+	res.To = htx.instructionAccount(instruction, 2)
+	res.Asset = htx.instructionAccount(instruction, 3)
+
+	return res
+}
+
+var tokenMetadataInstructionNames = map[byte]string{
+	1:  "update_metadata_account",
+	4:  "sign_metadata",
+	15: "update_metadata_account_v2",
+	17: "create_master_edition_v3",
+	18: "verify_collection",
+	29: "burn_nft",
+	30: "verify_creator",
+	32: "set_collection_size",
+	33: "create_metadata_account_v3",
+	41: "burn",
+	42: "create",
+	43: "mint",
+	44: "delegate",
+	45: "revoke",
+	46: "lock",
+	47: "unlock",
+	48: "migrate",
+	49: "transfer",
+	50: "update",
+	51: "use",
+	52: "verify",
+	53: "unverify",
+}
+
+func (htx *historyTransaction) decodeTokenMetadata(instruction types.CompiledInstruction) *HistoryAction {
+	res := &HistoryAction{Program: "token-metadata", Type: "unknown"}
+	if len(instruction.Data) == 0 {
+		return res
+	}
+	if name, ok := tokenMetadataInstructionNames[instruction.Data[0]]; ok {
+		res.Type = name
+	}
+
+	// Metadata instructions take the metadata account first and the mint
+	// second, except for the token standard ones.
+	switch instruction.Data[0] {
+	case 1, 15, 33:
+		res.Asset = htx.instructionAccount(instruction, 1)
+	}
+
+	return res
+}
+
+// setTransfer fills a value movement. Owners decide the direction, the
+// accounts themselves are kept as From and To.
+func (a *HistoryAction) setTransfer(wallet, fromOwner, toOwner, from, to, asset string, amount uint64, decimals uint8) {
+	a.Asset = asset
+	a.RawAmount = amount
+	a.Decimals = decimals
+	a.Amount = formatTokenAmount(amount, decimals)
+	a.From, a.To = from, to
+
+	out := fromOwner == wallet || from == wallet
+	in := toOwner == wallet || to == wallet
+	switch {
+	case out && in:
+		a.Direction = HistoryDirectionSelf
+	case out:
+		a.Direction = HistoryDirectionOut
+		a.Counterparty = firstNonEmpty(toOwner, to)
+	case in:
+		a.Direction = HistoryDirectionIn
+		a.Counterparty = firstNonEmpty(fromOwner, from)
+	}
+}
+
+// describe renders the action the way a person would say it.
+func (a *HistoryAction) describe() {
+	asset := a.Symbol
+	if asset == "" {
+		asset = a.Asset
+	}
+	counterparty := a.Counterparty
+	if a.CounterpartyLabel != "" {
+		counterparty = ContactPrefix + a.CounterpartyLabel
+	}
+
+	switch {
+	case a.Type == "memo":
+		a.Description = "memo " + strconv.Quote(a.Memo)
+	case a.Type == "mint_to" || a.Type == "mint_to_checked":
+		a.Description = fmt.Sprintf("minted %s %s to %s", a.Amount, asset, a.To)
+	case a.Type == "burn" && a.Program == "spl-token" || a.Type == "burn_checked":
+		a.Description = fmt.Sprintf("burned %s %s", a.Amount, asset)
+	case a.Type == "close_account":
+		a.Description = fmt.Sprintf("closed token account %s, rent to %s", a.From, a.To)
+	case a.Program == "associated-token-account":
+		a.Description = fmt.Sprintf("created token account of %s for %s", asset, a.To)
+	case a.Amount != "" && a.Direction == HistoryDirectionOut:
+		a.Description = fmt.Sprintf("sent %s %s to %s", a.Amount, asset, counterparty)
+	case a.Amount != "" && a.Direction == HistoryDirectionIn:
+		a.Description = fmt.Sprintf("received %s %s from %s", a.Amount, asset, counterparty)
+	case a.Amount != "" && a.Direction == HistoryDirectionSelf:
+		a.Description = fmt.Sprintf("moved %s %s between own accounts", a.Amount, asset)
+	case a.Amount != "":
+		a.Description = fmt.Sprintf("%s %s %s from %s to %s", a.Type, a.Amount, asset, a.From, a.To)
+	case a.Type == "invoke":
+		a.Description = "called program " + a.Program
+	case a.Asset != "":
+		a.Description = fmt.Sprintf("%s %s for %s", a.Program, a.Type, asset)
+	default:
+		a.Description = fmt.Sprintf("%s %s", a.Program, a.Type)
+	}
+}
+
+func (htx *historyTransaction) account(index int) string {
+	if index < 0 || index >= len(htx.accounts) {
+		return ""
+	}
+
+	return htx.accounts[index].ToBase58()
+}
+
+func (htx *historyTransaction) instructionAccount(instruction types.CompiledInstruction, i int) string {
+	if i >= len(instruction.Accounts) {
+		return ""
+	}
+
+	return htx.account(instruction.Accounts[i])
+}
+
+func (htx *historyTransaction) tokenAccount(instruction types.CompiledInstruction, i int) *historyTokenAccount {
+	if i >= len(instruction.Accounts) {
+		return nil
+	}
+
+	return htx.tokenAccounts[instruction.Accounts[i]]
+}
+
+// tokenAccountOwner falls back to the account itself when the transaction
+// has no balance for it.
+func (htx *historyTransaction) tokenAccountOwner(instruction types.CompiledInstruction, i int, account *historyTokenAccount) string {
+	if account != nil && account.Owner != "" {
+		return account.Owner
+	}
+
+	return htx.instructionAccount(instruction, i)
+}
+
+func (htx *historyTransaction) tokenMint(accounts ...*historyTokenAccount) (string, uint8) {
+	for _, account := range accounts {
+		if account != nil {
+			return account.Mint, account.Decimals
+		}
+	}
+
+	return "", 0
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
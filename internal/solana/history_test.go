@@ -0,0 +1,169 @@
+package solana
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_decodeHistoryEntry(t *testing.T) {
+	wallet := types.NewAccount().PublicKey
+	other := types.NewAccount().PublicKey
+	walletToken := types.NewAccount().PublicKey
+	otherToken := types.NewAccount().PublicKey
+	mint := types.NewAccount().PublicKey
+	dex := types.NewAccount().PublicKey
+
+	keys := []common.PublicKey{
+		wallet, other, walletToken, otherToken, mint,
+		common.SystemProgramID, common.TokenProgramID, common.MemoProgramID, dex, common.ComputeBudgetProgramID,
+	}
+	tokenTransfer := token.Transfer(token.TransferParam{From: otherToken, To: walletToken, Auth: other, Amount: 1500})
+	solTransfer := system.Transfer(system.TransferParam{From: wallet, To: other, Amount: 100000000})
+	blockTime := int64(1700000000)
+
+	tx := &client.Transaction{
+		Slot:        42,
+		BlockTime:   &blockTime,
+		AccountKeys: keys,
+		Meta: &client.TransactionMeta{
+			Fee: 5000,
+			PostTokenBalances: []rpc.TransactionMetaTokenBalance{
+				{AccountIndex: 2, Mint: mint.ToBase58(), Owner: wallet.ToBase58(), UITokenAmount: rpc.TokenAccountBalance{Decimals: 2}},
+				{AccountIndex: 3, Mint: mint.ToBase58(), Owner: other.ToBase58(), UITokenAmount: rpc.TokenAccountBalance{Decimals: 2}},
+			},
+			InnerInstructions: []client.InnerInstruction{{
+				Index:        3,
+				Instructions: []types.CompiledInstruction{{ProgramIDIndex: 6, Accounts: []int{3, 2, 1}, Data: tokenTransfer.Data}},
+			}},
+		},
+		Transaction: types.Transaction{Message: types.Message{Instructions: []types.CompiledInstruction{
+			{ProgramIDIndex: 9, Data: []byte{3, 1, 0, 0, 0, 0, 0, 0, 0}},
+			{ProgramIDIndex: 5, Accounts: []int{0, 1}, Data: solTransfer.Data},
+			{ProgramIDIndex: 7, Accounts: []int{0}, Data: []byte("invoice 42")},
+			{ProgramIDIndex: 8, Accounts: []int{3, 2, 1}},
+		}}},
+	}
+
+	entry := decodeHistoryEntry(wallet.ToBase58(), "sig", tx)
+	require.Equal(t, HistoryStatusSuccess, entry.Status)
+	require.True(t, entry.FeePayer)
+	require.Equal(t, uint64(5000), entry.Fee)
+	require.Equal(t, int64(1700000000), entry.Time.Unix())
+	require.Equal(t, []string{"invoice 42"}, entry.Memos)
+	require.Len(t, entry.Actions, 4)
+
+	for _, action := range entry.Actions {
+		action.describe()
+	}
+
+	sol := entry.Actions[0]
+	require.Equal(t, HistoryDirectionOut, sol.Direction)
+	require.Equal(t, AssetSOL, sol.Asset)
+	require.Equal(t, "0.1", sol.Amount)
+	require.Equal(t, "sent 0.1 SOL to "+other.ToBase58(), sol.Description)
+
+	require.Equal(t, `memo "invoice 42"`, entry.Actions[1].Description)
+	require.Equal(t, "called program "+dex.ToBase58(), entry.Actions[2].Description)
+
+	received := entry.Actions[3]
+	received.Symbol = "EXMPL"
+	received.CounterpartyLabel = "partner"
+	received.describe()
+	require.Equal(t, HistoryDirectionIn, received.Direction)
+	require.Equal(t, mint.ToBase58(), received.Asset)
+	require.Equal(t, "received 15 EXMPL from @partner", received.Description)
+
+	require.True(t, entry.matches(mint.ToBase58(), HistoryDirectionIn))
+	require.True(t, entry.matches(AssetSOL, HistoryDirectionOut))
+	require.False(t, entry.matches(AssetSOL, HistoryDirectionIn))
+}
+
+func Test_decodeSystemCreateAccountWithSeed(t *testing.T) {
+	htx := &historyTransaction{wallet: "wallet"}
+
+	data := make([]byte, 56)
+	data[0] = 3
+	data[36] = 4
+	data[48] = 7
+	require.Equal(t, uint64(7), htx.decodeSystem(types.CompiledInstruction{Data: data}).RawAmount)
+
+	// A seed length of 2^63 or more must not wrap around.
+	for _, seedLen := range []uint64{1 << 63, ^uint64(0) - 7} {
+		binary.LittleEndian.PutUint64(data[36:], seedLen)
+		require.NotPanics(t, func() {
+			htx.decodeSystem(types.CompiledInstruction{Data: data})
+		})
+	}
+}
+
+func Test_HistoryNextWithinShortPage(t *testing.T) {
+	wallet := types.NewAccount()
+	tx, err := types.NewTransaction(types.NewTransactionParam{
+		Signers: []types.Account{wallet},
+		Message: types.NewMessage(types.NewMessageParam{
+			FeePayer:        wallet.PublicKey,
+			RecentBlockhash: common.PublicKey{}.ToBase58(),
+			Instructions: []types.Instruction{
+				system.Transfer(system.TransferParam{From: wallet.PublicKey, To: types.NewAccount().PublicKey, Amount: 1}),
+			},
+		}),
+	})
+	require.NoError(t, err)
+	rawTx, err := tx.Serialize()
+	require.NoError(t, err)
+
+	signatures := make([]any, 50)
+	for i := range signatures {
+		signatures[i] = map[string]any{"signature": fmt.Sprintf("signature%d", i), "slot": 50 - i}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int    `json:"id"`
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		res := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "getSignaturesForAddress":
+			res["result"] = signatures
+		case "getTransaction":
+			res["result"] = map[string]any{"slot": 1, "transaction": []string{base64.StdEncoding.EncodeToString(rawTx), "base64"}}
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	defer server.Close()
+
+	m := &Module{
+		config:       &config{ContactsFilename: filepath.Join(t.TempDir(), "contacts.json")},
+		solanaClient: client.NewClient(server.URL),
+	}
+
+	// The page is shorter than a full one, but the limit stopped the walk
+	// before its end.
+	res, err := m.History(context.Background(), &HistoryRequest{Address: wallet.PublicKey.ToBase58(), Limit: 20})
+	require.NoError(t, err)
+	require.Len(t, res.Entries, 20)
+	require.Equal(t, "signature19", res.Next)
+
+	res, err = m.History(context.Background(), &HistoryRequest{Address: wallet.PublicKey.ToBase58(), Limit: 50})
+	require.NoError(t, err)
+	require.Len(t, res.Entries, 50)
+	require.Empty(t, res.Next)
+}
@@ -0,0 +1,210 @@
+package solana
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"sort"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+type TokenHoldersRequest struct {
+	MintAddress string
+	TopN        int
+	// ExcludedAddresses are treasury owners or token accounts left out of the
+	// circulating supply and concentration figures.
+	ExcludedAddresses []string
+}
+
+type TokenHolder struct {
+	Owner              string   `json:"owner"`
+	TokenAccounts      []string `json:"token_accounts"`
+	Amount             uint64   `json:"amount"`
+	UiAmount           string   `json:"ui_amount"`
+	SupplyPercent      float64  `json:"supply_percent"`
+	CirculatingPercent float64  `json:"circulating_percent"`
+	Excluded           bool     `json:"excluded"`
+}
+
+type TokenHoldersReport struct {
+	Mint              string         `json:"mint"`
+	Decimals          uint8          `json:"decimals"`
+	Supply            uint64         `json:"supply"`
+	CirculatingSupply uint64         `json:"circulating_supply"`
+	HolderCount       int            `json:"holder_count"`
+	TopN              int            `json:"top_n"`
+	TopNPercent       float64        `json:"top_n_circulating_percent"`
+	Gini              float64        `json:"gini"`
+	Holders           []*TokenHolder `json:"holders"`
+}
+
+// TokenHolders lists every token account of a mint grouped by owner and
+// computes distribution statistics over the circulating supply.
+func (m *Module) TokenHolders(ctx context.Context, req *TokenHoldersRequest) (*TokenHoldersReport, error) {
+	_, span := tracer.Start(ctx, "internal.solana.TokenHolders")
+	defer span.End()
+
+	mint, err := m.MintInfo(ctx, req.MintAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "get mint info")
+	}
+
+	programID := common.TokenProgramID
+	filters := []rpc.GetProgramAccountsConfigFilter{
+		{MemCmp: &rpc.GetProgramAccountsConfigFilterMemCmp{Offset: 0, Bytes: mint.Address}},
+	}
+	if mint.Program == "token-2022" {
+		programID = common.Token2022ProgramID
+	} else {
+		filters = append(filters, rpc.GetProgramAccountsConfigFilter{DataSize: token.TokenAccountSize})
+	}
+
+	// Mint, owner and amount are the first 72 bytes of a token account in
+	// both token programs.
+	res, err := m.solanaClient.RpcClient.GetProgramAccountsWithConfig(ctx, programID.ToBase58(), rpc.GetProgramAccountsConfig{
+		Encoding:  rpc.AccountEncodingBase64,
+		DataSlice: &rpc.DataSlice{Offset: 0, Length: 72},
+		Filters:   filters,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "get token accounts by mint")
+	}
+	if err := res.GetError(); err != nil {
+		return nil, errors.Wrap(err, "get token accounts by mint")
+	}
+
+	excluded := make(map[string]bool, len(req.ExcludedAddresses))
+	for _, address := range req.ExcludedAddresses {
+		excluded[address] = true
+	}
+
+	holders := map[string]*TokenHolder{}
+	for _, account := range res.Result {
+		data, err := decodeRpcAccountData(account.Account.Data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode token account %s", account.Pubkey)
+		}
+		if len(data) < 72 {
+			return nil, errors.Errorf("token account %s data is too short", account.Pubkey)
+		}
+
+		owner := common.PublicKeyFromBytes(data[32:64]).ToBase58()
+		amount := binary.LittleEndian.Uint64(data[64:72])
+
+		holder, ok := holders[owner]
+		if !ok {
+			holder = &TokenHolder{Owner: owner, Excluded: excluded[owner]}
+			holders[owner] = holder
+		}
+		holder.TokenAccounts = append(holder.TokenAccounts, account.Pubkey)
+		holder.Amount += amount
+		holder.Excluded = holder.Excluded || excluded[account.Pubkey]
+	}
+
+	report := &TokenHoldersReport{
+		Mint:     mint.Address,
+		Decimals: mint.Decimals,
+		Supply:   mint.Supply,
+		TopN:     req.TopN,
+	}
+
+	report.Holders = make([]*TokenHolder, 0, len(holders))
+	for _, holder := range holders {
+		if holder.Amount == 0 {
+			continue
+		}
+		report.Holders = append(report.Holders, holder)
+	}
+	sort.Slice(report.Holders, func(i, j int) bool {
+		if report.Holders[i].Amount != report.Holders[j].Amount {
+			return report.Holders[i].Amount > report.Holders[j].Amount
+		}
+		return report.Holders[i].Owner < report.Holders[j].Owner
+	})
+
+	report.CirculatingSupply = report.Supply
+	var circulating []uint64
+	for _, holder := range report.Holders {
+		if holder.Excluded {
+			report.CirculatingSupply -= min(holder.Amount, report.CirculatingSupply)
+			continue
+		}
+		circulating = append(circulating, holder.Amount)
+	}
+	report.HolderCount = len(circulating)
+
+	for _, holder := range report.Holders {
+		holder.UiAmount = formatTokenAmount(holder.Amount, report.Decimals)
+		holder.SupplyPercent = percent(holder.Amount, report.Supply)
+		if !holder.Excluded {
+			holder.CirculatingPercent = percent(holder.Amount, report.CirculatingSupply)
+		}
+	}
+
+	var topN uint64
+	for i := 0; i < len(circulating) && i < req.TopN; i++ {
+		topN += circulating[i]
+	}
+	report.TopNPercent = percent(topN, report.CirculatingSupply)
+	report.Gini = giniCoefficient(circulating)
+
+	return report, nil
+}
+
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(part) / float64(total) * 100
+}
+
+// giniCoefficient returns 0 for a perfectly even distribution and approaches
+// 1 when a single holder owns everything.
+func giniCoefficient(balances []uint64) float64 {
+	n := len(balances)
+	if n == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, n)
+	for i, balance := range balances {
+		sorted[i] = float64(balance)
+	}
+	sort.Float64s(sorted)
+
+	var sum, weighted float64
+	for i, balance := range sorted {
+		sum += balance
+		weighted += float64(i+1) * balance
+	}
+	if sum == 0 {
+		return 0
+	}
+
+	return 2*weighted/(float64(n)*sum) - float64(n+1)/float64(n)
+}
+
+// decodeRpcAccountData reads the ["<data>", "base64"] pair returned by the raw
+// RPC client.
+func decodeRpcAccountData(data any) ([]byte, error) {
+	pair, ok := data.([]any)
+	if !ok || len(pair) != 2 {
+		return nil, errors.New("unexpected account data format")
+	}
+	if pair[1] != string(rpc.AccountEncodingBase64) {
+		return nil, errors.Errorf("unexpected account data encoding %v", pair[1])
+	}
+	encoded, ok := pair[0].(string)
+	if !ok {
+		return nil, errors.New("unexpected account data format")
+	}
+
+	return base64.StdEncoding.DecodeString(encoded)
+}
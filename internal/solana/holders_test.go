@@ -0,0 +1,14 @@
+package solana
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_giniCoefficient(t *testing.T) {
+	require.Equal(t, float64(0), giniCoefficient(nil))
+	require.InDelta(t, 0, giniCoefficient([]uint64{5, 5, 5, 5}), 1e-9)
+	require.InDelta(t, 0.75, giniCoefficient([]uint64{0, 0, 0, 100}), 1e-9)
+	require.InDelta(t, 0.25, giniCoefficient([]uint64{1, 2, 3, 4}), 1e-9)
+}
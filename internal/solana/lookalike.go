@@ -0,0 +1,154 @@
+package solana
+
+import (
+	"context"
+	"sort"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+const knownAddressHistory = "history"
+
+// LookAlike is a recipient sharing its first and last characters with a
+// known counterparty without being it, the shape of an address poisoning
+// attack.
+type LookAlike struct {
+	Address      string `json:"address"`
+	KnownAddress string `json:"known_address"`
+	// Source tells where the known address comes from.
+	Source string `json:"source"`
+}
+
+// FindLookAlikes compares the recipients with the address book and the
+// counterparties of the owner's recent transactions.
+func (m *Module) FindLookAlikes(ctx context.Context, ownerKeyFilename string, recipients ...string) ([]*LookAlike, error) {
+	_, span := tracer.Start(ctx, "internal.solana.FindLookAlikes")
+	defer span.End()
+
+	ownerAccount, err := loadFromKeyFile(ctx, ownerKeyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load owner account")
+	}
+
+	known, err := m.knownAddresses(ctx, ownerAccount.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return findLookAlikes(known, recipients, m.config.LookAlikeChars), nil
+}
+
+// knownAddresses maps the address book and the addresses the owner dealt
+// with to their source. History lookups that fail are logged and skipped, the
+// check then relies on what could be read.
+func (m *Module) knownAddresses(ctx context.Context, owner common.PublicKey) (map[string]string, error) {
+	labels, err := m.contactLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	signatures, err := m.solanaClient.GetSignaturesForAddressWithConfig(ctx, owner.ToBase58(), client.GetSignaturesForAddressConfig{
+		Limit: m.config.LookAlikeHistory,
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, errors.Wrap(err, "failed to get signatures")
+		}
+		m.log.Error(ctx, "failed to get signatures, checking contacts only", err)
+	}
+
+	counterparties := make([][]string, len(signatures))
+	err = runConcurrently(len(signatures), m.config.RpcConcurrency, func(i int) error {
+		tx, err := m.solanaClient.GetTransactionWithConfig(ctx, signatures[i].Signature, client.GetTransactionConfig{
+			Commitment: rpc.CommitmentConfirmed,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return errors.Wrapf(err, "failed to get transaction %s", signatures[i].Signature)
+			}
+			m.log.Error(ctx, "failed to get transaction, skipping it", err, signatures[i].Signature)
+
+			return nil
+		}
+		if tx != nil {
+			counterparties[i] = transactionCounterparties(tx, owner)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]string{}
+	for _, addresses := range counterparties {
+		for _, address := range addresses {
+			res[address] = knownAddressHistory
+		}
+	}
+	for address := range labels {
+		res[address] = knownAddressContacts
+	}
+
+	return res, nil
+}
+
+// transactionCounterparties lists the accounts of a transaction other than
+// the owner and the programs it invoked.
+func transactionCounterparties(tx *client.Transaction, owner common.PublicKey) []string {
+	programs := map[common.PublicKey]bool{}
+	for _, instruction := range tx.Transaction.Message.Instructions {
+		if instruction.ProgramIDIndex < len(tx.AccountKeys) {
+			programs[tx.AccountKeys[instruction.ProgramIDIndex]] = true
+		}
+	}
+
+	var res []string
+	for _, account := range tx.AccountKeys {
+		if account == owner || programs[account] {
+			continue
+		}
+		res = append(res, account.ToBase58())
+	}
+
+	return res
+}
+
+func findLookAlikes(known map[string]string, recipients []string, chars int) []*LookAlike {
+	var res []*LookAlike
+	for _, recipient := range recipients {
+		for address, source := range known {
+			if looksAlike(recipient, address, chars) {
+				res = append(res, &LookAlike{
+					Address:      recipient,
+					KnownAddress: address,
+					Source:       source,
+				})
+			}
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Address != res[j].Address {
+			return res[i].Address < res[j].Address
+		}
+		return res[i].KnownAddress < res[j].KnownAddress
+	})
+
+	return res
+}
+
+// looksAlike reports different addresses sharing their first and last chars
+// characters, which is all most wallets show.
+func looksAlike(a, b string, chars int) bool {
+	if a == b || len(a) < 2*chars || len(b) < 2*chars {
+		return false
+	}
+
+	return a[:chars] == b[:chars] && a[len(a)-chars:] == b[len(b)-chars:]
+}
@@ -0,0 +1,109 @@
+package solana
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/logger"
+)
+
+func Test_looksAlike(t *testing.T) {
+	const known = "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU"
+
+	require.True(t, looksAlike("7xKXbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbgAsU", known, 4))
+	require.False(t, looksAlike(known, known, 4))
+	require.False(t, looksAlike("7xKXbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbgAsV", known, 4))
+	require.False(t, looksAlike("8xKXbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbgAsU", known, 4))
+	require.False(t, looksAlike("7xK", known, 4))
+}
+
+func Test_findLookAlikes(t *testing.T) {
+	known := map[string]string{
+		"7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU": knownAddressHistory,
+		"9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM": knownAddressHistory,
+	}
+
+	res := findLookAlikes(known, []string{
+		"7xKXbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbgAsU",
+		"9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM",
+	}, 4)
+	require.Equal(t, []*LookAlike{{
+		Address:      "7xKXbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbgAsU",
+		KnownAddress: "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU",
+		Source:       knownAddressHistory,
+	}}, res)
+}
+
+func Test_transactionCounterparties(t *testing.T) {
+	owner := types.NewAccount().PublicKey
+	counterparty := types.NewAccount().PublicKey
+
+	tx := &client.Transaction{
+		AccountKeys: []common.PublicKey{owner, counterparty, common.SystemProgramID},
+		Transaction: types.Transaction{Message: types.Message{
+			Instructions: []types.CompiledInstruction{{ProgramIDIndex: 2, Accounts: []int{0, 1}}},
+		}},
+	}
+
+	require.Equal(t, []string{counterparty.ToBase58()}, transactionCounterparties(tx, owner))
+}
+
+func Test_knownAddressesSkipsFailedLookups(t *testing.T) {
+	owner := types.NewAccount()
+	counterparty := types.NewAccount().PublicKey
+	tx, err := types.NewTransaction(types.NewTransactionParam{
+		Signers: []types.Account{owner},
+		Message: types.NewMessage(types.NewMessageParam{
+			FeePayer:        owner.PublicKey,
+			RecentBlockhash: common.PublicKey{}.ToBase58(),
+			Instructions: []types.Instruction{
+				system.Transfer(system.TransferParam{From: owner.PublicKey, To: counterparty, Amount: 1}),
+			},
+		}),
+	})
+	require.NoError(t, err)
+	rawTx, err := tx.Serialize()
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int    `json:"id"`
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		res := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch {
+		case req.Method == "getSignaturesForAddress":
+			res["result"] = []any{map[string]any{"signature": "pruned"}, map[string]any{"signature": "kept"}}
+		case req.Params[0] == "pruned":
+			res["error"] = map[string]any{"code": -32009, "message": "transaction history is not available"}
+		default:
+			res["result"] = map[string]any{"slot": 1, "transaction": []string{base64.StdEncoding.EncodeToString(rawTx), "base64"}}
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	defer server.Close()
+
+	m := &Module{
+		config:       &config{ContactsFilename: filepath.Join(t.TempDir(), "contacts.json"), LookAlikeHistory: 2, RpcConcurrency: 2},
+		log:          logger.New("test"),
+		solanaClient: client.NewClient(server.URL),
+	}
+
+	known, err := m.knownAddresses(context.Background(), owner.PublicKey)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{counterparty.ToBase58(): knownAddressHistory}, known)
+}
@@ -0,0 +1,109 @@
+package solana
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/memo"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/mr-tron/base58"
+)
+
+// memoLogPrefix starts the line the Memo program logs for every memo.
+const memoLogPrefix = "Program log: Memo (len "
+
+// memoInstructions builds the SPL Memo instruction for a non-empty memo. The
+// sender signs it, so the memo is attributable to it.
+func memoInstructions(text string, signer common.PublicKey) ([]types.Instruction, error) {
+	if text == "" {
+		return nil, nil
+	}
+	if !utf8.ValidString(text) {
+		return nil, errorf(ErrorKindInvalidInput, "memo must be valid UTF-8")
+	}
+
+	return []types.Instruction{memo.BuildMemo(memo.BuildMemoParam{
+		SignerPubkeys: []common.PublicKey{signer},
+		Memo:          []byte(text),
+	})}, nil
+}
+
+// addReferences appends the reference keys to the transfer instruction as
+// read-only accounts, the way Solana Pay does, so the payment can be found
+// with getSignaturesForAddress on a reference.
+func addReferences(transfer *types.Instruction, references []string) error {
+	for _, reference := range references {
+		decoded, err := base58.Decode(reference)
+		if err != nil || len(decoded) != common.PublicKeyLength {
+			return errorf(ErrorKindInvalidInput, "invalid reference key %q", reference)
+		}
+
+		transfer.Accounts = append(transfer.Accounts, types.AccountMeta{
+			PubKey: common.PublicKeyFromBytes(decoded),
+		})
+	}
+
+	return nil
+}
+
+// decodeMemoInstruction returns the memo of an SPL Memo instruction.
+func decodeMemoInstruction(instruction types.Instruction) (string, bool) {
+	if instruction.ProgramID != common.MemoProgramID {
+		return "", false
+	}
+
+	return string(instruction.Data), true
+}
+
+// memosFromLogs extracts memos from transaction logs, where the Memo program
+// writes lines like: Program log: Memo (len 5): "hello"
+func memosFromLogs(logs []string) []string {
+	var res []string
+	for _, line := range logs {
+		if !strings.HasPrefix(line, memoLogPrefix) {
+			continue
+		}
+
+		_, quoted, ok := strings.Cut(line, "): ")
+		if !ok {
+			continue
+		}
+
+		text, err := strconv.Unquote(quoted)
+		if err != nil {
+			text = strings.Trim(quoted, `"`)
+		}
+		res = append(res, text)
+	}
+
+	return res
+}
+
+// parseSignatureMemo splits the memo field of getSignaturesForAddress, which
+// joins memos as "[len] memo; [len] memo".
+func parseSignatureMemo(field string) []string {
+	var res []string
+	for field != "" {
+		if !strings.HasPrefix(field, "[") {
+			return append(res, field)
+		}
+
+		end := strings.Index(field, "] ")
+		if end < 0 {
+			return append(res, field)
+		}
+
+		length, err := strconv.Atoi(field[1:end])
+		rest := field[end+2:]
+		if err != nil || length > len(rest) {
+			return append(res, rest)
+		}
+
+		res = append(res, rest[:length])
+		field = strings.TrimPrefix(rest[length:], "; ")
+	}
+
+	return res
+}
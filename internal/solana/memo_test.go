@@ -0,0 +1,62 @@
+package solana
+
+import (
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_memoInstructions(t *testing.T) {
+	signer := types.NewAccount().PublicKey
+
+	instructions, err := memoInstructions("", signer)
+	require.NoError(t, err)
+	require.Empty(t, instructions)
+
+	instructions, err = memoInstructions("order 42", signer)
+	require.NoError(t, err)
+	require.Len(t, instructions, 1)
+	require.Equal(t, common.MemoProgramID, instructions[0].ProgramID)
+	require.True(t, instructions[0].Accounts[0].IsSigner)
+
+	text, ok := decodeMemoInstruction(instructions[0])
+	require.True(t, ok)
+	require.Equal(t, "order 42", text)
+
+	_, err = memoInstructions("\xff", signer)
+	require.Error(t, err)
+}
+
+func Test_addReferences(t *testing.T) {
+	reference := types.NewAccount().PublicKey
+	transfer := system.Transfer(system.TransferParam{
+		From:   types.NewAccount().PublicKey,
+		To:     types.NewAccount().PublicKey,
+		Amount: 1,
+	})
+
+	require.NoError(t, addReferences(&transfer, []string{reference.ToBase58()}))
+	require.Len(t, transfer.Accounts, 3)
+	require.Equal(t, types.AccountMeta{PubKey: reference}, transfer.Accounts[2])
+
+	require.Error(t, addReferences(&transfer, []string{"not-a-key"}))
+}
+
+func Test_memosFromLogs(t *testing.T) {
+	require.Equal(t, []string{"hello", `say "hi"`}, memosFromLogs([]string{
+		"Program MemoSq4gqABAXKb96qnH8TysNcWxMyWCqXgDLGmfcHr invoke [1]",
+		`Program log: Memo (len 5): "hello"`,
+		`Program log: Memo (len 8): "say \"hi\""`,
+		"Program MemoSq4gqABAXKb96qnH8TysNcWxMyWCqXgDLGmfcHr success",
+	}))
+}
+
+func Test_parseSignatureMemo(t *testing.T) {
+	require.Nil(t, parseSignatureMemo(""))
+	require.Equal(t, []string{"hello"}, parseSignatureMemo("[5] hello"))
+	require.Equal(t, []string{"a; b", "c"}, parseSignatureMemo("[4] a; b; [1] c"))
+	require.Equal(t, []string{"plain"}, parseSignatureMemo("plain"))
+}
@@ -0,0 +1,161 @@
+package solana
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/metaplex/token_metadata"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+type MintMetadataCreator struct {
+	Address  string `json:"address"`
+	Verified bool   `json:"verified"`
+	Share    uint8  `json:"share"`
+}
+
+type MintMetadata struct {
+	Address              string                 `json:"address"`
+	UpdateAuthority      string                 `json:"update_authority"`
+	IsMutable            bool                   `json:"is_mutable"`
+	PrimarySaleHappened  bool                   `json:"primary_sale_happened"`
+	Name                 string                 `json:"name"`
+	Symbol               string                 `json:"symbol"`
+	Uri                  string                 `json:"uri"`
+	SellerFeeBasisPoints uint16                 `json:"seller_fee_basis_points"`
+	Creators             []*MintMetadataCreator `json:"creators"`
+}
+
+type MintInfoResponse struct {
+	Address         string            `json:"address"`
+	Program         string            `json:"program"`
+	IsInitialized   bool              `json:"is_initialized"`
+	Supply          uint64            `json:"supply"`
+	UiSupply        string            `json:"ui_supply"`
+	Decimals        uint8             `json:"decimals"`
+	MintAuthority   string            `json:"mint_authority"`
+	FreezeAuthority string            `json:"freeze_authority"`
+	Extensions      []*TokenExtension `json:"extensions,omitempty"`
+	Metadata        *MintMetadata     `json:"metadata"`
+}
+
+// MintInfo decodes a mint account, including Token-2022 extensions, and its
+// Metaplex metadata account if there is one.
+func (m *Module) MintInfo(ctx context.Context, mintAddress string) (*MintInfoResponse, error) {
+	_, span := tracer.Start(ctx, "internal.solana.MintInfo")
+	defer span.End()
+
+	mintPubKey, err := ParseAddress(mintAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid mint")
+	}
+
+	mintAccount, err := m.solanaClient.GetAccountInfo(ctx, mintPubKey.ToBase58())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get mint account")
+	}
+
+	res, err := decodeMint(mintPubKey, mintAccount.Owner, mintAccount.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataKey, err := token_metadata.GetTokenMetaPubkey(mintPubKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "calculate metadata key")
+	}
+
+	metadataAccount, err := m.solanaClient.GetAccountInfo(ctx, metadataKey.ToBase58())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get account metadata")
+	}
+
+	if len(metadataAccount.Data) > 0 {
+		res.Metadata, err = decodeMintMetadata(metadataKey, metadataAccount.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func decodeMint(mint common.PublicKey, owner common.PublicKey, data []byte) (*MintInfoResponse, error) {
+	res := &MintInfoResponse{
+		Address: mint.ToBase58(),
+	}
+
+	switch owner {
+	case common.TokenProgramID:
+		res.Program = "token"
+	case common.Token2022ProgramID:
+		res.Program = "token-2022"
+	case common.PublicKey{}:
+		return nil, &AccountNotFoundError{Address: mint.ToBase58(), What: "mint"}
+	default:
+		return nil, &InvalidMintError{Address: mint.ToBase58(), Reason: fmt.Sprintf("owned by %s, not a token program", owner.ToBase58())}
+	}
+
+	if len(data) < token.MintAccountSize {
+		return nil, &InvalidMintError{Address: mint.ToBase58(), Reason: "account is not a mint"}
+	}
+
+	mintState, err := token.MintAccountFromData(data[:token.MintAccountSize])
+	if err != nil {
+		return nil, errors.Wrap(err, "decode mint account")
+	}
+
+	res.IsInitialized = mintState.IsInitialized
+	res.Supply = mintState.Supply
+	res.UiSupply = formatTokenAmount(mintState.Supply, mintState.Decimals)
+	res.Decimals = mintState.Decimals
+	if mintState.MintAuthority != nil {
+		res.MintAuthority = mintState.MintAuthority.ToBase58()
+	}
+	if mintState.FreezeAuthority != nil {
+		res.FreezeAuthority = mintState.FreezeAuthority.ToBase58()
+	}
+
+	if owner == common.Token2022ProgramID {
+		res.Extensions, err = decodeToken2022Extensions(data, token2022AccountTypeMint)
+		if err != nil {
+			return nil, errors.Wrap(err, "decode token-2022 extensions")
+		}
+	}
+
+	return res, nil
+}
+
+func decodeMintMetadata(address common.PublicKey, data []byte) (*MintMetadata, error) {
+	md, err := token_metadata.MetadataDeserialize(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode metadata")
+	}
+
+	res := &MintMetadata{
+		Address:              address.ToBase58(),
+		UpdateAuthority:      md.UpdateAuthority.ToBase58(),
+		IsMutable:            md.IsMutable,
+		PrimarySaleHappened:  md.PrimarySaleHappened,
+		Name:                 md.Data.Name,
+		Symbol:               md.Data.Symbol,
+		Uri:                  md.Data.Uri,
+		SellerFeeBasisPoints: md.Data.SellerFeeBasisPoints,
+	}
+
+	if md.Data.Creators != nil {
+		for _, creator := range *md.Data.Creators {
+			res.Creators = append(res.Creators, &MintMetadataCreator{
+				Address:  creator.Address.ToBase58(),
+				Verified: creator.Verified,
+				Share:    creator.Share,
+			})
+		}
+	}
+
+	return res, nil
+}
@@ -2,8 +2,10 @@ package solana
 
 import (
 	"context"
+	"time"
 
 	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/rpc"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/pkg/errors"
 
@@ -16,10 +18,81 @@ type config struct {
 
 	ApiSandboxUrl string `envconfig:"SOLANA_API_SANDBOX_URL" default:"https://api.devnet.solana.com"`
 	ApiUrl        string `envconfig:"SOLANA_API_URL" default:"https://api.mainnet-beta.solana.com"`
+
+	// DasApiUrl is a Digital Asset Standard capable RPC, needed to read
+	// compressed NFT proofs. Defaults to the active RPC URL.
+	DasApiUrl string `envconfig:"SOLANA_DAS_API_URL"`
+
+	// WsUrl is the PubSub endpoint, derived from the RPC URL by default.
+	WsUrl string `envconfig:"SOLANA_WS_URL"`
+
+	// ConfirmCommitment is one of processed, confirmed or finalized.
+	ConfirmCommitment   string        `envconfig:"SOLANA_CONFIRM_COMMITMENT" default:"confirmed"`
+	ConfirmTimeout      time.Duration `envconfig:"SOLANA_CONFIRM_TIMEOUT" default:"2m"`
+	ConfirmPollInterval time.Duration `envconfig:"SOLANA_CONFIRM_POLL_INTERVAL" default:"2s"`
+
+	// ComputeUnitLimit and ComputeUnitPrice are described by ComputeBudget.
+	ComputeUnitLimit      string `envconfig:"SOLANA_COMPUTE_UNIT_LIMIT"`
+	ComputeUnitPrice      string `envconfig:"SOLANA_COMPUTE_UNIT_PRICE"`
+	PriorityFeePercentile int    `envconfig:"SOLANA_PRIORITY_FEE_PERCENTILE" default:"75"`
+
+	// WatchPollInterval paces the watch command when PubSub is unavailable.
+	WatchPollInterval time.Duration `envconfig:"SOLANA_WATCH_POLL_INTERVAL" default:"10s"`
+
+	// LookAlikeChars is how many leading and trailing characters make two
+	// addresses look alike, LookAlikeHistory how many recent transactions
+	// provide the known counterparties.
+	LookAlikeChars   int `envconfig:"SOLANA_LOOKALIKE_CHARS" default:"4"`
+	LookAlikeHistory int `envconfig:"SOLANA_LOOKALIKE_HISTORY" default:"25"`
+
+	ContactsFilename string `envconfig:"SOLANA_CONTACTS_FILE" default:"contacts.json"`
+
+	// RpcConcurrency bounds the RPC calls of independent lookups in flight.
+	RpcConcurrency int `envconfig:"SOLANA_RPC_CONCURRENCY" default:"4"`
+
+	// StakeRewardEpochs is how many past epochs of rewards account_info shows
+	// for every stake account.
+	StakeRewardEpochs int `envconfig:"SOLANA_STAKE_REWARD_EPOCHS" default:"5"`
 }
 
 func (c *config) Load() error {
-	return envconfig.Process("", c)
+	if err := envconfig.Process("", c); err != nil {
+		return err
+	}
+
+	if _, ok := commitmentLevels[rpc.Commitment(c.ConfirmCommitment)]; !ok {
+		return errors.Errorf("unsupported commitment %q", c.ConfirmCommitment)
+	}
+
+	return nil
+}
+
+func (c *config) computeBudgetConfig() *ComputeBudget {
+	return &ComputeBudget{
+		UnitLimit:             c.ComputeUnitLimit,
+		UnitPrice:             c.ComputeUnitPrice,
+		PriorityFeePercentile: c.PriorityFeePercentile,
+	}
+}
+
+func (c *config) commitment() rpc.Commitment {
+	return rpc.Commitment(c.ConfirmCommitment)
+}
+
+func (c *config) apiUrl() string {
+	if c.UseSandbox {
+		return c.ApiSandboxUrl
+	}
+
+	return c.ApiUrl
+}
+
+func (c *config) dasApiUrl() string {
+	if c.DasApiUrl != "" {
+		return c.DasApiUrl
+	}
+
+	return c.apiUrl()
 }
 
 var m *Module
@@ -39,18 +112,19 @@ func New(ctx context.Context) (*Module, error) {
 		return nil, errors.Wrap(err, "loading configuration")
 	}
 
-	apiUrl := c.ApiUrl
-	if c.UseSandbox {
-		apiUrl = c.ApiSandboxUrl
-	}
+	sc := client.NewClient(c.apiUrl())
 
-	sc := client.NewClient(apiUrl)
+	budget, err := parseComputeBudget(c.computeBudgetConfig())
+	if err != nil {
+		return nil, errors.Wrap(err, "loading configuration")
+	}
 
 	m = &Module{
 		config: c,
 		log:    l,
 
-		solanaClient: sc,
+		solanaClient:  sc,
+		computeBudget: budget,
 	}
 
 	return m, nil
@@ -60,5 +134,7 @@ type Module struct {
 	config *config
 	log    logger.Logger
 
-	solanaClient *client.Client
+	solanaClient  *client.Client
+	computeBudget *computeBudget
+	dryRun        bool
 }
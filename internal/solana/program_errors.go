@@ -0,0 +1,252 @@
+package solana
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/pkg/errors"
+)
+
+type programErrorCode struct {
+	name    string
+	message string
+	// insufficientFunds codes are reported with ErrorKindInsufficientFunds.
+	insufficientFunds bool
+}
+
+type programErrorTable struct {
+	program string
+	// codes are indexed by the custom error code.
+	codes []programErrorCode
+}
+
+var systemErrors = &programErrorTable{
+	program: "system",
+	codes: []programErrorCode{
+		{name: "AccountAlreadyInUse", message: "an account with the same address already exists"},
+		{name: "ResultWithNegativeLamports", message: "account does not have enough SOL to perform the operation", insufficientFunds: true},
+		{name: "InvalidProgramId", message: "cannot assign account to this program id"},
+		{name: "InvalidAccountDataLength", message: "cannot allocate account data of this length"},
+		{name: "MaxSeedLengthExceeded", message: "length of requested seed is too long"},
+		{name: "AddressWithSeedMismatch", message: "provided address does not match addressed derived from seed"},
+		{name: "NonceNoRecentBlockhashes", message: "advancing stored nonce requires a populated RecentBlockhashes sysvar"},
+		{name: "NonceBlockhashNotExpired", message: "stored nonce is still in recent_blockhashes"},
+		{name: "NonceUnexpectedBlockhashValue", message: "specified nonce does not match stored nonce"},
+	},
+}
+
+// tokenErrors are shared by Token-2022, whose first codes match SPL Token.
+var tokenErrors = []programErrorCode{
+	{name: "NotRentExempt", message: "lamport balance below rent-exempt threshold"},
+	{name: "InsufficientFunds", message: "insufficient funds", insufficientFunds: true},
+	{name: "InvalidMint", message: "invalid mint"},
+	{name: "MintMismatch", message: "account not associated with this mint"},
+	{name: "OwnerMismatch", message: "owner does not match"},
+	{name: "FixedSupply", message: "fixed supply"},
+	{name: "AlreadyInUse", message: "already in use"},
+	{name: "InvalidNumberOfProvidedSigners", message: "invalid number of provided signers"},
+	{name: "InvalidNumberOfRequiredSigners", message: "invalid number of required signers"},
+	{name: "UninitializedState", message: "state is uninitialized"},
+	{name: "NativeNotSupported", message: "instruction does not support native tokens"},
+	{name: "NonNativeHasBalance", message: "non-native account can only be closed if its balance is zero"},
+	{name: "InvalidInstruction", message: "invalid instruction"},
+	{name: "InvalidState", message: "state is invalid for requested operation"},
+	{name: "Overflow", message: "operation overflowed"},
+	{name: "AuthorityTypeNotSupported", message: "account does not support specified authority type"},
+	{name: "MintCannotFreeze", message: "this token mint cannot freeze accounts"},
+	{name: "AccountFrozen", message: "account is frozen"},
+	{name: "MintDecimalsMismatch", message: "the provided decimals value different from the mint decimals"},
+	{name: "NonNativeNotSupported", message: "instruction does not support non-native tokens"},
+}
+
+var associatedTokenAccountErrors = &programErrorTable{
+	program: "associated-token-account",
+	codes: []programErrorCode{
+		{name: "InvalidOwner", message: "associated token account owner does not match address derivation"},
+	},
+}
+
+var tokenMetadataErrors = &programErrorTable{
+	program: "token-metadata",
+	codes: []programErrorCode{
+		{name: "InstructionUnpackError", message: "failed to unpack instruction data"},
+		{name: "InstructionPackError", message: "failed to pack instruction data"},
+		{name: "NotRentExempt", message: "lamport balance below rent-exempt threshold"},
+		{name: "AlreadyInitialized", message: "already initialized"},
+		{name: "Uninitialized", message: "uninitialized"},
+		{name: "InvalidMetadataKey", message: "metadata's key must match seed of ['metadata', program id, mint] provided"},
+		{name: "InvalidEditionKey", message: "edition's key must match seed of ['metadata', program id, name, 'edition'] provided"},
+		{name: "UpdateAuthorityIncorrect", message: "update authority given does not match"},
+		{name: "UpdateAuthorityIsNotSigner", message: "update authority needs to be signer to update metadata"},
+		{name: "NotMintAuthority", message: "you must be the mint authority and signer on this transaction"},
+		{name: "InvalidMintAuthority", message: "mint authority provided does not match the authority on the mint"},
+		{name: "NameTooLong", message: "name too long"},
+		{name: "SymbolTooLong", message: "symbol too long"},
+		{name: "UriTooLong", message: "URI too long"},
+		{name: "UpdateAuthorityMustBeEqualToMetadataAuthorityAndSigner", message: "update authority must be equivalent to the metadata's authority and also signer of this transaction"},
+		{name: "MintMismatch", message: "mint given does not match mint on metadata"},
+		{name: "EditionsMustHaveExactlyOneToken", message: "editions must have exactly one token"},
+		{name: "MaxEditionsMintedAlready", message: "maximum editions printed already"},
+		{name: "TokenMintToFailed", message: "token mint to failed"},
+		{name: "MasterRecordMismatch", message: "the master edition record passed must match the master record on the edition given"},
+		{name: "DestinationMintMismatch", message: "the destination account does not have the right mint"},
+		{name: "EditionAlreadyMinted", message: "an edition can only mint one of its kind"},
+		{name: "PrintingMintDecimalsShouldBeZero", message: "printing mint decimals should be zero"},
+		{name: "OneTimePrintingAuthorizationMintDecimalsShouldBeZero", message: "one time printing authorization mint decimals should be zero"},
+		{name: "EditionMintDecimalsShouldBeZero", message: "edition mint decimals should be zero"},
+		{name: "TokenBurnFailed", message: "token burn failed"},
+		{name: "TokenAccountOneTimeAuthMintMismatch", message: "the one time authorization mint does not match that on the token account"},
+		{name: "DerivedKeyInvalid", message: "derived key invalid"},
+		{name: "PrintingMintMismatch", message: "the printing mint does not match that on the master edition"},
+		{name: "OneTimePrintingAuthMintMismatch", message: "the one time printing auth mint does not match that on the master edition"},
+		{name: "TokenAccountMintMismatch", message: "the mint of the token account does not match the printing mint"},
+		{name: "TokenAccountMintMismatchV2", message: "the mint of the token account does not match the master metadata mint"},
+		{name: "NotEnoughTokens", message: "not enough tokens to mint a limited edition", insufficientFunds: true},
+		{name: "PrintingMintAuthorizationAccountMismatch", message: "the mint on your authorization token holding account does not match your printing mint"},
+		{name: "AuthorizationTokenAccountOwnerMismatch", message: "the authorization token account has a different owner than the update authority for the master edition"},
+		{name: "Disabled", message: "this feature is currently disabled"},
+		{name: "CreatorsTooLong", message: "creators list too long"},
+		{name: "CreatorsMustBeAtleastOne", message: "creators must be at least one if set"},
+		{name: "MustBeOneOfCreators", message: "if using a creators array, you must be one of the creators listed"},
+		{name: "NoCreatorsPresentOnMetadata", message: "this metadata does not have creators"},
+		{name: "CreatorNotFound", message: "this creator address was not found"},
+		{name: "InvalidBasisPoints", message: "basis points cannot be more than 10000"},
+		{name: "PrimarySaleCanOnlyBeFlippedToTrue", message: "primary sale can only be flipped to true and is immutable"},
+		{name: "OwnerMismatch", message: "owner does not match that on the account given"},
+		{name: "NoBalanceInAccountForAuthorization", message: "this account has no tokens to be used for authorization"},
+		{name: "ShareTotalMustBe100", message: "share total must equal 100 for creator array"},
+	},
+}
+
+var programErrorTables = map[common.PublicKey]*programErrorTable{
+	common.SystemProgramID:                    systemErrors,
+	common.TokenProgramID:                     {program: "token", codes: tokenErrors},
+	common.Token2022ProgramID:                 {program: "token-2022", codes: tokenErrors},
+	common.SPLAssociatedTokenAccountProgramID: associatedTokenAccountErrors,
+	common.MetaplexTokenMetaProgramID:         tokenMetadataErrors,
+}
+
+// programFailedLog matches the log line of a failing program. Programs called
+// by the failing instruction log first, so the first match is the program that
+// returned the error.
+var programFailedLog = regexp.MustCompile(`^Program (\w+) failed: `)
+
+// decodeProgramError turns the instruction error of an RPC transaction error
+// into a *ProgramError, and returns nil for other transaction errors. The
+// program comes from the logs when they name it, since the error may come
+// from a program the instruction called, and from programIDs otherwise.
+func decodeProgramError(txErr any, programIDs []common.PublicKey, logs []string) *ProgramError {
+	v, ok := txErr.(map[string]any)
+	if !ok {
+		return nil
+	}
+	instructionError, ok := v["InstructionError"].([]any)
+	if !ok || len(instructionError) != 2 {
+		return nil
+	}
+	index, ok := instructionError[0].(float64)
+	if !ok {
+		return nil
+	}
+
+	res := &ProgramError{Instruction: int(index)}
+
+	var programID common.PublicKey
+	if int(index) < len(programIDs) {
+		programID = programIDs[int(index)]
+		res.ProgramID = programID.ToBase58()
+	}
+	for _, line := range logs {
+		if match := programFailedLog.FindStringSubmatch(line); match != nil {
+			programID = common.PublicKeyFromString(match[1])
+			res.ProgramID = match[1]
+			break
+		}
+	}
+
+	table := programErrorTables[programID]
+	if table != nil {
+		res.Program = table.program
+	}
+
+	custom, isCustom := instructionError[1].(map[string]any)
+	code, hasCode := custom["Custom"].(float64)
+	if !isCustom || !hasCode {
+		// Built-in errors such as InvalidAccountData are named already.
+		res.Message = formatInstructionError(instructionError[1])
+		res.Name = res.Message
+
+		return res
+	}
+
+	customCode := uint32(code)
+	res.Code = &customCode
+	res.Message = fmt.Sprintf("custom program error 0x%x", customCode)
+	if table != nil && int(customCode) < len(table.codes) {
+		known := table.codes[customCode]
+		res.Name = known.name
+		res.Message = known.message
+		res.insufficientFunds = known.insufficientFunds
+	}
+
+	return res
+}
+
+// describeTransactionError renders a transaction error, decoding the program
+// error when there is one.
+func describeTransactionError(txErr any, programIDs []common.PublicKey, logs []string) string {
+	if programErr := decodeProgramError(txErr, programIDs, logs); programErr != nil {
+		return programErr.Detail()
+	}
+
+	return formatTransactionError(txErr)
+}
+
+// preflightProgramError decodes the program error of a transaction rejected
+// by preflight simulation, which the RPC returns as a JSON-RPC error with the
+// transaction error and logs in its data.
+func preflightProgramError(err error, programIDs []common.PublicKey) *ProgramError {
+	var rpcErr *rpc.JsonRpcError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != rpcSimulationFailed {
+		return nil
+	}
+
+	data, ok := rpcErr.Data.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	var logs []string
+	if values, ok := data["logs"].([]any); ok {
+		for _, value := range values {
+			if line, ok := value.(string); ok {
+				logs = append(logs, line)
+			}
+		}
+	}
+
+	return decodeProgramError(data["err"], programIDs, logs)
+}
+
+func instructionProgramIDs(instructions []types.Instruction) []common.PublicKey {
+	res := make([]common.PublicKey, len(instructions))
+	for i, instruction := range instructions {
+		res[i] = instruction.ProgramID
+	}
+
+	return res
+}
+
+func messageProgramIDs(message types.Message) []common.PublicKey {
+	res := make([]common.PublicKey, len(message.Instructions))
+	for i, instruction := range message.Instructions {
+		if instruction.ProgramIDIndex < len(message.Accounts) {
+			res[i] = message.Accounts[instruction.ProgramIDIndex]
+		}
+	}
+
+	return res
+}
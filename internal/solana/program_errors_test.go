@@ -0,0 +1,75 @@
+package solana
+
+import (
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func instructionError(index int, err any) map[string]any {
+	return map[string]any{"InstructionError": []any{float64(index), err}}
+}
+
+func Test_decodeProgramError(t *testing.T) {
+	programIDs := []common.PublicKey{common.ComputeBudgetProgramID, common.TokenProgramID}
+
+	res := decodeProgramError(instructionError(1, map[string]any{"Custom": float64(1)}), programIDs, nil)
+	require.NotNil(t, res)
+	require.Equal(t, "token", res.Program)
+	require.Equal(t, "InsufficientFunds", res.Name)
+	require.Equal(t, ErrorKindInsufficientFunds, res.Kind())
+	require.Equal(t, "instruction 1 (token): insufficient funds (InsufficientFunds, custom program error 0x1)", res.Detail())
+
+	res.Signature = "sig"
+	require.Equal(t, "transaction sig failed: instruction 1 (token): insufficient funds (InsufficientFunds, custom program error 0x1)", res.Error())
+
+	// The ATA program fails with the error of the system program it called.
+	logs := []string{
+		"Program " + common.SPLAssociatedTokenAccountProgramID.ToBase58() + " invoke [1]",
+		"Program 11111111111111111111111111111111 invoke [2]",
+		"Allocate: account Address { address: x, base: None } already in use",
+		"Program 11111111111111111111111111111111 failed: custom program error: 0x0",
+		"Program " + common.SPLAssociatedTokenAccountProgramID.ToBase58() + " failed: custom program error: 0x0",
+	}
+	res = decodeProgramError(instructionError(0, map[string]any{"Custom": float64(0)}),
+		[]common.PublicKey{common.SPLAssociatedTokenAccountProgramID}, logs)
+	require.NotNil(t, res)
+	require.Equal(t, "system", res.Program)
+	require.Equal(t, "AccountAlreadyInUse", res.Name)
+	require.Equal(t, ErrorKindTransactionFailed, res.Kind())
+
+	res = decodeProgramError(instructionError(0, map[string]any{"Custom": float64(7)}),
+		[]common.PublicKey{common.MetaplexTokenMetaProgramID}, nil)
+	require.Equal(t, "UpdateAuthorityIncorrect", res.Name)
+
+	// Unknown programs keep the raw code.
+	res = decodeProgramError(instructionError(0, map[string]any{"Custom": float64(6000)}), []common.PublicKey{{1}}, nil)
+	require.Empty(t, res.Program)
+	require.Empty(t, res.Name)
+	require.Contains(t, res.Detail(), "custom program error 0x1770")
+
+	res = decodeProgramError(instructionError(0, "InvalidAccountData"), programIDs, nil)
+	require.Equal(t, "instruction 0 ("+common.ComputeBudgetProgramID.ToBase58()+"): InvalidAccountData", res.Detail())
+
+	require.Nil(t, decodeProgramError("BlockhashNotFound", programIDs, nil))
+}
+
+func Test_preflightProgramError(t *testing.T) {
+	err := errors.Wrap(&rpc.JsonRpcError{
+		Code:    rpcSimulationFailed,
+		Message: "Transaction simulation failed: Error processing Instruction 0: custom program error: 0x4",
+		Data: map[string]any{
+			"err":  instructionError(0, map[string]any{"Custom": float64(4)}),
+			"logs": []any{"Program " + common.TokenProgramID.ToBase58() + " failed: custom program error: 0x4"},
+		},
+	}, "send")
+
+	res := preflightProgramError(err, []common.PublicKey{common.TokenProgramID})
+	require.NotNil(t, res)
+	require.Equal(t, "OwnerMismatch", res.Name)
+
+	require.Nil(t, preflightProgramError(errors.New("rpc: call error"), nil))
+}
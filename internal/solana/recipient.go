@@ -0,0 +1,197 @@
+package solana
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+type RecipientKind string
+
+const (
+	RecipientWallet       RecipientKind = "wallet"
+	RecipientTokenAccount RecipientKind = "token_account"
+	RecipientMint         RecipientKind = "mint"
+	RecipientProgram      RecipientKind = "program"
+	RecipientPDA          RecipientKind = "pda"
+	RecipientNonexistent  RecipientKind = "nonexistent"
+	// RecipientProgramAccount is a data account of some other program.
+	RecipientProgramAccount RecipientKind = "program_account"
+)
+
+type Recipient struct {
+	Address  string        `json:"address"`
+	Kind     RecipientKind `json:"kind"`
+	Owner    string        `json:"owner,omitempty"`
+	Lamports uint64        `json:"lamports"`
+	// TokenMint and TokenOwner are set for token accounts.
+	TokenMint  string   `json:"token_mint,omitempty"`
+	TokenOwner string   `json:"token_owner,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+
+	pubKey common.PublicKey
+}
+
+// limitAddresses are the addresses whose contact limits apply to a transfer:
+// the recipient itself and, for a token account, the owner receiving what is
+// sent to it.
+func (r *Recipient) limitAddresses() []string {
+	if r.Kind == RecipientTokenAccount && r.TokenOwner != r.Address {
+		return []string{r.Address, r.TokenOwner}
+	}
+
+	return []string{r.Address}
+}
+
+// limitTransfers maps the limit addresses of the recipient to the amount.
+func (r *Recipient) limitTransfers(amount uint64) map[string]uint64 {
+	res := map[string]uint64{}
+	for _, address := range r.limitAddresses() {
+		res[address] = amount
+	}
+
+	return res
+}
+
+// ParseAddress decodes a base58 account address, rejecting anything that is
+// not exactly 32 bytes.
+func ParseAddress(address string) (common.PublicKey, error) {
+	decoded, err := base58.Decode(address)
+	if err != nil {
+		return common.PublicKey{}, errorf(ErrorKindInvalidInput, "invalid address %q: not base58", address)
+	}
+	if len(decoded) != common.PublicKeyLength {
+		return common.PublicKey{}, errorf(ErrorKindInvalidInput, "invalid address %q: %d bytes instead of %d", address, len(decoded), common.PublicKeyLength)
+	}
+
+	return common.PublicKeyFromBytes(decoded), nil
+}
+
+// ResolveRecipient classifies a transfer target. An empty token mint checks
+// the recipient for a SOL transfer. Targets that cannot receive the asset are
+// rejected, the dubious ones come back with warnings.
+func (m *Module) ResolveRecipient(ctx context.Context, address string, tokenMint string) (*Recipient, error) {
+	_, span := tracer.Start(ctx, "internal.solana.ResolveRecipient")
+	defer span.End()
+
+	recipients, err := m.resolveRecipients(ctx, []string{address}, tokenMint)
+	if err != nil {
+		return nil, err
+	}
+
+	return recipients[0], nil
+}
+
+func (m *Module) resolveRecipients(ctx context.Context, addresses []string, tokenMint string) ([]*Recipient, error) {
+	pubKeys := make([]common.PublicKey, len(addresses))
+	for i, address := range addresses {
+		pubKey, err := ParseAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		pubKeys[i] = pubKey
+	}
+
+	accounts, err := m.getMultipleAccounts(ctx, addresses)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get recipient accounts")
+	}
+
+	res := make([]*Recipient, len(addresses))
+	for i := range addresses {
+		recipient := classifyRecipient(pubKeys[i], accounts[i])
+		if err := recipient.check(tokenMint); err != nil {
+			return nil, err
+		}
+		res[i] = recipient
+	}
+
+	return res, nil
+}
+
+func classifyRecipient(pubKey common.PublicKey, account *client.AccountInfo) *Recipient {
+	res := &Recipient{
+		Address: pubKey.ToBase58(),
+		pubKey:  pubKey,
+	}
+
+	if account == nil {
+		res.Kind = RecipientNonexistent
+		if !common.IsOnCurve(pubKey) {
+			res.Kind = RecipientPDA
+		}
+
+		return res
+	}
+
+	res.Owner = account.Owner.ToBase58()
+	res.Lamports = account.Lamports
+
+	switch {
+	case account.Executable:
+		res.Kind = RecipientProgram
+	case isTokenProgram(account.Owner) && isTokenAccountData(account.Data):
+		res.Kind = RecipientTokenAccount
+		res.TokenMint = common.PublicKeyFromBytes(account.Data[:32]).ToBase58()
+		res.TokenOwner = common.PublicKeyFromBytes(account.Data[32:64]).ToBase58()
+	case isTokenProgram(account.Owner) && len(account.Data) >= token.MintAccountSize:
+		res.Kind = RecipientMint
+	case !common.IsOnCurve(pubKey):
+		res.Kind = RecipientPDA
+	case account.Owner == common.SystemProgramID:
+		res.Kind = RecipientWallet
+	default:
+		res.Kind = RecipientProgramAccount
+	}
+
+	return res
+}
+
+// check applies the transfer rules for the asset: SPL tokens are never sent
+// to mints, programs or token accounts of another mint.
+func (r *Recipient) check(tokenMint string) error {
+	switch r.Kind {
+	case RecipientMint, RecipientProgram:
+		if tokenMint != "" {
+			return errorf(ErrorKindInvalidInput, "recipient %s is a %s and cannot hold tokens", r.Address, r.Kind)
+		}
+		r.Warnings = append(r.Warnings, fmt.Sprintf("recipient is a %s, SOL sent to it is most likely lost", r.Kind))
+	case RecipientTokenAccount:
+		if tokenMint == "" {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("recipient is a token account of %s, not a wallet", r.TokenOwner))
+			break
+		}
+		if r.TokenMint != tokenMint {
+			return errorf(ErrorKindInvalidInput, "recipient %s is a token account of mint %s, not %s", r.Address, r.TokenMint, tokenMint)
+		}
+		r.Warnings = append(r.Warnings, fmt.Sprintf("recipient is a token account of %s, tokens are sent to it directly", r.TokenOwner))
+	case RecipientPDA:
+		r.Warnings = append(r.Warnings, "recipient is off-curve (a program derived address), only its program can move the funds")
+	case RecipientProgramAccount:
+		r.Warnings = append(r.Warnings, fmt.Sprintf("recipient is a data account of program %s, not a wallet", r.Owner))
+	}
+
+	return nil
+}
+
+// tokenDestination is the token account receiving tokens for the recipient:
+// the recipient itself when it already is one, its ATA otherwise.
+func (r *Recipient) tokenDestination(mint common.PublicKey) (common.PublicKey, bool, error) {
+	if r.Kind == RecipientTokenAccount {
+		return r.pubKey, true, nil
+	}
+
+	ata, _, err := common.FindAssociatedTokenAddress(r.pubKey, mint)
+	if err != nil {
+		return common.PublicKey{}, false, errors.Wrapf(err, "failed to find recipient token account for %s", r.Address)
+	}
+
+	return ata, false, nil
+}
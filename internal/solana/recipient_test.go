@@ -0,0 +1,102 @@
+package solana
+
+import (
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseAddress(t *testing.T) {
+	wallet := types.NewAccount().PublicKey
+
+	pubKey, err := ParseAddress(wallet.ToBase58())
+	require.NoError(t, err)
+	require.Equal(t, wallet, pubKey)
+
+	_, err = ParseAddress("0OIl")
+	require.ErrorContains(t, err, "not base58")
+	_, err = ParseAddress(wallet.ToBase58()[:20])
+	require.ErrorContains(t, err, "bytes instead of 32")
+}
+
+func Test_classifyRecipient(t *testing.T) {
+	wallet := types.NewAccount().PublicKey
+	mint := types.NewAccount().PublicKey
+	pda, _, err := common.FindAssociatedTokenAddress(wallet, mint)
+	require.NoError(t, err)
+
+	tokenAccountData := make([]byte, token.TokenAccountSize)
+	copy(tokenAccountData, mint.Bytes())
+	copy(tokenAccountData[32:], wallet.Bytes())
+
+	tests := []struct {
+		name    string
+		pubKey  common.PublicKey
+		account *client.AccountInfo
+		kind    RecipientKind
+	}{
+		{"nonexistent", wallet, nil, RecipientNonexistent},
+		{"pda", pda, nil, RecipientPDA},
+		{"wallet", wallet, &client.AccountInfo{Owner: common.SystemProgramID, Lamports: 1}, RecipientWallet},
+		{"program", mint, &client.AccountInfo{Owner: common.BPFLoaderUpgradeableProgramID, Executable: true}, RecipientProgram},
+		{"mint", mint, &client.AccountInfo{Owner: common.TokenProgramID, Data: make([]byte, token.MintAccountSize)}, RecipientMint},
+		{"token account", pda, &client.AccountInfo{Owner: common.TokenProgramID, Data: tokenAccountData}, RecipientTokenAccount},
+		{"program account", wallet, &client.AccountInfo{Owner: common.StakeProgramID}, RecipientProgramAccount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.kind, classifyRecipient(tt.pubKey, tt.account).Kind)
+		})
+	}
+
+	recipient := classifyRecipient(pda, &client.AccountInfo{Owner: common.TokenProgramID, Data: tokenAccountData})
+	require.Equal(t, mint.ToBase58(), recipient.TokenMint)
+	require.Equal(t, wallet.ToBase58(), recipient.TokenOwner)
+}
+
+func Test_RecipientCheck(t *testing.T) {
+	mint := types.NewAccount().PublicKey.ToBase58()
+
+	require.Error(t, (&Recipient{Kind: RecipientMint}).check(mint))
+	require.Error(t, (&Recipient{Kind: RecipientProgram}).check(mint))
+	require.Error(t, (&Recipient{Kind: RecipientTokenAccount, TokenMint: types.NewAccount().PublicKey.ToBase58()}).check(mint))
+
+	recipient := &Recipient{Kind: RecipientMint}
+	require.NoError(t, recipient.check(""))
+	require.Len(t, recipient.Warnings, 1)
+
+	recipient = &Recipient{Kind: RecipientTokenAccount, TokenMint: mint}
+	require.NoError(t, recipient.check(mint))
+	require.Len(t, recipient.Warnings, 1)
+
+	recipient = &Recipient{Kind: RecipientWallet}
+	require.NoError(t, recipient.check(mint))
+	require.Empty(t, recipient.Warnings)
+}
+
+func Test_RecipientTokenDestination(t *testing.T) {
+	wallet := types.NewAccount().PublicKey
+	mint := types.NewAccount().PublicKey
+	ata, _, err := common.FindAssociatedTokenAddress(wallet, mint)
+	require.NoError(t, err)
+
+	destination, direct, err := classifyRecipient(wallet, nil).tokenDestination(mint)
+	require.NoError(t, err)
+	require.False(t, direct)
+	require.Equal(t, ata, destination)
+
+	tokenAccount := &Recipient{Kind: RecipientTokenAccount, pubKey: ata}
+	destination, direct, err = tokenAccount.tokenDestination(mint)
+	require.NoError(t, err)
+	require.True(t, direct)
+	require.Equal(t, ata, destination)
+}
+
+func Test_RecipientLimitAddresses(t *testing.T) {
+	require.Equal(t, []string{"wallet"}, (&Recipient{Address: "wallet", Kind: RecipientWallet}).limitAddresses())
+	require.Equal(t, []string{"ata", "owner"}, (&Recipient{Address: "ata", Kind: RecipientTokenAccount, TokenOwner: "owner"}).limitAddresses())
+}
@@ -0,0 +1,254 @@
+package solana
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/pkg/errors"
+)
+
+// DryRunError is returned in place of sending a transaction in dry-run mode.
+type DryRunError struct {
+	Report *SimulationReport
+}
+
+func (e *DryRunError) Error() string {
+	return "dry run: transaction simulated, not sent"
+}
+
+type BalanceChange struct {
+	Address string `json:"address"`
+	// Asset is "SOL" or the token mint.
+	Asset  string `json:"asset"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+	Change string `json:"change"`
+}
+
+type SimulationReport struct {
+	Success       bool             `json:"success"`
+	Error         string           `json:"error,omitempty"`
+	UnitsConsumed uint64           `json:"units_consumed"`
+	Fee           *TransactionFee  `json:"fee,omitempty"`
+	Logs          []string         `json:"logs"`
+	Balances      []*BalanceChange `json:"balances"`
+}
+
+// SetDryRun makes every transaction be simulated instead of sent.
+func (m *Module) SetDryRun(dryRun bool) {
+	m.dryRun = dryRun
+}
+
+func (m *Module) DryRun() bool {
+	return m.dryRun
+}
+
+// simulateTransaction runs the signed transaction and compares the SOL and
+// token balances of its writable accounts before and after.
+func (m *Module) simulateTransaction(ctx context.Context, tx *signedTransaction) (*SimulationReport, error) {
+	writable := messageWritableAccounts(tx.Transaction.Message)
+
+	addresses := make([]string, len(writable))
+	for i, account := range writable {
+		addresses[i] = account.ToBase58()
+	}
+
+	before, err := m.getMultipleAccounts(ctx, addresses)
+	if err != nil {
+		return nil, errors.Wrap(err, "get accounts before simulation")
+	}
+
+	res, err := m.solanaClient.SimulateTransactionWithConfig(ctx, tx.Transaction, client.SimulateTransactionConfig{
+		SigVerify:  true,
+		Commitment: m.config.commitment(),
+		Addresses:  addresses,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "simulate transaction")
+	}
+
+	report := &SimulationReport{
+		Success: res.Err == nil,
+		Logs:    res.Logs,
+	}
+	if res.Err != nil {
+		report.Error = describeTransactionError(res.Err, messageProgramIDs(tx.Transaction.Message), res.Logs)
+	}
+	if res.UnitConsumed != nil {
+		report.UnitsConsumed = *res.UnitConsumed
+	}
+
+	if report.Fee, err = m.messageFee(ctx, tx.Transaction.Message); err != nil {
+		return nil, err
+	}
+
+	after := make([]*client.AccountInfo, len(addresses))
+	if res.Err == nil {
+		if len(res.Accounts) != len(addresses) {
+			return nil, errors.Errorf("simulation returned %d accounts, requested %d", len(res.Accounts), len(addresses))
+		}
+		copy(after, res.Accounts)
+	} else {
+		// A failed transaction only pays the fee.
+		copy(after, before)
+	}
+
+	if report.Balances, err = m.balanceChanges(ctx, addresses, before, after); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// getMultipleAccounts returns nil for accounts that do not exist, which are
+// the ones without lamports: the owner of a missing account reads as the
+// System program. Chunks of getMultipleAccountsLimit addresses are fetched
+// concurrently.
+func (m *Module) getMultipleAccounts(ctx context.Context, addresses []string) ([]*client.AccountInfo, error) {
+	return m.fetchMultipleAccounts(ctx, addresses, m.config.RpcConcurrency)
+}
+
+// fetchMultipleAccounts is getMultipleAccounts with at most concurrency
+// chunks in flight, for callers already running concurrently themselves.
+func (m *Module) fetchMultipleAccounts(ctx context.Context, addresses []string, concurrency int) ([]*client.AccountInfo, error) {
+	res := make([]*client.AccountInfo, len(addresses))
+
+	chunks := (len(addresses) + getMultipleAccountsLimit - 1) / getMultipleAccountsLimit
+	err := runConcurrently(chunks, concurrency, func(chunk int) error {
+		start := chunk * getMultipleAccountsLimit
+		end := min(start+getMultipleAccountsLimit, len(addresses))
+
+		accounts, err := m.solanaClient.GetMultipleAccounts(ctx, addresses[start:end])
+		if err != nil {
+			return err
+		}
+
+		for i := range accounts {
+			if accounts[i].Lamports > 0 {
+				res[start+i] = &accounts[i]
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (m *Module) balanceChanges(ctx context.Context, addresses []string, before, after []*client.AccountInfo) ([]*BalanceChange, error) {
+	var res []*BalanceChange
+	for i, address := range addresses {
+		res = append(res, &BalanceChange{
+			Address: address,
+			Asset:   "SOL",
+			Before:  formatTokenAmount(accountLamports(before[i]), 9),
+			After:   formatTokenAmount(accountLamports(after[i]), 9),
+			Change:  formatTokenAmountChange(accountLamports(before[i]), accountLamports(after[i]), 9),
+		})
+
+		mint, beforeAmount, afterAmount, ok := tokenBalanceChange(before[i], after[i])
+		if !ok {
+			continue
+		}
+
+		mintDecimals, err := m.simulatedMintDecimals(ctx, mint, addresses, after)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get decimals of %s", mint)
+		}
+
+		res = append(res, &BalanceChange{
+			Address: address,
+			Asset:   mint,
+			Before:  formatTokenAmount(beforeAmount, mintDecimals),
+			After:   formatTokenAmount(afterAmount, mintDecimals),
+			Change:  formatTokenAmountChange(beforeAmount, afterAmount, mintDecimals),
+		})
+	}
+
+	return res, nil
+}
+
+// mintDecimalsOffset is shared by both token programs.
+const mintDecimalsOffset = 44
+
+// simulatedMintDecimals prefers the simulated mint state, so mints created by
+// the transaction are covered.
+func (m *Module) simulatedMintDecimals(ctx context.Context, mint string, addresses []string, after []*client.AccountInfo) (uint8, error) {
+	for i, address := range addresses {
+		if address == mint && after[i] != nil && len(after[i].Data) >= token.MintAccountSize {
+			return after[i].Data[mintDecimalsOffset], nil
+		}
+	}
+
+	supply, err := m.solanaClient.GetTokenSupply(ctx, mint)
+	if err != nil {
+		return 0, err
+	}
+
+	return supply.Decimals, nil
+}
+
+func accountLamports(account *client.AccountInfo) uint64 {
+	if account == nil {
+		return 0
+	}
+
+	return account.Lamports
+}
+
+// tokenBalanceChange reads the token amount before and after when either
+// side is a token account.
+func tokenBalanceChange(before, after *client.AccountInfo) (string, uint64, uint64, bool) {
+	var (
+		mint                      string
+		beforeAmount, afterAmount uint64
+		found                     bool
+	)
+	if before != nil && isTokenProgram(before.Owner) && isTokenAccountData(before.Data) {
+		mint = common.PublicKeyFromBytes(before.Data[:32]).ToBase58()
+		beforeAmount = binary.LittleEndian.Uint64(before.Data[64:72])
+		found = true
+	}
+	if after != nil && isTokenProgram(after.Owner) && isTokenAccountData(after.Data) {
+		mint = common.PublicKeyFromBytes(after.Data[:32]).ToBase58()
+		afterAmount = binary.LittleEndian.Uint64(after.Data[64:72])
+		found = true
+	}
+
+	return mint, beforeAmount, afterAmount, found
+}
+
+func isTokenProgram(owner common.PublicKey) bool {
+	return owner == common.TokenProgramID || owner == common.Token2022ProgramID
+}
+
+// isTokenAccountData tells token accounts from mints, Token-2022 accounts
+// with extensions carrying their account type after the base layout.
+func isTokenAccountData(data []byte) bool {
+	return len(data) == token.TokenAccountSize ||
+		len(data) > token2022AccountTypeOffset && data[token2022AccountTypeOffset] == token2022AccountTypeAccount
+}
+
+// messageWritableAccounts lists the accounts the message may modify.
+func messageWritableAccounts(message types.Message) []common.PublicKey {
+	var (
+		res      []common.PublicKey
+		signers  = int(message.Header.NumRequireSignatures)
+		readonly = int(message.Header.NumReadonlySignedAccounts)
+		unsigned = int(message.Header.NumReadonlyUnsignedAccounts)
+	)
+	for i, account := range message.Accounts {
+		if i < signers && i < signers-readonly || i >= signers && i < len(message.Accounts)-unsigned {
+			res = append(res, account)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,69 @@
+package solana
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_messageWritableAccounts(t *testing.T) {
+	from, to := types.NewAccount().PublicKey, types.NewAccount().PublicKey
+
+	message := types.NewMessage(types.NewMessageParam{
+		FeePayer:        from,
+		RecentBlockhash: common.PublicKey{}.ToBase58(),
+		Instructions: []types.Instruction{
+			system.Transfer(system.TransferParam{From: from, To: to, Amount: 1}),
+		},
+	})
+
+	require.Equal(t, []common.PublicKey{from, to}, messageWritableAccounts(message))
+}
+
+func Test_tokenBalanceChange(t *testing.T) {
+	mint := types.NewAccount().PublicKey
+	tokenAccount := func(amount uint64) *client.AccountInfo {
+		data := make([]byte, token.TokenAccountSize)
+		copy(data, mint.Bytes())
+		binary.LittleEndian.PutUint64(data[64:], amount)
+
+		return &client.AccountInfo{Owner: common.TokenProgramID, Data: data}
+	}
+
+	gotMint, before, after, ok := tokenBalanceChange(tokenAccount(10), tokenAccount(4))
+	require.True(t, ok)
+	require.Equal(t, mint.ToBase58(), gotMint)
+	require.Equal(t, uint64(10), before)
+	require.Equal(t, uint64(4), after)
+
+	// Created by the transaction.
+	_, before, after, ok = tokenBalanceChange(nil, tokenAccount(7))
+	require.True(t, ok)
+	require.Zero(t, before)
+	require.Equal(t, uint64(7), after)
+
+	mintAccount := &client.AccountInfo{Owner: common.TokenProgramID, Data: make([]byte, token.MintAccountSize)}
+	_, _, _, ok = tokenBalanceChange(mintAccount, mintAccount)
+	require.False(t, ok)
+
+	_, _, _, ok = tokenBalanceChange(&client.AccountInfo{Owner: common.SystemProgramID}, nil)
+	require.False(t, ok)
+}
+
+func Test_isTokenAccountData(t *testing.T) {
+	require.True(t, isTokenAccountData(make([]byte, token.TokenAccountSize)))
+	require.False(t, isTokenAccountData(make([]byte, token.MintAccountSize)))
+
+	extended := make([]byte, token.TokenAccountSize+10)
+	extended[token2022AccountTypeOffset] = token2022AccountTypeAccount
+	require.True(t, isTokenAccountData(extended))
+
+	extended[token2022AccountTypeOffset] = token2022AccountTypeMint
+	require.False(t, isTokenAccountData(extended))
+}
@@ -0,0 +1,536 @@
+package solana
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/stake"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+type StakeOperation string
+
+const (
+	StakeCreate     StakeOperation = "create"
+	StakeDelegate   StakeOperation = "delegate"
+	StakeDeactivate StakeOperation = "deactivate"
+	StakeWithdraw   StakeOperation = "withdraw"
+	StakeSplit      StakeOperation = "split"
+	StakeMerge      StakeOperation = "merge"
+)
+
+const (
+	StakeStateInitialized  = "initialized"
+	StakeStateActivating   = "activating"
+	StakeStateActive       = "active"
+	StakeStateDeactivating = "deactivating"
+	StakeStateInactive     = "inactive"
+)
+
+type StakeRequest struct {
+	Operation        StakeOperation
+	OwnerKeyFilename string
+	// StakeAddress is the account operated on, the destination of a merge.
+	// Create and Split make a new account instead.
+	StakeAddress string
+	// VoteAddress is the validator of Delegate, and of Create when set.
+	VoteAddress string
+	// AmountLamports is funded by Create, withdrawn by Withdraw and moved to
+	// the new account by Split.
+	AmountLamports uint64
+	// Max withdraws the whole stake account balance.
+	Max bool
+	// Seed derives the new account of Create and Split from the owner key,
+	// a fresh key is used otherwise.
+	Seed string
+	// SourceAddress is merged into StakeAddress and closed.
+	SourceAddress string
+	// ToAddress receives a withdrawal, the owner by default.
+	ToAddress string
+}
+
+type StakeEstimate struct {
+	*TransactionFee
+	// StakeAddress is the account operated on, or the new one of Create and
+	// Split.
+	StakeAddress   string `json:"stake_address"`
+	AmountLamports uint64 `json:"amount_lamports,omitempty"`
+	RentLamports   uint64 `json:"rent_lamports,omitempty"`
+}
+
+type StakeResult struct {
+	TransactionResult
+	Operation      StakeOperation `json:"operation"`
+	StakeAddress   string         `json:"stake_address"`
+	AmountLamports uint64         `json:"amount_lamports,omitempty"`
+}
+
+// EstimateStake prices the operation, priority fee included, and resolves
+// the stake account address.
+func (m *Module) EstimateStake(ctx context.Context, req *StakeRequest) (*StakeEstimate, error) {
+	_, span := tracer.Start(ctx, "internal.solana.EstimateStake")
+	defer span.End()
+
+	ownerAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load owner account")
+	}
+
+	_, _, estimate, err := m.planStake(ctx, req, ownerAccount, types.NewAccount())
+	if err != nil {
+		return nil, err
+	}
+	// The fresh key of the real run is not known yet.
+	if req.Seed == "" && (req.Operation == StakeCreate || req.Operation == StakeSplit) {
+		estimate.StakeAddress = ""
+	}
+
+	return estimate, nil
+}
+
+// Stake runs the operation and returns the stake account address.
+func (m *Module) Stake(ctx context.Context, req *StakeRequest) (*StakeResult, error) {
+	_, span := tracer.Start(ctx, "internal.solana.Stake")
+	defer span.End()
+
+	ownerAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load owner account")
+	}
+
+	instructions, signers, estimate, err := m.planStake(ctx, req, ownerAccount, types.NewAccount())
+	if err != nil {
+		return nil, err
+	}
+	m.log.Info(ctx, "estimated transaction fee", estimate.TotalLamports, "priority_fee", estimate.PriorityFee)
+
+	confirmation, err := m.sendAndConfirm(ctx, signers, ownerAccount.PublicKey, instructions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StakeResult{
+		TransactionResult: TransactionResult{Signature: confirmation.Signature, FeeLamports: estimate.TotalLamports},
+		Operation:         req.Operation,
+		StakeAddress:      estimate.StakeAddress,
+		AmountLamports:    estimate.AmountLamports,
+	}, nil
+}
+
+// planStake builds the operation with the owner as fee payer and both stake
+// authorities. newAccount becomes the new stake account when no seed is set.
+func (m *Module) planStake(ctx context.Context, req *StakeRequest, ownerAccount *types.Account, newAccount types.Account) ([]types.Instruction, []types.Account, *StakeEstimate, error) {
+	owner := ownerAccount.PublicKey
+	signers := []types.Account{*ownerAccount}
+	estimate := &StakeEstimate{StakeAddress: req.StakeAddress}
+
+	newStakeAccount := func() (common.PublicKey, types.Instruction, error) {
+		rent, err := m.solanaClient.GetMinimumBalanceForRentExemption(ctx, stake.AccountSize)
+		if err != nil {
+			return common.PublicKey{}, types.Instruction{}, errors.Wrap(err, "failed to get rent-exempt minimum")
+		}
+		estimate.RentLamports = rent
+
+		lamports := rent
+		if req.Operation == StakeCreate {
+			if req.AmountLamports < rent {
+				return common.PublicKey{}, types.Instruction{}, errorf(ErrorKindInvalidInput, "%d lamports is below the stake account rent-exempt minimum of %d lamports", req.AmountLamports, rent)
+			}
+			lamports = req.AmountLamports
+		}
+
+		if req.Seed == "" {
+			signers = append(signers, newAccount)
+			estimate.StakeAddress = newAccount.PublicKey.ToBase58()
+
+			return newAccount.PublicKey, system.CreateAccount(system.CreateAccountParam{
+				From:     owner,
+				New:      newAccount.PublicKey,
+				Owner:    common.StakeProgramID,
+				Lamports: lamports,
+				Space:    stake.AccountSize,
+			}), nil
+		}
+
+		address := common.CreateWithSeed(owner, req.Seed, common.StakeProgramID)
+		existing, err := m.solanaClient.GetAccountInfo(ctx, address.ToBase58())
+		if err != nil {
+			return common.PublicKey{}, types.Instruction{}, errors.Wrap(err, "failed to get stake account")
+		}
+		if existing.Lamports > 0 {
+			return common.PublicKey{}, types.Instruction{}, errorf(ErrorKindInvalidInput, "stake account %s for seed %q already exists", address.ToBase58(), req.Seed)
+		}
+		estimate.StakeAddress = address.ToBase58()
+
+		return address, system.CreateAccountWithSeed(system.CreateAccountWithSeedParam{
+			From:     owner,
+			New:      address,
+			Base:     owner,
+			Owner:    common.StakeProgramID,
+			Seed:     req.Seed,
+			Lamports: lamports,
+			Space:    stake.AccountSize,
+		}), nil
+	}
+
+	var (
+		instructions []types.Instruction
+		spent        uint64
+	)
+	switch req.Operation {
+	case StakeCreate:
+		address, create, err := newStakeAccount()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		instructions = append(instructions, create, stake.Initialize(stake.InitializeParam{
+			Stake: address,
+			Auth:  stake.Authorized{Staker: owner, Withdrawer: owner},
+		}))
+		if req.VoteAddress != "" {
+			vote, err := ParseAddress(req.VoteAddress)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			instructions = append(instructions, stake.DelegateStake(stake.DelegateStakeParam{Stake: address, Auth: owner, Vote: vote}))
+		}
+		estimate.AmountLamports = req.AmountLamports
+		spent = req.AmountLamports
+
+	case StakeDelegate:
+		address, state, err := m.ownedStakeAccount(ctx, req.StakeAddress, owner)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		vote, err := ParseAddress(req.VoteAddress)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		instructions = append(instructions, stake.DelegateStake(stake.DelegateStakeParam{Stake: address, Auth: owner, Vote: vote}))
+		estimate.AmountLamports = state.lamports - state.rentExemptReserve
+
+	case StakeDeactivate:
+		address, state, err := m.ownedStakeAccount(ctx, req.StakeAddress, owner)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if state.kind != stakeKindDelegated || state.deactivationEpoch != math.MaxUint64 {
+			return nil, nil, nil, errorf(ErrorKindInvalidInput, "stake account %s is not delegated or already deactivating", req.StakeAddress)
+		}
+		instructions = append(instructions, stake.Deactivate(stake.DeactivateParam{Stake: address, Auth: owner}))
+		estimate.AmountLamports = state.delegatedLamports
+
+	case StakeWithdraw:
+		address, state, err := m.ownedStakeAccount(ctx, req.StakeAddress, owner)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		to := owner
+		if req.ToAddress != "" {
+			if to, err = ParseAddress(req.ToAddress); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		amount := req.AmountLamports
+		if req.Max {
+			amount = state.lamports
+		}
+		if amount == 0 {
+			return nil, nil, nil, errorf(ErrorKindInvalidInput, "nothing to withdraw from stake account %s", req.StakeAddress)
+		}
+		if amount > state.lamports {
+			return nil, nil, nil, &InsufficientBalanceError{Address: req.StakeAddress, Asset: AssetSOL, Purpose: "stake withdrawal", Have: state.lamports, Need: amount}
+		}
+		instructions = append(instructions, stake.Withdraw(stake.WithdrawParam{Stake: address, Auth: owner, To: to, Lamports: amount}))
+		estimate.AmountLamports = amount
+
+	case StakeSplit:
+		address, state, err := m.ownedStakeAccount(ctx, req.StakeAddress, owner)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if req.AmountLamports == 0 {
+			return nil, nil, nil, errorf(ErrorKindInvalidInput, "split amount must be positive")
+		}
+		if req.AmountLamports >= state.lamports {
+			// The split leaves at least one lamport behind.
+			return nil, nil, nil, &InsufficientBalanceError{Address: req.StakeAddress, Asset: AssetSOL, Purpose: "stake split", Have: state.lamports, Need: req.AmountLamports + 1}
+		}
+		// The split destination has to be rent-exempt before the split.
+		splitAddress, create, err := newStakeAccount()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		instructions = append(instructions, create, stake.Split(stake.SplitParam{
+			Stake:      address,
+			Auth:       owner,
+			SplitStake: splitAddress,
+			Lamports:   req.AmountLamports,
+		}))
+		estimate.AmountLamports = req.AmountLamports
+		spent = estimate.RentLamports
+
+	case StakeMerge:
+		address, _, err := m.ownedStakeAccount(ctx, req.StakeAddress, owner)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		source, state, err := m.ownedStakeAccount(ctx, req.SourceAddress, owner)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if source == address {
+			return nil, nil, nil, errorf(ErrorKindInvalidInput, "cannot merge a stake account into itself")
+		}
+		instructions = append(instructions, stake.Merge(stake.MergeParam{From: source, Auth: owner, To: address}))
+		estimate.AmountLamports = state.lamports
+
+	default:
+		return nil, nil, nil, errors.Errorf("unsupported stake operation %q", req.Operation)
+	}
+
+	instructions, err := m.withComputeBudget(ctx, owner, instructions)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	fee, err := m.estimateFee(ctx, owner, instructions)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	estimate.TransactionFee = fee
+
+	balance, err := m.solanaClient.GetBalance(ctx, owner.ToBase58())
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "failed to get owner balance")
+	}
+	if balance < spent+fee.TotalLamports {
+		return nil, nil, nil, &InsufficientBalanceError{Address: owner.ToBase58(), Asset: AssetSOL, Purpose: fmt.Sprintf("stake %s and fees", req.Operation), Have: balance, Need: spent + fee.TotalLamports}
+	}
+
+	return instructions, signers, estimate, nil
+}
+
+// ownedStakeAccount loads a stake account the owner has both authorities of.
+func (m *Module) ownedStakeAccount(ctx context.Context, address string, owner common.PublicKey) (common.PublicKey, *stakeAccountState, error) {
+	pubKey, err := ParseAddress(address)
+	if err != nil {
+		return common.PublicKey{}, nil, err
+	}
+
+	account, err := m.solanaClient.GetAccountInfo(ctx, address)
+	if err != nil {
+		return common.PublicKey{}, nil, errors.Wrap(err, "failed to get stake account")
+	}
+	if account.Owner == (common.PublicKey{}) && account.Lamports == 0 {
+		return common.PublicKey{}, nil, &AccountNotFoundError{Address: address, What: "stake"}
+	}
+	if account.Owner != common.StakeProgramID {
+		return common.PublicKey{}, nil, errorf(ErrorKindInvalidInput, "%s is not a stake account", address)
+	}
+
+	state, err := decodeStakeAccount(account.Data)
+	if err != nil {
+		return common.PublicKey{}, nil, errors.Wrapf(err, "decode stake account %s", address)
+	}
+	state.lamports = account.Lamports
+
+	if state.staker != owner || state.withdrawer != owner {
+		return common.PublicKey{}, nil, errorf(ErrorKindInvalidInput, "stake account %s is not controlled by %s", address, owner.ToBase58())
+	}
+
+	return pubKey, state, nil
+}
+
+const (
+	stakeKindUninitialized uint32 = iota
+	stakeKindInitialized
+	stakeKindDelegated
+	stakeKindRewardsPool
+)
+
+// stakeAccountState is the part of StakeStateV2 the commands use. The meta
+// starts at 4, the delegation at 124.
+type stakeAccountState struct {
+	kind              uint32
+	rentExemptReserve uint64
+	staker            common.PublicKey
+	withdrawer        common.PublicKey
+	voter             common.PublicKey
+	delegatedLamports uint64
+	activationEpoch   uint64
+	deactivationEpoch uint64
+
+	lamports uint64
+}
+
+func decodeStakeAccount(data []byte) (*stakeAccountState, error) {
+	if len(data) < 4 {
+		return nil, errors.New("stake account data is too short")
+	}
+
+	res := &stakeAccountState{kind: binary.LittleEndian.Uint32(data)}
+	switch res.kind {
+	case stakeKindInitialized, stakeKindDelegated:
+	default:
+		return res, nil
+	}
+
+	if len(data) < 124 {
+		return nil, errors.New("stake account data is too short")
+	}
+	res.rentExemptReserve = binary.LittleEndian.Uint64(data[4:12])
+	res.staker = common.PublicKeyFromBytes(data[12:44])
+	res.withdrawer = common.PublicKeyFromBytes(data[44:76])
+
+	if res.kind != stakeKindDelegated {
+		return res, nil
+	}
+
+	if len(data) < 180 {
+		return nil, errors.New("stake account data is too short")
+	}
+	res.voter = common.PublicKeyFromBytes(data[124:156])
+	res.delegatedLamports = binary.LittleEndian.Uint64(data[156:164])
+	res.activationEpoch = binary.LittleEndian.Uint64(data[164:172])
+	res.deactivationEpoch = binary.LittleEndian.Uint64(data[172:180])
+
+	return res, nil
+}
+
+// activation returns the state of the stake in the epoch. Warmup and
+// cooldown are taken as one epoch, which holds unless a large share of the
+// cluster stake moves at once.
+func (s *stakeAccountState) activation(epoch uint64) string {
+	if s.kind != stakeKindDelegated {
+		return StakeStateInitialized
+	}
+
+	if s.deactivationEpoch != math.MaxUint64 {
+		if s.activationEpoch == s.deactivationEpoch || epoch > s.deactivationEpoch {
+			return StakeStateInactive
+		}
+
+		return StakeStateDeactivating
+	}
+
+	// Genesis stakes are activated at epoch MaxUint64.
+	if s.activationEpoch != math.MaxUint64 && epoch <= s.activationEpoch {
+		return StakeStateActivating
+	}
+
+	return StakeStateActive
+}
+
+type StakeReward struct {
+	Epoch       uint64 `json:"epoch"`
+	Lamports    uint64 `json:"lamports"`
+	PostBalance uint64 `json:"post_balance"`
+	Commission  *uint8 `json:"commission,omitempty"`
+}
+
+type StakeAccount struct {
+	PublicKey         string         `json:"public_key"`
+	Balance           uint64         `json:"balance"`
+	State             string         `json:"state"`
+	Voter             string         `json:"voter,omitempty"`
+	VoterLabel        string         `json:"voter_label,omitempty"`
+	DelegatedLamports uint64         `json:"delegated_lamports,omitempty"`
+	ActivationEpoch   *uint64        `json:"activation_epoch,omitempty"`
+	DeactivationEpoch *uint64        `json:"deactivation_epoch,omitempty"`
+	Rewards           []*StakeReward `json:"rewards,omitempty"`
+}
+
+// stakeAccounts lists the stake accounts the owner can withdraw from, with
+// the rewards of the last StakeRewardEpochs epochs.
+func (m *Module) stakeAccounts(ctx context.Context, owner string, labels map[string]string) ([]*StakeAccount, error) {
+	res, err := m.solanaClient.RpcClient.GetProgramAccountsWithConfig(ctx, common.StakeProgramID.ToBase58(), rpc.GetProgramAccountsConfig{
+		Encoding: rpc.AccountEncodingBase64,
+		Filters: []rpc.GetProgramAccountsConfigFilter{
+			{DataSize: stake.AccountSize},
+			{MemCmp: &rpc.GetProgramAccountsConfigFilterMemCmp{Offset: 44, Bytes: owner}},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "get stake accounts")
+	}
+	if err := res.GetError(); err != nil {
+		return nil, errors.Wrap(err, "get stake accounts")
+	}
+	if len(res.Result) == 0 {
+		return nil, nil
+	}
+
+	epochInfo, err := m.solanaClient.GetEpochInfo(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get epoch info")
+	}
+
+	accounts := make([]*StakeAccount, 0, len(res.Result))
+	addresses := make([]string, 0, len(res.Result))
+	for _, account := range res.Result {
+		data, err := decodeRpcAccountData(account.Account.Data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode stake account %s", account.Pubkey)
+		}
+		state, err := decodeStakeAccount(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decode stake account %s", account.Pubkey)
+		}
+
+		stakeAccount := &StakeAccount{
+			PublicKey: account.Pubkey,
+			Balance:   account.Account.Lamports,
+			State:     state.activation(epochInfo.Epoch),
+		}
+		if state.kind == stakeKindDelegated {
+			stakeAccount.Voter = state.voter.ToBase58()
+			stakeAccount.VoterLabel = labels[stakeAccount.Voter]
+			stakeAccount.DelegatedLamports = state.delegatedLamports
+			if state.activationEpoch != math.MaxUint64 {
+				stakeAccount.ActivationEpoch = &state.activationEpoch
+			}
+			if state.deactivationEpoch != math.MaxUint64 {
+				stakeAccount.DeactivationEpoch = &state.deactivationEpoch
+			}
+		}
+		accounts = append(accounts, stakeAccount)
+		addresses = append(addresses, account.Pubkey)
+	}
+
+	for i := 1; i <= m.config.StakeRewardEpochs && uint64(i) < epochInfo.Epoch; i++ {
+		epoch := epochInfo.Epoch - uint64(i)
+		rewards, err := m.solanaClient.RpcClient.GetInflationRewardWithConfig(ctx, addresses, rpc.GetInflationRewardConfig{
+			Commitment: rpc.CommitmentFinalized,
+			Epoch:      epoch,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "get inflation rewards of epoch %d", epoch)
+		}
+		if err := rewards.GetError(); err != nil {
+			return nil, errors.Wrapf(err, "get inflation rewards of epoch %d", epoch)
+		}
+
+		for j, reward := range rewards.Result {
+			if reward == nil || j >= len(accounts) {
+				continue
+			}
+			accounts[j].Rewards = append(accounts[j].Rewards, &StakeReward{
+				Epoch:       reward.Epoch,
+				Lamports:    reward.Amount,
+				PostBalance: reward.PostBalance,
+				Commission:  reward.Commission,
+			})
+		}
+	}
+
+	return accounts, nil
+}
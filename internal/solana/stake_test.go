@@ -0,0 +1,52 @@
+package solana
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/program/stake"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_decodeStakeAccount(t *testing.T) {
+	staker := types.NewAccount().PublicKey
+	withdrawer := types.NewAccount().PublicKey
+	voter := types.NewAccount().PublicKey
+
+	data := make([]byte, stake.AccountSize)
+	binary.LittleEndian.PutUint32(data, stakeKindDelegated)
+	binary.LittleEndian.PutUint64(data[4:], 2282880)
+	copy(data[12:], staker.Bytes())
+	copy(data[44:], withdrawer.Bytes())
+	copy(data[124:], voter.Bytes())
+	binary.LittleEndian.PutUint64(data[156:], 5000000000)
+	binary.LittleEndian.PutUint64(data[164:], 600)
+	binary.LittleEndian.PutUint64(data[172:], math.MaxUint64)
+
+	state, err := decodeStakeAccount(data)
+	require.NoError(t, err)
+	require.Equal(t, stakeKindDelegated, state.kind)
+	require.Equal(t, uint64(2282880), state.rentExemptReserve)
+	require.Equal(t, staker, state.staker)
+	require.Equal(t, withdrawer, state.withdrawer)
+	require.Equal(t, voter, state.voter)
+	require.Equal(t, uint64(5000000000), state.delegatedLamports)
+
+	require.Equal(t, StakeStateActivating, state.activation(600))
+	require.Equal(t, StakeStateActive, state.activation(601))
+
+	state.deactivationEpoch = 610
+	require.Equal(t, StakeStateDeactivating, state.activation(610))
+	require.Equal(t, StakeStateInactive, state.activation(611))
+
+	binary.LittleEndian.PutUint32(data, stakeKindInitialized)
+	state, err = decodeStakeAccount(data)
+	require.NoError(t, err)
+	require.Equal(t, StakeStateInitialized, state.activation(600))
+	require.Equal(t, uint64(0), state.delegatedLamports)
+
+	_, err = decodeStakeAccount(data[:100])
+	require.Error(t, err)
+}
@@ -2,9 +2,9 @@ package solana
 
 import (
 	"context"
-	"fmt"
-	"time"
+	"os"
 
+	"github.com/blocto/solana-go-sdk/client"
 	"github.com/blocto/solana-go-sdk/common"
 	"github.com/blocto/solana-go-sdk/program/associated_token_account"
 	"github.com/blocto/solana-go-sdk/program/metaplex/token_metadata"
@@ -19,151 +19,368 @@ import (
 type CreateTokenRequest struct {
 	OwnerKeyFilename       string
 	OutputTokenKeyFilename string
-	InitialSupply          uint64
+	// CheckpointFilename defaults to the token key file name with a
+	// ".checkpoint.json" suffix.
+	CheckpointFilename string
+	InitialSupply      uint64
 
 	Name   string
 	Symbol string
 	Uri    string
+
+	// Resume finishes the run recorded in the checkpoint file instead of
+	// creating a new mint.
+	Resume bool
+}
+
+// createTokenSteps selects which parts of token creation still have to be
+// sent on chain.
+type createTokenSteps struct {
+	CreateMint     bool
+	CreateATA      bool
+	MintTo         bool
+	CreateMetadata bool
 }
 
-func (m *Module) CreateToken(ctx context.Context, req *CreateTokenRequest) error {
+func (s createTokenSteps) empty() bool {
+	return !s.CreateMint && !s.CreateATA && !s.MintTo && !s.CreateMetadata
+}
+
+func (m *Module) CreateToken(ctx context.Context, req *CreateTokenRequest) (*CreateTokenResult, error) {
 	_, span := tracer.Start(ctx, "pkg.payment.CreateToken")
 	defer span.End()
 
+	if req.Resume {
+		return m.resumeCreateToken(ctx, req)
+	}
+
+	checkpointFilename := checkpointFilename(req)
+	if _, err := os.Stat(checkpointFilename); err == nil {
+		return nil, errorf(ErrorKindInvalidInput, "checkpoint file %s already exists, resume it or remove it first", checkpointFilename)
+	}
+	if _, err := os.Stat(req.OutputTokenKeyFilename); err == nil {
+		return nil, errorf(ErrorKindInvalidInput, "token key file %s already exists", req.OutputTokenKeyFilename)
+	}
+
 	ownerAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
 	if err != nil {
-		return errors.Wrap(err, "failed to load owner account")
+		return nil, errors.Wrap(err, "failed to load owner account")
 	}
 
-	ownerBalance, err := m.solanaClient.GetBalance(ctx, ownerAccount.PublicKey.ToBase58())
+	estimate, err := m.EstimateCreateToken(ctx, req)
 	if err != nil {
-		return errors.Wrap(err, "get owner balance")
+		return nil, errors.Wrap(err, "estimate token creation cost")
+	}
+	if !estimate.Sufficient {
+		return nil, estimate.insufficientBalance(ownerAccount.PublicKey)
 	}
-	m.log.Info(ctx, "owner balance", ownerBalance)
 
-	mintAccount, err := m.CreateAccount(ctx, req.OutputTokenKeyFilename)
-	if err != nil {
-		return errors.Wrap(err, "create mint account")
+	// A dry run leaves no files behind.
+	mintAccount := types.NewAccount()
+	if !m.dryRun {
+		if err := writeKeyFile(req.OutputTokenKeyFilename, &mintAccount); err != nil {
+			return nil, errors.Wrap(err, "write mint key file")
+		}
 	}
 	m.log.Info(ctx, "mint account address", mintAccount.PublicKey.ToBase58())
 
-	exemptionMinBalance, err := m.solanaClient.GetMinimumBalanceForRentExemption(ctx, token.MintAccountSize)
+	ataAddress, _, err := common.FindAssociatedTokenAddress(ownerAccount.PublicKey, mintAccount.PublicKey)
 	if err != nil {
-		return errors.Wrap(err, "get exemption min balance")
+		return nil, errors.Wrap(err, "calculate ATA address")
 	}
-	m.log.Info(ctx, "exemption min balance", exemptionMinBalance)
+	m.log.Info(ctx, "ATA account address", ataAddress.ToBase58())
 
-	createMintAccountInstruction := system.CreateAccount(system.CreateAccountParam{
-		From:     ownerAccount.PublicKey,
-		New:      mintAccount.PublicKey,
-		Lamports: exemptionMinBalance,
-		Space:    token.MintAccountSize,
-		Owner:    common.TokenProgramID,
-	})
+	metadataKey, err := token_metadata.GetTokenMetaPubkey(mintAccount.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "calculate metadata key")
+	}
 
-	initializeMintInstruction := token.InitializeMint(token.InitializeMintParam{
-		Decimals: 0,
-		Mint:     mintAccount.PublicKey,
-		MintAuth: ownerAccount.PublicKey,
-	})
+	checkpoint := &createTokenCheckpoint{
+		OwnerPublicKey:  ownerAccount.PublicKey.ToBase58(),
+		MintPublicKey:   mintAccount.PublicKey.ToBase58(),
+		MintKeyFilename: req.OutputTokenKeyFilename,
+		ATAAddress:      ataAddress.ToBase58(),
+		MetadataAddress: metadataKey.ToBase58(),
+		InitialSupply:   req.InitialSupply,
+		Name:            req.Name,
+		Symbol:          req.Symbol,
+		Uri:             req.Uri,
+	}
+	if !m.dryRun {
+		if err := checkpoint.save(checkpointFilename); err != nil {
+			return nil, errors.Wrap(err, "save checkpoint")
+		}
+		m.log.Info(ctx, "token creation checkpoint", checkpointFilename)
+	}
 
-	ataAddress, _, err := common.FindAssociatedTokenAddress(ownerAccount.PublicKey, mintAccount.PublicKey)
-	if err != nil {
-		return errors.Wrap(err, "calculate ATA address")
+	if err := m.finishCreateToken(ctx, checkpoint, checkpointFilename, ownerAccount, &mintAccount, createTokenSteps{
+		CreateMint:     true,
+		CreateATA:      true,
+		MintTo:         req.InitialSupply > 0,
+		CreateMetadata: true,
+	}); err != nil {
+		return nil, err
 	}
-	m.log.Info(ctx, "ATA account address", ataAddress.ToBase58())
 
-	createATAInstruction := associated_token_account.Create(associated_token_account.CreateParam{
-		Funder:                 ownerAccount.PublicKey,
-		Owner:                  ownerAccount.PublicKey,
-		Mint:                   mintAccount.PublicKey,
-		AssociatedTokenAccount: ataAddress,
-	})
+	return checkpoint.result(), nil
+}
 
-	mintToInstruction := token.MintTo(token.MintToParam{
-		Mint:   mintAccount.PublicKey,
-		Auth:   ownerAccount.PublicKey,
-		To:     ataAddress,
-		Amount: req.InitialSupply,
-	})
+func (m *Module) resumeCreateToken(ctx context.Context, req *CreateTokenRequest) (*CreateTokenResult, error) {
+	checkpointFilename := checkpointFilename(req)
 
-	mintData := token_metadata.DataV2{
-		Name:                 req.Name,
-		Symbol:               req.Symbol,
-		Uri:                  req.Uri,
-		SellerFeeBasisPoints: 0,
-		Creators: &[]token_metadata.Creator{
-			{
-				Address:  ownerAccount.PublicKey,
-				Verified: true,
-				Share:    100,
-			},
-		},
+	checkpoint, err := loadCreateTokenCheckpoint(checkpointFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "load checkpoint")
+	}
+	if checkpoint.Completed {
+		m.log.Info(ctx, "token creation already completed", checkpoint.MintPublicKey)
+		return checkpoint.result(), nil
 	}
 
-	metadataKey, _, err := common.FindProgramAddress([][]byte{[]byte("metadata"), common.MetaplexTokenMetaProgramID.Bytes(), mintAccount.PublicKey.Bytes()}, common.MetaplexTokenMetaProgramID)
+	ownerAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
 	if err != nil {
-		return errors.Wrap(err, "calculate metadata key")
+		return nil, errors.Wrap(err, "failed to load owner account")
+	}
+	if ownerAccount.PublicKey.ToBase58() != checkpoint.OwnerPublicKey {
+		return nil, errorf(ErrorKindInvalidInput, "checkpoint belongs to owner %s, not %s", checkpoint.OwnerPublicKey, ownerAccount.PublicKey.ToBase58())
 	}
 
-	metadataInstruction := token_metadata.CreateMetadataAccountV3(token_metadata.CreateMetadataAccountV3Param{
-		Metadata:                metadataKey,
-		Mint:                    mintAccount.PublicKey,
-		MintAuthority:           ownerAccount.PublicKey,
-		Payer:                   ownerAccount.PublicKey,
-		UpdateAuthority:         ownerAccount.PublicKey,
-		UpdateAuthorityIsSigner: true,
-		IsMutable:               true,
-		Data:                    mintData,
-	})
+	mintAccount, err := loadFromKeyFile(ctx, checkpoint.MintKeyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load mint account")
+	}
+	if mintAccount.PublicKey.ToBase58() != checkpoint.MintPublicKey {
+		return nil, errorf(ErrorKindInvalidInput, "mint key file %s does not match checkpoint mint %s", checkpoint.MintKeyFilename, checkpoint.MintPublicKey)
+	}
 
-	recentBlockhash, err := m.solanaClient.GetLatestBlockhash(ctx)
+	if err := m.checkLastCreateTokenTransaction(ctx, checkpoint); err != nil {
+		return nil, err
+	}
+
+	steps, err := m.missingCreateTokenSteps(ctx, checkpoint)
 	if err != nil {
-		return errors.Wrap(err, "failed to get recent blockhash")
+		return nil, errors.Wrap(err, "inspect on-chain token state")
 	}
-	m.log.Info(ctx, "recent Solana block hash", recentBlockhash)
+	m.log.Info(ctx, "missing token creation steps", steps)
 
-	tx, err := types.NewTransaction(types.NewTransactionParam{
-		Signers: []types.Account{*ownerAccount, *mintAccount},
-		Message: types.NewMessage(types.NewMessageParam{
-			FeePayer:        ownerAccount.PublicKey,
-			RecentBlockhash: recentBlockhash.Blockhash,
-			Instructions: []types.Instruction{
-				createMintAccountInstruction,
-				initializeMintInstruction,
-				createATAInstruction,
-				mintToInstruction,
-				metadataInstruction,
-			},
-		}),
+	if steps.empty() {
+		checkpoint.Completed = true
+		if m.dryRun {
+			return checkpoint.result(), nil
+		}
+		if err := checkpoint.save(checkpointFilename); err != nil {
+			return nil, errors.Wrap(err, "save checkpoint")
+		}
+		m.log.Info(ctx, "token creation already on chain", checkpoint.MintPublicKey)
+
+		return checkpoint.result(), nil
+	}
+
+	if err := m.finishCreateToken(ctx, checkpoint, checkpointFilename, ownerAccount, mintAccount, steps); err != nil {
+		return nil, err
+	}
+
+	return checkpoint.result(), nil
+}
+
+// checkLastCreateTokenTransaction refuses to resume while the last sent
+// transaction may still land: the steps it carries would be sent again and
+// the initial supply minted twice.
+func (m *Module) checkLastCreateTokenTransaction(ctx context.Context, checkpoint *createTokenCheckpoint) error {
+	n := len(checkpoint.Signatures)
+	if n == 0 {
+		return nil
+	}
+	signature := checkpoint.Signatures[n-1]
+
+	status, err := m.solanaClient.GetSignatureStatusWithConfig(ctx, signature, client.GetSignatureStatusesConfig{
+		SearchTransactionHistory: true,
 	})
 	if err != nil {
-		return errors.Wrap(err, "failed to create transaction")
+		return errors.Wrap(err, "get last transaction status")
+	}
+	if status != nil {
+		m.log.Info(ctx, "last transaction status", signature, status)
+
+		return nil
 	}
 
-	txSignature, err := m.solanaClient.SendTransaction(ctx, tx)
+	// Checkpoints written before the blockhash was recorded cannot tell.
+	if checkpoint.LastBlockhash == "" {
+		return nil
+	}
+
+	valid, err := m.solanaClient.IsBlockhashValid(ctx, checkpoint.LastBlockhash)
 	if err != nil {
-		return errors.Wrap(err, "failed to send transaction")
+		return errors.Wrap(err, "check blockhash validity")
 	}
-	m.log.Info(ctx, "transaction signature", txSignature)
+	if valid {
+		return errorf(ErrorKindTimeout, "transaction %s may still land, rerun once its blockhash expires", signature)
+	}
+
+	return nil
+}
+
+// missingCreateTokenSteps compares the checkpoint with on-chain state. The
+// initial supply is only minted while the supply is still zero, so a resume
+// never mints twice.
+func (m *Module) missingCreateTokenSteps(ctx context.Context, checkpoint *createTokenCheckpoint) (createTokenSteps, error) {
+	var steps createTokenSteps
 
-	txNotConfirmed := true
-	fmt.Print("waiting for confirmation")
-	for txNotConfirmed {
-		tx, err := m.solanaClient.GetTransaction(ctx, txSignature)
+	mintInfo, err := m.solanaClient.GetAccountInfo(ctx, checkpoint.MintPublicKey)
+	if err != nil {
+		return steps, errors.Wrap(err, "get mint account")
+	}
+
+	switch mintInfo.Owner {
+	case common.PublicKey{}:
+		steps.CreateMint = true
+		steps.MintTo = checkpoint.InitialSupply > 0
+	case common.TokenProgramID:
+		mint, err := token.MintAccountFromData(mintInfo.Data)
 		if err != nil {
-			m.log.Error(ctx, "failed to get transaction", err, txSignature)
+			return steps, errors.Wrap(err, "decode mint account")
 		}
-		if tx != nil && tx.Meta != nil && tx.Meta.Err == nil {
-			txNotConfirmed = false
-		} else {
-			fmt.Print(".")
-			time.Sleep(1 * time.Second)
+		steps.MintTo = mint.Supply == 0 && checkpoint.InitialSupply > 0
+	default:
+		return steps, errorf(ErrorKindInvalidInput, "mint address %s is owned by %s", checkpoint.MintPublicKey, mintInfo.Owner.ToBase58())
+	}
+
+	ataInfo, err := m.solanaClient.GetAccountInfo(ctx, checkpoint.ATAAddress)
+	if err != nil {
+		return steps, errors.Wrap(err, "get ATA account")
+	}
+	steps.CreateATA = ataInfo.Owner == common.PublicKey{}
+
+	metadataInfo, err := m.solanaClient.GetAccountInfo(ctx, checkpoint.MetadataAddress)
+	if err != nil {
+		return steps, errors.Wrap(err, "get metadata account")
+	}
+	steps.CreateMetadata = metadataInfo.Owner == common.PublicKey{}
+
+	return steps, nil
+}
+
+func (m *Module) finishCreateToken(ctx context.Context, checkpoint *createTokenCheckpoint, checkpointFilename string, ownerAccount, mintAccount *types.Account, steps createTokenSteps) error {
+	estimate, err := m.estimateCreateTokenRent(ctx, steps)
+	if err != nil {
+		return err
+	}
+
+	instructions, err := m.estimateCreateTokenFee(ctx, estimate, ownerAccount,
+		createTokenInstructions(checkpoint, ownerAccount, mintAccount, steps, estimate.MintRent))
+	if err != nil {
+		return err
+	}
+	if !estimate.Sufficient {
+		return estimate.insufficientBalance(ownerAccount.PublicKey)
+	}
+
+	signers := []types.Account{*ownerAccount}
+	if steps.CreateMint {
+		signers = append(signers, *mintAccount)
+	}
+
+	tx, err := m.signTransaction(ctx, signers, ownerAccount.PublicKey, instructions)
+	if err != nil {
+		return err
+	}
+
+	// The signature is checkpointed before sending, so a resume after a
+	// crash mid-send finds it.
+	if !m.dryRun {
+		checkpoint.Signatures = append(checkpoint.Signatures, tx.Signature)
+		checkpoint.LastBlockhash = tx.Blockhash
+		if err := checkpoint.save(checkpointFilename); err != nil {
+			return errors.Wrap(err, "save checkpoint")
 		}
 	}
-	fmt.Println("")
 
-	m.log.Info(ctx, "transaction has been confirmed", txSignature)
+	if err := m.broadcastTransaction(ctx, tx); err != nil {
+		return err
+	}
+
+	res, err := m.confirmTransaction(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if err := res.Err(); err != nil {
+		return errors.Wrap(err, "token creation is incomplete, rerun with --resume")
+	}
+
+	checkpoint.Completed = true
+	if err := checkpoint.save(checkpointFilename); err != nil {
+		return errors.Wrap(err, "save checkpoint")
+	}
 
 	return nil
 }
+
+func createTokenInstructions(checkpoint *createTokenCheckpoint, ownerAccount, mintAccount *types.Account, steps createTokenSteps, mintRent uint64) []types.Instruction {
+	ataAddress := common.PublicKeyFromString(checkpoint.ATAAddress)
+	metadataKey := common.PublicKeyFromString(checkpoint.MetadataAddress)
+
+	var instructions []types.Instruction
+
+	if steps.CreateMint {
+		instructions = append(instructions,
+			system.CreateAccount(system.CreateAccountParam{
+				From:     ownerAccount.PublicKey,
+				New:      mintAccount.PublicKey,
+				Lamports: mintRent,
+				Space:    token.MintAccountSize,
+				Owner:    common.TokenProgramID,
+			}),
+			token.InitializeMint(token.InitializeMintParam{
+				Decimals: 0,
+				Mint:     mintAccount.PublicKey,
+				MintAuth: ownerAccount.PublicKey,
+			}),
+		)
+	}
+
+	if steps.CreateATA {
+		instructions = append(instructions, associated_token_account.Create(associated_token_account.CreateParam{
+			Funder:                 ownerAccount.PublicKey,
+			Owner:                  ownerAccount.PublicKey,
+			Mint:                   mintAccount.PublicKey,
+			AssociatedTokenAccount: ataAddress,
+		}))
+	}
+
+	if steps.MintTo {
+		instructions = append(instructions, token.MintTo(token.MintToParam{
+			Mint:   mintAccount.PublicKey,
+			Auth:   ownerAccount.PublicKey,
+			To:     ataAddress,
+			Amount: checkpoint.InitialSupply,
+		}))
+	}
+
+	if steps.CreateMetadata {
+		instructions = append(instructions, token_metadata.CreateMetadataAccountV3(token_metadata.CreateMetadataAccountV3Param{
+			Metadata:                metadataKey,
+			Mint:                    mintAccount.PublicKey,
+			MintAuthority:           ownerAccount.PublicKey,
+			Payer:                   ownerAccount.PublicKey,
+			UpdateAuthority:         ownerAccount.PublicKey,
+			UpdateAuthorityIsSigner: true,
+			IsMutable:               true,
+			Data: token_metadata.DataV2{
+				Name:                 checkpoint.Name,
+				Symbol:               checkpoint.Symbol,
+				Uri:                  checkpoint.Uri,
+				SellerFeeBasisPoints: 0,
+				Creators: &[]token_metadata.Creator{
+					{
+						Address:  ownerAccount.PublicKey,
+						Verified: true,
+						Share:    100,
+					},
+				},
+			},
+		}))
+	}
+
+	return instructions
+}
@@ -0,0 +1,206 @@
+package solana
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Token-2022 pads mints to the token account size so the account type
+	// byte sits at the same offset for mints and token accounts.
+	token2022AccountTypeOffset = token.TokenAccountSize
+
+	token2022AccountTypeMint    = 1
+	token2022AccountTypeAccount = 2
+)
+
+const (
+	token2022ExtensionTransferFeeConfig   = 1
+	token2022ExtensionMintCloseAuthority  = 3
+	token2022ExtensionDefaultAccountState = 6
+	token2022ExtensionPermanentDelegate   = 12
+	token2022ExtensionTransferHook        = 14
+	token2022ExtensionMetadataPointer     = 18
+	token2022ExtensionTokenMetadata       = 19
+	token2022ExtensionGroupPointer        = 20
+	token2022ExtensionGroupMemberPointer  = 22
+)
+
+var token2022ExtensionNames = map[uint16]string{
+	1:  "transfer_fee_config",
+	2:  "transfer_fee_amount",
+	3:  "mint_close_authority",
+	4:  "confidential_transfer_mint",
+	5:  "confidential_transfer_account",
+	6:  "default_account_state",
+	7:  "immutable_owner",
+	8:  "memo_transfer",
+	9:  "non_transferable",
+	10: "interest_bearing_config",
+	11: "cpi_guard",
+	12: "permanent_delegate",
+	13: "non_transferable_account",
+	14: "transfer_hook",
+	15: "transfer_hook_account",
+	16: "confidential_transfer_fee_config",
+	17: "confidential_transfer_fee_amount",
+	18: "metadata_pointer",
+	19: "token_metadata",
+	20: "group_pointer",
+	21: "token_group",
+	22: "group_member_pointer",
+	23: "token_group_member",
+	24: "confidential_mint_burn",
+	25: "scaled_ui_amount",
+	26: "pausable",
+	27: "pausable_account",
+}
+
+type TokenExtension struct {
+	Type   string            `json:"type"`
+	Fields map[string]string `json:"fields,omitempty"`
+	// Data is the base64 extension value, set when it is not decoded.
+	Data string `json:"data,omitempty"`
+}
+
+// decodeToken2022Extensions parses the TLV area that follows a Token-2022
+// mint or token account.
+func decodeToken2022Extensions(data []byte, accountType byte) ([]*TokenExtension, error) {
+	if len(data) <= token2022AccountTypeOffset {
+		return nil, nil
+	}
+	if data[token2022AccountTypeOffset] != accountType {
+		return nil, errors.Errorf("unexpected account type %d", data[token2022AccountTypeOffset])
+	}
+
+	var res []*TokenExtension
+	for tlv := data[token2022AccountTypeOffset+1:]; len(tlv) >= 4; {
+		extensionType := binary.LittleEndian.Uint16(tlv[0:2])
+		length := int(binary.LittleEndian.Uint16(tlv[2:4]))
+		if extensionType == 0 {
+			break
+		}
+		if len(tlv) < 4+length {
+			return nil, errors.Errorf("extension %d overflows account data", extensionType)
+		}
+
+		res = append(res, decodeToken2022Extension(extensionType, tlv[4:4+length]))
+		tlv = tlv[4+length:]
+	}
+
+	return res, nil
+}
+
+func decodeToken2022Extension(extensionType uint16, value []byte) *TokenExtension {
+	name, ok := token2022ExtensionNames[extensionType]
+	if !ok {
+		name = fmt.Sprintf("unknown_%d", extensionType)
+	}
+	ext := &TokenExtension{Type: name}
+
+	switch extensionType {
+	case token2022ExtensionMintCloseAuthority, token2022ExtensionPermanentDelegate:
+		if len(value) == 32 {
+			ext.Fields = map[string]string{"authority": optionalPublicKey(value)}
+		}
+	case token2022ExtensionMetadataPointer, token2022ExtensionGroupPointer, token2022ExtensionGroupMemberPointer:
+		if len(value) == 64 {
+			ext.Fields = map[string]string{
+				"authority": optionalPublicKey(value[0:32]),
+				"address":   optionalPublicKey(value[32:64]),
+			}
+		}
+	case token2022ExtensionTransferHook:
+		if len(value) == 64 {
+			ext.Fields = map[string]string{
+				"authority":  optionalPublicKey(value[0:32]),
+				"program_id": optionalPublicKey(value[32:64]),
+			}
+		}
+	case token2022ExtensionDefaultAccountState:
+		if len(value) == 1 {
+			ext.Fields = map[string]string{"state": tokenAccountStateName(value[0])}
+		}
+	case token2022ExtensionTransferFeeConfig:
+		if len(value) == 108 {
+			ext.Fields = map[string]string{
+				"transfer_fee_config_authority":   optionalPublicKey(value[0:32]),
+				"withdraw_withheld_authority":     optionalPublicKey(value[32:64]),
+				"withheld_amount":                 fmt.Sprint(binary.LittleEndian.Uint64(value[64:72])),
+				"older_epoch":                     fmt.Sprint(binary.LittleEndian.Uint64(value[72:80])),
+				"older_maximum_fee":               fmt.Sprint(binary.LittleEndian.Uint64(value[80:88])),
+				"older_transfer_fee_basis_points": fmt.Sprint(binary.LittleEndian.Uint16(value[88:90])),
+				"newer_epoch":                     fmt.Sprint(binary.LittleEndian.Uint64(value[90:98])),
+				"newer_maximum_fee":               fmt.Sprint(binary.LittleEndian.Uint64(value[98:106])),
+				"newer_transfer_fee_basis_points": fmt.Sprint(binary.LittleEndian.Uint16(value[106:108])),
+			}
+		}
+	case token2022ExtensionTokenMetadata:
+		if fields, err := decodeToken2022Metadata(value); err == nil {
+			ext.Fields = fields
+		}
+	}
+
+	if ext.Fields == nil && len(value) > 0 {
+		ext.Data = base64.StdEncoding.EncodeToString(value)
+	}
+
+	return ext
+}
+
+// decodeToken2022Metadata reads the fixed part of the SPL token metadata
+// interface: update authority, mint, name, symbol and uri.
+func decodeToken2022Metadata(value []byte) (map[string]string, error) {
+	if len(value) < 64 {
+		return nil, errors.New("token metadata is too short")
+	}
+
+	fields := map[string]string{
+		"update_authority": optionalPublicKey(value[0:32]),
+		"mint":             common.PublicKeyFromBytes(value[32:64]).ToBase58(),
+	}
+
+	rest := value[64:]
+	for _, key := range []string{"name", "symbol", "uri"} {
+		if len(rest) < 4 {
+			return nil, errors.New("token metadata string is truncated")
+		}
+		length := int(binary.LittleEndian.Uint32(rest[0:4]))
+		if len(rest) < 4+length {
+			return nil, errors.New("token metadata string is truncated")
+		}
+		fields[key] = string(rest[4 : 4+length])
+		rest = rest[4+length:]
+	}
+
+	return fields, nil
+}
+
+// optionalPublicKey renders an OptionalNonZeroPubkey, where all zero bytes
+// mean "none".
+func optionalPublicKey(data []byte) string {
+	key := common.PublicKeyFromBytes(data)
+	if key == (common.PublicKey{}) {
+		return ""
+	}
+
+	return key.ToBase58()
+}
+
+func tokenAccountStateName(state byte) string {
+	switch token.TokenAccountState(state) {
+	case token.TokenAccountStateUninitialized:
+		return "uninitialized"
+	case token.TokenAccountStateInitialized:
+		return "initialized"
+	case token.TokenAccountFrozen:
+		return "frozen"
+	default:
+		return fmt.Sprintf("unknown_%d", state)
+	}
+}
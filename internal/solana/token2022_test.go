@@ -0,0 +1,41 @@
+package solana
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_decodeToken2022Extensions(t *testing.T) {
+	authority := common.PublicKeyFromString("TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA")
+
+	data := make([]byte, token2022AccountTypeOffset+1)
+	data[token2022AccountTypeOffset] = token2022AccountTypeMint
+
+	tlv := make([]byte, 4)
+	binary.LittleEndian.PutUint16(tlv[0:2], token2022ExtensionMintCloseAuthority)
+	binary.LittleEndian.PutUint16(tlv[2:4], 32)
+	data = append(append(data, tlv...), authority.Bytes()...)
+
+	binary.LittleEndian.PutUint16(tlv[0:2], 7)
+	binary.LittleEndian.PutUint16(tlv[2:4], 0)
+	data = append(data, tlv...)
+
+	res, err := decodeToken2022Extensions(data, token2022AccountTypeMint)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	require.Equal(t, "mint_close_authority", res[0].Type)
+	require.Equal(t, authority.ToBase58(), res[0].Fields["authority"])
+	require.Equal(t, "immutable_owner", res[1].Type)
+
+	_, err = decodeToken2022Extensions(data, token2022AccountTypeAccount)
+	require.Error(t, err)
+}
+
+func Test_MintInfoInvalidAddress(t *testing.T) {
+	_, err := (&Module{}).MintInfo(context.Background(), "not-a-mint")
+	require.Equal(t, ErrorKindInvalidInput, KindOf(err))
+}
@@ -0,0 +1,95 @@
+package solana
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// createTokenCheckpoint records everything needed to finish an interrupted
+// CreateToken run without creating another mint.
+type createTokenCheckpoint struct {
+	OwnerPublicKey  string `json:"owner_public_key"`
+	MintPublicKey   string `json:"mint_public_key"`
+	MintKeyFilename string `json:"mint_key_filename"`
+	ATAAddress      string `json:"ata_address"`
+	MetadataAddress string `json:"metadata_address"`
+
+	InitialSupply uint64 `json:"initial_supply"`
+	Name          string `json:"name"`
+	Symbol        string `json:"symbol"`
+	Uri           string `json:"uri"`
+
+	Signatures []string `json:"signatures"`
+	// LastBlockhash is the blockhash of the last signature, which tells
+	// whether that transaction can still land.
+	LastBlockhash string `json:"last_blockhash,omitempty"`
+	Completed     bool   `json:"completed"`
+}
+
+// CreateTokenResult reports the accounts of a token creation and the
+// transactions sent for it, across resumes.
+type CreateTokenResult struct {
+	Mint            string   `json:"mint"`
+	MintKeyFilename string   `json:"mint_key_filename"`
+	ATA             string   `json:"ata"`
+	Metadata        string   `json:"metadata"`
+	InitialSupply   uint64   `json:"initial_supply"`
+	Signatures      []string `json:"signatures"`
+	Completed       bool     `json:"completed"`
+}
+
+func (c *createTokenCheckpoint) result() *CreateTokenResult {
+	return &CreateTokenResult{
+		Mint:            c.MintPublicKey,
+		MintKeyFilename: c.MintKeyFilename,
+		ATA:             c.ATAAddress,
+		Metadata:        c.MetadataAddress,
+		InitialSupply:   c.InitialSupply,
+		Signatures:      c.Signatures,
+		Completed:       c.Completed,
+	}
+}
+
+func checkpointFilename(req *CreateTokenRequest) string {
+	if req.CheckpointFilename != "" {
+		return req.CheckpointFilename
+	}
+
+	return req.OutputTokenKeyFilename + ".checkpoint.json"
+}
+
+func loadCreateTokenCheckpoint(filename string) (*createTokenCheckpoint, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "read checkpoint file")
+	}
+
+	var c createTokenCheckpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, errors.Wrap(err, "unmarshal checkpoint file")
+	}
+
+	return &c, nil
+}
+
+// save writes the checkpoint through a temporary file so an interrupted
+// write never leaves a truncated checkpoint behind.
+func (c *createTokenCheckpoint) save(filename string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshal checkpoint")
+	}
+
+	tmpFilename := filename + ".tmp"
+	if err := os.WriteFile(tmpFilename, data, 0600); err != nil {
+		return errors.Wrap(err, "write checkpoint file")
+	}
+
+	if err := os.Rename(tmpFilename, filename); err != nil {
+		return errors.Wrap(err, "replace checkpoint file")
+	}
+
+	return nil
+}
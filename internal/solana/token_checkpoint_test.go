@@ -0,0 +1,25 @@
+package solana
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_createTokenCheckpoint_save(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "token.checkpoint.json")
+
+	checkpoint := &createTokenCheckpoint{
+		OwnerPublicKey: "owner",
+		MintPublicKey:  "mint",
+		InitialSupply:  3000000,
+		Signatures:     []string{"sig"},
+		LastBlockhash:  "blockhash",
+	}
+	require.NoError(t, checkpoint.save(filename))
+
+	res, err := loadCreateTokenCheckpoint(filename)
+	require.NoError(t, err)
+	require.Equal(t, checkpoint, res)
+}
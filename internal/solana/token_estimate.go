@@ -0,0 +1,179 @@
+package solana
+
+import (
+	"context"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/program/metaplex/token_metadata"
+	"github.com/blocto/solana-go-sdk/program/token"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+// metadataAccountMaxSize is the size Token Metadata allocates for a metadata
+// account, independent of the actual name, symbol and uri lengths.
+const metadataAccountMaxSize = 679
+
+// metadataCreateProtocolFee is the fee Token Metadata charges the payer on
+// top of the rent when it creates a metadata account.
+const metadataCreateProtocolFee = 10_000_000
+
+type CreateTokenEstimate struct {
+	MintRent     uint64 `json:"mint_rent_lamports"`
+	ATARent      uint64 `json:"ata_rent_lamports"`
+	MetadataRent uint64 `json:"metadata_rent_lamports"`
+	// MetadataProtocolFee is charged by Token Metadata for the metadata account.
+	MetadataProtocolFee uint64 `json:"metadata_protocol_fee_lamports"`
+	TransactionFee      uint64 `json:"transaction_fee_lamports"`
+	// PriorityFee is the part of TransactionFee paid for the compute budget.
+	PriorityFee   uint64 `json:"priority_fee_lamports"`
+	TotalLamports uint64 `json:"total_lamports"`
+
+	OwnerBalance uint64 `json:"owner_balance_lamports"`
+	Sufficient   bool   `json:"sufficient"`
+}
+
+func (e *CreateTokenEstimate) insufficientBalance(owner common.PublicKey) error {
+	return &InsufficientBalanceError{Address: owner.ToBase58(), Asset: AssetSOL, Purpose: "token creation", Have: e.OwnerBalance, Need: e.TotalLamports}
+}
+
+// EstimateCreateToken prices the CreateToken transaction without sending it.
+// With Resume set only the steps missing on chain are priced.
+func (m *Module) EstimateCreateToken(ctx context.Context, req *CreateTokenRequest) (*CreateTokenEstimate, error) {
+	_, span := tracer.Start(ctx, "internal.solana.EstimateCreateToken")
+	defer span.End()
+
+	ownerAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load owner account")
+	}
+
+	var (
+		checkpoint  *createTokenCheckpoint
+		mintAccount *types.Account
+		steps       createTokenSteps
+	)
+	if req.Resume {
+		checkpoint, err = loadCreateTokenCheckpoint(checkpointFilename(req))
+		if err != nil {
+			return nil, errors.Wrap(err, "load checkpoint")
+		}
+
+		mintAccount, err = loadFromKeyFile(ctx, checkpoint.MintKeyFilename)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load mint account")
+		}
+
+		steps, err = m.missingCreateTokenSteps(ctx, checkpoint)
+		if err != nil {
+			return nil, errors.Wrap(err, "inspect on-chain token state")
+		}
+	} else {
+		// A throwaway mint key: addresses only affect the fee through the
+		// number of signatures, which does not depend on the key itself.
+		account := types.NewAccount()
+		mintAccount = &account
+
+		ataAddress, _, err := common.FindAssociatedTokenAddress(ownerAccount.PublicKey, mintAccount.PublicKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "calculate ATA address")
+		}
+
+		metadataKey, err := token_metadata.GetTokenMetaPubkey(mintAccount.PublicKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "calculate metadata key")
+		}
+
+		checkpoint = &createTokenCheckpoint{
+			ATAAddress:      ataAddress.ToBase58(),
+			MetadataAddress: metadataKey.ToBase58(),
+			InitialSupply:   req.InitialSupply,
+			Name:            req.Name,
+			Symbol:          req.Symbol,
+			Uri:             req.Uri,
+		}
+		steps = createTokenSteps{
+			CreateMint:     true,
+			CreateATA:      true,
+			MintTo:         req.InitialSupply > 0,
+			CreateMetadata: true,
+		}
+	}
+
+	estimate, err := m.estimateCreateTokenRent(ctx, steps)
+	if err != nil {
+		return nil, err
+	}
+
+	instructions := createTokenInstructions(checkpoint, ownerAccount, mintAccount, steps, estimate.MintRent)
+	if _, err := m.estimateCreateTokenFee(ctx, estimate, ownerAccount, instructions); err != nil {
+		return nil, err
+	}
+
+	return estimate, nil
+}
+
+func (m *Module) estimateCreateTokenRent(ctx context.Context, steps createTokenSteps) (*CreateTokenEstimate, error) {
+	var (
+		estimate CreateTokenEstimate
+		err      error
+	)
+
+	if steps.CreateMint {
+		estimate.MintRent, err = m.solanaClient.GetMinimumBalanceForRentExemption(ctx, token.MintAccountSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "get mint rent exemption")
+		}
+	}
+
+	if steps.CreateATA {
+		estimate.ATARent, err = m.solanaClient.GetMinimumBalanceForRentExemption(ctx, token.TokenAccountSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "get ATA rent exemption")
+		}
+	}
+
+	if steps.CreateMetadata {
+		estimate.MetadataRent, err = m.solanaClient.GetMinimumBalanceForRentExemption(ctx, metadataAccountMaxSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "get metadata rent exemption")
+		}
+		estimate.MetadataProtocolFee = metadataCreateProtocolFee
+	}
+
+	return &estimate, nil
+}
+
+// estimateCreateTokenFee adds the compute budget, prices the real message
+// and compares the total with the owner balance. The returned instructions
+// are the ones to send.
+func (m *Module) estimateCreateTokenFee(ctx context.Context, estimate *CreateTokenEstimate, ownerAccount *types.Account, instructions []types.Instruction) ([]types.Instruction, error) {
+	instructions, err := m.withComputeBudget(ctx, ownerAccount.PublicKey, instructions)
+	if err != nil {
+		return nil, errors.Wrap(err, "apply compute budget")
+	}
+
+	fee, err := m.estimateFee(ctx, ownerAccount.PublicKey, instructions)
+	if err != nil {
+		return nil, err
+	}
+	estimate.TransactionFee = fee.TotalLamports
+	estimate.PriorityFee = fee.PriorityFee
+
+	estimate.OwnerBalance, err = m.solanaClient.GetBalance(ctx, ownerAccount.PublicKey.ToBase58())
+	if err != nil {
+		return nil, errors.Wrap(err, "get owner balance")
+	}
+
+	estimate.TotalLamports = estimate.MintRent + estimate.ATARent + estimate.MetadataRent + estimate.MetadataProtocolFee + estimate.TransactionFee
+	estimate.Sufficient = estimate.OwnerBalance >= estimate.TotalLamports
+
+	m.log.Info(ctx, "token creation cost",
+		"total_lamports", estimate.TotalLamports,
+		"owner_balance", estimate.OwnerBalance,
+	)
+
+	return instructions, nil
+}
@@ -0,0 +1,174 @@
+package solana
+
+import (
+	"context"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/pkg/bincode"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/mr-tron/base58"
+	"github.com/pkg/errors"
+)
+
+// TransactionResult identifies the transaction a command sent.
+type TransactionResult struct {
+	Signature string `json:"signature"`
+	// FeeLamports is the fee estimated before sending, priority fee included.
+	FeeLamports uint64 `json:"fee_lamports,omitempty"`
+}
+
+// signedTransaction keeps what is needed to rebroadcast a transaction and to
+// tell when it can no longer land.
+type signedTransaction struct {
+	Transaction          types.Transaction
+	Signature            string
+	Blockhash            string
+	LastValidBlockHeight uint64
+}
+
+func (m *Module) signTransaction(ctx context.Context, signers []types.Account, feePayer common.PublicKey, instructions []types.Instruction) (*signedTransaction, error) {
+	instructions, err := m.withComputeBudget(ctx, feePayer, instructions)
+	if err != nil {
+		return nil, errors.Wrap(err, "apply compute budget")
+	}
+
+	recentBlockhash, err := m.solanaClient.GetLatestBlockhashWithConfig(ctx, client.GetLatestBlockhashConfig{
+		Commitment: m.config.commitment(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get recent blockhash")
+	}
+	m.log.Info(ctx, "recent Solana block hash", recentBlockhash.Blockhash)
+
+	tx, err := types.NewTransaction(types.NewTransactionParam{
+		Signers: signers,
+		Message: types.NewMessage(types.NewMessageParam{
+			FeePayer:        feePayer,
+			RecentBlockhash: recentBlockhash.Blockhash,
+			Instructions:    instructions,
+		}),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create transaction")
+	}
+
+	return &signedTransaction{
+		Transaction:          tx,
+		Signature:            base58.Encode(tx.Signatures[0]),
+		Blockhash:            recentBlockhash.Blockhash,
+		LastValidBlockHeight: recentBlockhash.LatestValidBlockHeight,
+	}, nil
+}
+
+// broadcastTransaction sends the transaction, or simulates it in dry-run
+// mode and returns a *DryRunError with the report.
+func (m *Module) broadcastTransaction(ctx context.Context, tx *signedTransaction) error {
+	if m.dryRun {
+		report, err := m.simulateTransaction(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		return &DryRunError{Report: report}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return &InterruptedError{err: err}
+	}
+
+	if _, err := m.solanaClient.SendTransactionWithConfig(ctx, tx.Transaction, client.SendTransactionConfig{
+		PreflightCommitment: m.config.commitment(),
+	}); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// The request may have reached the node before it was cancelled.
+			return &InterruptedError{Signature: tx.Signature, Sent: true, err: ctxErr}
+		}
+		if programErr := preflightProgramError(err, messageProgramIDs(tx.Transaction.Message)); programErr != nil {
+			return programErr
+		}
+
+		return errors.Wrap(err, "failed to send transaction")
+	}
+	m.log.Info(ctx, "transaction signature", tx.Signature)
+
+	return nil
+}
+
+func (m *Module) sendTransaction(ctx context.Context, signers []types.Account, feePayer common.PublicKey, instructions []types.Instruction) (*signedTransaction, error) {
+	tx, err := m.signTransaction(ctx, signers, feePayer, instructions)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.broadcastTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// sendAndConfirm sends the transaction and waits for it, turning a failed or
+// expired outcome into an error.
+func (m *Module) sendAndConfirm(ctx context.Context, signers []types.Account, feePayer common.PublicKey, instructions []types.Instruction) (*ConfirmationResult, error) {
+	tx, err := m.sendTransaction(ctx, signers, feePayer, instructions)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := m.confirmTransaction(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, res.Err()
+}
+
+// sendAndConfirmPriced is sendAndConfirm that also prices the signed message,
+// so the fee reported is the one the transaction pays.
+func (m *Module) sendAndConfirmPriced(ctx context.Context, signers []types.Account, feePayer common.PublicKey, instructions []types.Instruction) (*ConfirmationResult, *TransactionFee, error) {
+	tx, err := m.signTransaction(ctx, signers, feePayer, instructions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fee, err := m.messageFee(ctx, tx.Transaction.Message)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := m.broadcastTransaction(ctx, tx); err != nil {
+		return nil, nil, err
+	}
+
+	res, err := m.confirmTransaction(ctx, tx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return res, fee, res.Err()
+}
+
+// maxTransactionSize is the largest serialized transaction the network
+// accepts (IPv6 MTU minus headers).
+const maxTransactionSize = 1232
+
+// transactionSize returns the serialized size of a legacy transaction built
+// from the instructions, signatures included.
+func transactionSize(feePayer common.PublicKey, instructions []types.Instruction) (int, error) {
+	message := types.NewMessage(types.NewMessageParam{
+		FeePayer: feePayer,
+		// Any valid hash works, the blockhash has a fixed size.
+		RecentBlockhash: common.PublicKey{}.ToBase58(),
+		Instructions:    instructions,
+	})
+
+	data, err := message.Serialize()
+	if err != nil {
+		return 0, errors.Wrap(err, "serialize message")
+	}
+
+	signatures := int(message.Header.NumRequireSignatures)
+
+	return len(bincode.UintToVarLenBytes(uint64(signatures))) + signatures*64 + len(data), nil
+}
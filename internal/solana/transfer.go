@@ -2,10 +2,10 @@ package solana
 
 import (
 	"context"
-	"fmt"
+	"encoding/binary"
+
 	"github.com/blocto/solana-go-sdk/program/associated_token_account"
 	"github.com/blocto/solana-go-sdk/program/token"
-	"time"
 
 	"github.com/blocto/solana-go-sdk/common"
 	"github.com/blocto/solana-go-sdk/program/system"
@@ -19,187 +19,329 @@ type TransferSOLRequest struct {
 	OwnerKeyFilename string
 	TargetAddress    string
 	AmountLamports   uint64
+	// Max sends the whole balance minus fees instead of AmountLamports.
+	Max bool
+	// KeepRentReserve leaves the sender rent-exempt in Max mode.
+	KeepRentReserve bool
+	// Memo is sent through the SPL Memo program when set.
+	Memo string
+	// References are read-only keys attached to the transfer to find it later.
+	References []string
+	// Estimate from EstimateTransferSOL is sent as confirmed, with its amount
+	// and fee, instead of planning the transfer again.
+	Estimate *TransferSOLEstimate
+}
+
+// TransferResult reports a transfer of lamports, token base units or a
+// compressed NFT.
+type TransferResult struct {
+	TransactionResult
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Asset is "SOL", the token mint or the compressed NFT asset ID.
+	Asset  string `json:"asset"`
+	Amount uint64 `json:"amount"`
+}
+
+type TransferSOLEstimate struct {
+	*TransactionFee
+	AmountLamports uint64 `json:"amount_lamports"`
+
+	instructions []types.Instruction
+}
+
+// EstimateTransferSOL prices the transfer, priority fee included, and
+// resolves the amount sent in Max mode.
+func (m *Module) EstimateTransferSOL(ctx context.Context, req *TransferSOLRequest) (*TransferSOLEstimate, error) {
+	_, span := tracer.Start(ctx, "internal.solana.EstimateTransferSOL")
+	defer span.End()
+
+	fromAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load sender account")
+	}
+
+	return m.planTransferSOL(ctx, req, fromAccount)
 }
 
-func (m *Module) TransferSOL(ctx context.Context, req *TransferSOLRequest) error {
+func (m *Module) TransferSOL(ctx context.Context, req *TransferSOLRequest) (*TransferResult, error) {
 	_, span := tracer.Start(ctx, "pkg.payment.TransferSOL")
 	defer span.End()
 
 	fromAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
 	if err != nil {
-		return errors.Wrap(err, "failed to load sender account")
+		return nil, errors.Wrap(err, "failed to load sender account")
 	}
 
-	recipientPubKey := common.PublicKeyFromString(req.TargetAddress)
+	estimate := req.Estimate
+	if estimate == nil {
+		if estimate, err = m.planTransferSOL(ctx, req, fromAccount); err != nil {
+			return nil, err
+		}
+	} else if err := m.checkTransferSOLEstimate(ctx, fromAccount, estimate); err != nil {
+		return nil, err
+	}
+	m.log.Info(ctx, "estimated transaction fee", estimate.TotalLamports, "priority_fee", estimate.PriorityFee)
 
-	ownerBalance, err := m.solanaClient.GetBalance(ctx, fromAccount.PublicKey.ToBase58())
+	confirmation, err := m.sendAndConfirm(ctx, []types.Account{*fromAccount}, fromAccount.PublicKey, estimate.instructions)
 	if err != nil {
-		return errors.Wrap(err, "failed to get sender balance")
+		return nil, err
 	}
-	m.log.Info(ctx, "sender balance", ownerBalance)
 
-	recentBlockhash, err := m.solanaClient.GetLatestBlockhash(ctx)
+	return &TransferResult{
+		TransactionResult: TransactionResult{Signature: confirmation.Signature, FeeLamports: estimate.TotalLamports},
+		From:              fromAccount.PublicKey.ToBase58(),
+		To:                req.TargetAddress,
+		Asset:             AssetSOL,
+		Amount:            estimate.AmountLamports,
+	}, nil
+}
+
+// planTransferSOL builds the transfer and checks it against the balances:
+// the sender has to cover the amount and the fee from the real message, and
+// neither side may end up with a balance below the rent-exempt minimum.
+func (m *Module) planTransferSOL(ctx context.Context, req *TransferSOLRequest, fromAccount *types.Account) (*TransferSOLEstimate, error) {
+	recipient, err := m.ResolveRecipient(ctx, req.TargetAddress, "")
 	if err != nil {
-		return errors.Wrap(err, "failed to get recent blockhash")
+		return nil, err
 	}
-	m.log.Info(ctx, "recent Solana block hash", recentBlockhash.Blockhash)
 
-	feeCalculator, err := m.solanaClient.GetFeeForMessage(ctx, types.NewMessage(types.NewMessageParam{
-		FeePayer:        fromAccount.PublicKey,
-		RecentBlockhash: recentBlockhash.Blockhash,
-		Instructions:    []types.Instruction{},
-	}))
+	ownerBalance, err := m.solanaClient.GetBalance(ctx, fromAccount.PublicKey.ToBase58())
 	if err != nil {
-		return errors.Wrap(err, "failed to get transaction fee")
+		return nil, errors.Wrap(err, "failed to get sender balance")
 	}
-	m.log.Info(ctx, "estimated transaction fee", *feeCalculator)
+	m.log.Info(ctx, "sender balance", ownerBalance)
 
-	totalAmount := req.AmountLamports + *feeCalculator
-	if ownerBalance < totalAmount {
-		return errors.New("insufficient balance for transfer and fees")
+	rentExemptMinimum, err := m.solanaClient.GetMinimumBalanceForRentExemption(ctx, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get rent-exempt minimum")
 	}
 
-	transferInstruction := system.Transfer(system.TransferParam{
-		From:   fromAccount.PublicKey,
-		To:     recipientPubKey,
-		Amount: req.AmountLamports,
-	})
+	// In Max mode the amount is only known once the fee is, and the fee does
+	// not depend on it.
+	amount := req.AmountLamports
+	if req.Max {
+		amount = 0
+	}
 
-	tx, err := types.NewTransaction(types.NewTransactionParam{
-		Signers: []types.Account{*fromAccount},
-		Message: types.NewMessage(types.NewMessageParam{
-			FeePayer:        fromAccount.PublicKey,
-			RecentBlockhash: recentBlockhash.Blockhash,
-			Instructions:    []types.Instruction{transferInstruction},
-		}),
-	})
+	instructions, err := m.transferSOLInstructions(ctx, req, fromAccount, recipient.pubKey, amount)
 	if err != nil {
-		return errors.Wrap(err, "failed to create transaction")
+		return nil, err
 	}
 
-	txSignature, err := m.solanaClient.SendTransaction(ctx, tx)
+	fee, err := m.estimateFee(ctx, fromAccount.PublicKey, instructions)
 	if err != nil {
-		return errors.Wrap(err, "failed to send transaction")
+		return nil, err
 	}
-	m.log.Info(ctx, "transaction signature", txSignature)
 
-	// Wait for confirmation
-	txNotConfirmed := true
-	fmt.Print("waiting for confirmation")
-	for txNotConfirmed {
-		tx, err := m.solanaClient.GetTransaction(ctx, txSignature)
-		if err != nil {
-			m.log.Error(ctx, "failed to get transaction", err, txSignature)
+	if req.Max {
+		var reserve uint64
+		if req.KeepRentReserve {
+			reserve = rentExemptMinimum
 		}
-		if tx != nil && tx.Meta != nil && tx.Meta.Err == nil {
-			txNotConfirmed = false
-		} else {
-			fmt.Print(".")
-			time.Sleep(10 * time.Second)
+		if ownerBalance <= fee.TotalLamports+reserve {
+			// Max mode needs at least one lamport left to send.
+			return nil, &InsufficientBalanceError{Address: fromAccount.PublicKey.ToBase58(), Asset: AssetSOL, Purpose: "fees and rent reserve", Have: ownerBalance, Need: fee.TotalLamports + reserve + 1}
 		}
+
+		amount = ownerBalance - fee.TotalLamports - reserve
+		if err := setTransferSOLAmount(instructions, amount); err != nil {
+			return nil, err
+		}
+	} else {
+		if ownerBalance < amount+fee.TotalLamports {
+			return nil, &InsufficientBalanceError{Address: fromAccount.PublicKey.ToBase58(), Asset: AssetSOL, Purpose: "transfer and fees", Have: ownerBalance, Need: amount + fee.TotalLamports}
+		}
+
+		left := ownerBalance - amount - fee.TotalLamports
+		if left > 0 && left < rentExemptMinimum {
+			return nil, errors.Errorf("sender would be left with %d lamports, below the rent-exempt minimum of %d lamports; send at most %d lamports or use max mode", left, rentExemptMinimum, ownerBalance-fee.TotalLamports-rentExemptMinimum)
+		}
+	}
+
+	if err := m.checkContactLimits(AssetSOL, recipient.limitTransfers(amount)); err != nil {
+		return nil, err
+	}
+
+	if recipient.Lamports == 0 && amount < rentExemptMinimum {
+		return nil, errors.Errorf("recipient %s is a new account and %d lamports is below the rent-exempt minimum of %d lamports", req.TargetAddress, amount, rentExemptMinimum)
+	}
+
+	return &TransferSOLEstimate{TransactionFee: fee, AmountLamports: amount, instructions: instructions}, nil
+}
+
+// checkTransferSOLEstimate makes sure the sender still covers the confirmed
+// amount and fee, the balance may have changed since the estimate.
+func (m *Module) checkTransferSOLEstimate(ctx context.Context, fromAccount *types.Account, estimate *TransferSOLEstimate) error {
+	if estimate.instructions == nil || estimate.TransactionFee == nil {
+		return errors.New("transfer estimate does not come from EstimateTransferSOL")
 	}
-	fmt.Println("")
 
-	m.log.Info(ctx, "transaction has been confirmed", txSignature)
+	ownerBalance, err := m.solanaClient.GetBalance(ctx, fromAccount.PublicKey.ToBase58())
+	if err != nil {
+		return errors.Wrap(err, "failed to get sender balance")
+	}
+	if need := estimate.AmountLamports + estimate.TotalLamports; ownerBalance < need {
+		return &InsufficientBalanceError{Address: fromAccount.PublicKey.ToBase58(), Asset: AssetSOL, Purpose: "confirmed transfer and fees", Have: ownerBalance, Need: need}
+	}
 
 	return nil
 }
 
+func (m *Module) transferSOLInstructions(ctx context.Context, req *TransferSOLRequest, fromAccount *types.Account, to common.PublicKey, amount uint64) ([]types.Instruction, error) {
+	transferInstruction := system.Transfer(system.TransferParam{
+		From:   fromAccount.PublicKey,
+		To:     to,
+		Amount: amount,
+	})
+
+	if err := addReferences(&transferInstruction, req.References); err != nil {
+		return nil, err
+	}
+	memos, err := memoInstructions(req.Memo, fromAccount.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.withComputeBudget(ctx, fromAccount.PublicKey, append([]types.Instruction{transferInstruction}, memos...))
+}
+
+// setTransferSOLAmount rewrites the amount of the system transfer in place,
+// leaving the compute budget priced for the original message untouched.
+func setTransferSOLAmount(instructions []types.Instruction, amount uint64) error {
+	for i := range instructions {
+		data := instructions[i].Data
+		if instructions[i].ProgramID != common.SystemProgramID || len(data) != 12 ||
+			binary.LittleEndian.Uint32(data) != uint32(system.InstructionTransfer) {
+			continue
+		}
+
+		data = append([]byte{}, data...)
+		binary.LittleEndian.PutUint64(data[4:], amount)
+		instructions[i].Data = data
+
+		return nil
+	}
+
+	return errors.New("transfer instruction not found")
+}
+
 type TransferSPLTokenRequest struct {
 	OwnerKeyFilename string
 	TargetAddress    string
 	Amount           uint64
 	TokenMint        string
+	// Memo is sent through the SPL Memo program when set.
+	Memo string
+	// References are read-only keys attached to the transfer to find it later.
+	References []string
+}
+
+// EstimateTransferSPLToken prices the transfer, priority fee included.
+func (m *Module) EstimateTransferSPLToken(ctx context.Context, req *TransferSPLTokenRequest) (*TransactionFee, error) {
+	_, span := tracer.Start(ctx, "internal.solana.EstimateTransferSPLToken")
+	defer span.End()
+
+	fromAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load sender account")
+	}
+
+	instructions, err := m.transferSPLTokenInstructions(ctx, req, fromAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.estimateFee(ctx, fromAccount.PublicKey, instructions)
 }
 
-func (m *Module) TransferSPLToken(ctx context.Context, req *TransferSPLTokenRequest) error {
+func (m *Module) TransferSPLToken(ctx context.Context, req *TransferSPLTokenRequest) (*TransferResult, error) {
 	_, span := tracer.Start(ctx, "pkg.payment.TransferSPLToken")
 	defer span.End()
 
 	fromAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
 	if err != nil {
-		return errors.Wrap(err, "failed to load sender account")
+		return nil, errors.Wrap(err, "failed to load sender account")
+	}
+
+	instructions, err := m.transferSPLTokenInstructions(ctx, req, fromAccount)
+	if err != nil {
+		return nil, err
 	}
 
-	recipientPubKey := common.PublicKeyFromString(req.TargetAddress)
-	tokenMintPubKey := common.PublicKeyFromString(req.TokenMint)
+	confirmation, fee, err := m.sendAndConfirmPriced(ctx, []types.Account{*fromAccount}, fromAccount.PublicKey, instructions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransferResult{
+		TransactionResult: TransactionResult{Signature: confirmation.Signature, FeeLamports: fee.TotalLamports},
+		From:              fromAccount.PublicKey.ToBase58(),
+		To:                req.TargetAddress,
+		Asset:             req.TokenMint,
+		Amount:            req.Amount,
+	}, nil
+}
+
+func (m *Module) transferSPLTokenInstructions(ctx context.Context, req *TransferSPLTokenRequest, fromAccount *types.Account) ([]types.Instruction, error) {
+	tokenMintPubKey, err := ParseAddress(req.TokenMint)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid token mint")
+	}
+
+	recipient, err := m.ResolveRecipient(ctx, req.TargetAddress, req.TokenMint)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkContactLimits(req.TokenMint, recipient.limitTransfers(req.Amount)); err != nil {
+		return nil, err
+	}
 
 	fromTokenAccount, _, err := common.FindAssociatedTokenAddress(fromAccount.PublicKey, tokenMintPubKey)
 	if err != nil {
-		return errors.Wrap(err, "failed to find sender token account")
+		return nil, errors.Wrap(err, "failed to find sender token account")
 	}
 
-	ataAccount, _, err := common.FindAssociatedTokenAddress(recipientPubKey, tokenMintPubKey)
+	toTokenAccount, direct, err := recipient.tokenDestination(tokenMintPubKey)
 	if err != nil {
-		return errors.Wrap(err, "failed to find recipient token account")
+		return nil, err
 	}
 
 	instructionList := []types.Instruction{}
 
-	accountInfo, err := m.solanaClient.GetAccountInfo(ctx, ataAccount.ToBase58())
-	if err != nil || accountInfo.Owner.Bytes() == nil || len(accountInfo.Owner.Bytes()) == 0 || accountInfo.Owner != common.TokenProgramID {
+	if direct {
+		m.log.Info(ctx, "recipient is a token account, sending to it directly")
+	} else if accountInfo, err := m.solanaClient.GetAccountInfo(ctx, toTokenAccount.ToBase58()); err != nil || accountInfo.Owner != common.TokenProgramID {
 		m.log.Info(ctx, "recipient ATA not found, creating new one...")
 
 		ataInstruction := associated_token_account.Create(associated_token_account.CreateParam{
 			Funder:                 fromAccount.PublicKey,
-			Owner:                  recipientPubKey,
+			Owner:                  recipient.pubKey,
 			Mint:                   tokenMintPubKey,
-			AssociatedTokenAccount: ataAccount,
+			AssociatedTokenAccount: toTokenAccount,
 		})
 
 		instructionList = append(instructionList, ataInstruction)
 
-		m.log.Info(ctx, "created ATA for recipient", ataAccount.ToBase58())
-	}
-
-	recentBlockhash, err := m.solanaClient.GetLatestBlockhash(ctx)
-	if err != nil {
-		return errors.Wrap(err, "failed to get recent blockhash")
+		m.log.Info(ctx, "created ATA for recipient", toTokenAccount.ToBase58())
 	}
 
 	transferInstruction := token.Transfer(token.TransferParam{
 		From:   fromTokenAccount,
-		To:     ataAccount,
+		To:     toTokenAccount,
 		Amount: req.Amount,
 		Auth:   fromAccount.PublicKey,
 	})
-	instructionList = append(instructionList, transferInstruction)
 
-	tx, err := types.NewTransaction(types.NewTransactionParam{
-		Signers: []types.Account{*fromAccount},
-		Message: types.NewMessage(types.NewMessageParam{
-			FeePayer:        fromAccount.PublicKey,
-			RecentBlockhash: recentBlockhash.Blockhash,
-			Instructions:    instructionList,
-		}),
-	})
-	if err != nil {
-		return errors.Wrap(err, "failed to create transaction")
+	if err := addReferences(&transferInstruction, req.References); err != nil {
+		return nil, err
 	}
-
-	txSignature, err := m.solanaClient.SendTransaction(ctx, tx)
+	memos, err := memoInstructions(req.Memo, fromAccount.PublicKey)
 	if err != nil {
-		return errors.Wrap(err, "failed to send transaction")
+		return nil, err
 	}
-	m.log.Info(ctx, "transaction signature", txSignature)
-
-	txNotConfirmed := true
-	fmt.Print("waiting for confirmation")
-	for txNotConfirmed {
-		tx, err := m.solanaClient.GetTransaction(ctx, txSignature)
-		if err != nil {
-			m.log.Error(ctx, "failed to get transaction", err, txSignature)
-		}
-
-		if tx != nil && tx.Meta != nil && tx.Meta.Err == nil {
-			txNotConfirmed = false
-		} else {
-			fmt.Print(".")
-			time.Sleep(10 * time.Second)
-		}
-	}
-
-	m.log.Info(ctx, "transaction has been confirmed", txSignature)
+	instructionList = append(instructionList, transferInstruction)
+	instructionList = append(instructionList, memos...)
 
-	return nil
+	return m.withComputeBudget(ctx, fromAccount.PublicKey, instructionList)
 }
@@ -0,0 +1,60 @@
+package solana
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/program/system"
+	"github.com/blocto/solana-go-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_setTransferSOLAmount(t *testing.T) {
+	from, to := types.NewAccount().PublicKey, types.NewAccount().PublicKey
+	transfer := system.Transfer(system.TransferParam{From: from, To: to, Amount: 0})
+	original := transfer.Data
+
+	instructions := append(computeBudgetPlaceholder(), transfer)
+	require.NoError(t, setTransferSOLAmount(instructions, 12345))
+
+	require.Equal(t, system.Transfer(system.TransferParam{From: from, To: to, Amount: 12345}).Data, instructions[len(instructions)-1].Data)
+	require.Equal(t, uint64(0), binary.LittleEndian.Uint64(original[4:]))
+
+	require.Error(t, setTransferSOLAmount(computeBudgetPlaceholder(), 1))
+}
+
+func Test_checkTransferSOLEstimate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID int `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(map[string]any{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"result":  map[string]any{"context": map[string]any{"slot": 1}, "value": 1_000_000},
+		})
+	}))
+	defer server.Close()
+
+	m := &Module{solanaClient: client.NewClient(server.URL)}
+	from := types.NewAccount()
+	instructions := []types.Instruction{system.Transfer(system.TransferParam{From: from.PublicKey, To: types.NewAccount().PublicKey})}
+	ctx := context.Background()
+
+	covered := &TransferSOLEstimate{TransactionFee: &TransactionFee{TotalLamports: 5000}, AmountLamports: 995_000, instructions: instructions}
+	require.NoError(t, m.checkTransferSOLEstimate(ctx, &from, covered))
+
+	// The balance dropped below what the user confirmed.
+	short := &TransferSOLEstimate{TransactionFee: &TransactionFee{TotalLamports: 5000}, AmountLamports: 995_001, instructions: instructions}
+	var insufficient *InsufficientBalanceError
+	require.ErrorAs(t, m.checkTransferSOLEstimate(ctx, &from, short), &insufficient)
+	require.Equal(t, uint64(1_000_001), insufficient.Need)
+
+	require.Error(t, m.checkTransferSOLEstimate(ctx, &from, &TransferSOLEstimate{TransactionFee: &TransactionFee{}, AmountLamports: 1}))
+}
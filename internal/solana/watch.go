@@ -0,0 +1,487 @@
+package solana
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/client"
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/blocto/solana-go-sdk/rpc"
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+const (
+	WatchEventSOL         = "sol"
+	WatchEventToken       = "token"
+	WatchEventTransaction = "transaction"
+)
+
+type WatchEvent struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Slot      uint64    `json:"slot,omitempty"`
+	Account   string    `json:"account,omitempty"`
+	Mint      string    `json:"mint,omitempty"`
+	Balance   string    `json:"balance,omitempty"`
+	Change    string    `json:"change,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+	// Memos of the transaction, to match incoming payments.
+	Memos []string `json:"memos,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+type WatchRequest struct {
+	OwnerKeyFilename string
+	// Address is watched instead of the key file's account when set.
+	Address string
+}
+
+// Watch streams balance and token changes of the owner until ctx is done.
+// The current balances are reported first. It uses PubSub subscriptions and
+// falls back to polling when the node does not support them.
+func (m *Module) Watch(ctx context.Context, req *WatchRequest, onEvent func(*WatchEvent)) error {
+	_, span := tracer.Start(ctx, "internal.solana.Watch")
+	defer span.End()
+
+	address := req.Address
+	if address == "" {
+		ownerAccount, err := loadFromKeyFile(ctx, req.OwnerKeyFilename)
+		if err != nil {
+			return errors.Wrap(err, "failed to load owner account")
+		}
+		address = ownerAccount.PublicKey.ToBase58()
+	}
+
+	w := &watcher{
+		m:        m,
+		address:  address,
+		onEvent:  onEvent,
+		tokens:   map[string]*watchedTokenAccount{},
+		decimals: map[string]uint8{},
+		updates:  make(chan watchUpdate),
+		subs:     map[string]*wsSubscription{},
+	}
+	defer w.close()
+
+	if err := w.sync(ctx); err != nil {
+		return err
+	}
+
+	if err := w.subscribe(ctx); err != nil {
+		m.log.Error(ctx, "subscriptions unavailable, polling instead", err)
+		w.close()
+
+		return w.poll(ctx)
+	}
+
+	return w.listen(ctx)
+}
+
+type watchedTokenAccount struct {
+	Mint   string
+	Amount uint64
+}
+
+type watchUpdate struct {
+	kind    string
+	account string
+	data    json.RawMessage
+}
+
+type watcher struct {
+	m       *Module
+	address string
+	onEvent func(*WatchEvent)
+
+	balance  *uint64
+	tokens   map[string]*watchedTokenAccount
+	decimals map[string]uint8
+	lastSig  string
+
+	updates chan watchUpdate
+	subs    map[string]*wsSubscription
+}
+
+func (w *watcher) emit(event *WatchEvent) {
+	event.Time = time.Now().UTC()
+	w.onEvent(event)
+}
+
+func (w *watcher) setBalance(slot, lamports uint64) {
+	if w.balance != nil && *w.balance == lamports {
+		return
+	}
+
+	event := &WatchEvent{
+		Kind:    WatchEventSOL,
+		Slot:    slot,
+		Account: w.address,
+		Balance: formatTokenAmount(lamports, 9),
+	}
+	if w.balance != nil {
+		event.Change = formatTokenAmountChange(*w.balance, lamports, 9)
+	}
+	w.balance = &lamports
+
+	w.emit(event)
+}
+
+func (w *watcher) setTokenAmount(ctx context.Context, slot uint64, account, mint string, amount uint64) {
+	previous, known := w.tokens[account]
+	if known && previous.Amount == amount {
+		return
+	}
+
+	decimals, err := w.mintDecimals(ctx, mint)
+	if err != nil {
+		w.m.log.Error(ctx, "failed to get mint decimals", err, mint)
+	}
+
+	event := &WatchEvent{
+		Kind:    WatchEventToken,
+		Slot:    slot,
+		Account: account,
+		Mint:    mint,
+		Balance: formatTokenAmount(amount, decimals),
+	}
+	if known {
+		event.Change = formatTokenAmountChange(previous.Amount, amount, decimals)
+	}
+	w.tokens[account] = &watchedTokenAccount{Mint: mint, Amount: amount}
+
+	w.emit(event)
+}
+
+func (w *watcher) mintDecimals(ctx context.Context, mint string) (uint8, error) {
+	if decimals, ok := w.decimals[mint]; ok {
+		return decimals, nil
+	}
+
+	supply, err := w.m.solanaClient.GetTokenSupply(ctx, mint)
+	if err != nil {
+		return 0, err
+	}
+	w.decimals[mint] = supply.Decimals
+
+	return supply.Decimals, nil
+}
+
+// sync fetches the SOL balance and all token accounts, reporting changes.
+func (w *watcher) sync(ctx context.Context) error {
+	balance, err := w.m.solanaClient.GetBalanceAndContextWithConfig(ctx, w.address, client.GetBalanceConfig{
+		Commitment: w.m.config.commitment(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to get balance")
+	}
+	w.setBalance(balance.Context.Slot, balance.Value)
+
+	tokens, err := w.m.ownerTokenAccounts(ctx, w.address)
+	if err != nil {
+		return err
+	}
+
+	for account, tokenAccount := range tokens {
+		w.setTokenAmount(ctx, balance.Context.Slot, account, tokenAccount.Mint, tokenAccount.Amount)
+	}
+	for account, tokenAccount := range w.tokens {
+		if _, ok := tokens[account]; !ok {
+			// Closed token account.
+			w.setTokenAmount(ctx, balance.Context.Slot, account, tokenAccount.Mint, 0)
+			delete(w.tokens, account)
+		}
+	}
+
+	return nil
+}
+
+// syncTransactions reports transactions since the last seen one, used when
+// polling.
+func (w *watcher) syncTransactions(ctx context.Context) error {
+	signatures, err := w.m.solanaClient.GetSignaturesForAddressWithConfig(ctx, w.address, client.GetSignaturesForAddressConfig{
+		Limit: 100,
+		Until: w.lastSig,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to get signatures")
+	}
+	if len(signatures) == 0 {
+		return nil
+	}
+
+	// The first call only sets the starting point.
+	if w.lastSig != "" {
+		for i := len(signatures) - 1; i >= 0; i-- {
+			var memos []string
+			if signatures[i].Memo != nil {
+				memos = parseSignatureMemo(*signatures[i].Memo)
+			}
+			w.emitTransaction(signatures[i].Slot, signatures[i].Signature, memos, signatures[i].Err)
+		}
+	}
+	w.lastSig = signatures[0].Signature
+
+	return nil
+}
+
+func (w *watcher) emitTransaction(slot uint64, signature string, memos []string, txErr any) {
+	event := &WatchEvent{
+		Kind:      WatchEventTransaction,
+		Slot:      slot,
+		Account:   w.address,
+		Signature: signature,
+		Memos:     memos,
+	}
+	if txErr != nil {
+		event.Error = formatTransactionError(txErr)
+	}
+
+	w.emit(event)
+}
+
+func (w *watcher) poll(ctx context.Context) error {
+	ticker := time.NewTicker(w.m.config.WatchPollInterval)
+	defer ticker.Stop()
+
+	if err := w.syncTransactions(ctx); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := w.syncTransactions(ctx); err != nil {
+			w.m.log.Error(ctx, "failed to poll transactions", err)
+		}
+		if err := w.sync(ctx); err != nil {
+			w.m.log.Error(ctx, "failed to poll balances", err)
+		}
+	}
+}
+
+func (w *watcher) subscribe(ctx context.Context) error {
+	if err := w.addSubscription(ctx, "logs", w.address); err != nil {
+		return err
+	}
+	if err := w.addSubscription(ctx, WatchEventSOL, w.address); err != nil {
+		return err
+	}
+	for account := range w.tokens {
+		if err := w.addSubscription(ctx, WatchEventToken, account); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *watcher) addSubscription(ctx context.Context, kind, account string) error {
+	key := kind + ":" + account
+	if _, ok := w.subs[key]; ok {
+		return nil
+	}
+
+	var (
+		sub *wsSubscription
+		err error
+	)
+	if kind == "logs" {
+		sub, err = w.m.subscribeLogs(ctx, account)
+	} else {
+		sub, err = w.m.subscribeAccount(ctx, account)
+	}
+	if err != nil {
+		return err
+	}
+	w.subs[key] = sub
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case data := <-sub.Notifications():
+				select {
+				case w.updates <- watchUpdate{kind: kind, account: account, data: data}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (w *watcher) listen(ctx context.Context) error {
+	ticker := time.NewTicker(w.m.config.WatchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case update := <-w.updates:
+			if err := w.apply(ctx, update); err != nil {
+				w.m.log.Error(ctx, "failed to apply notification", err, update.account)
+			}
+		case <-ticker.C:
+			// Changes made while a subscription was reconnecting were not
+			// notified.
+			stale := false
+			for _, sub := range w.subs {
+				if sub.stale() {
+					stale = true
+				}
+			}
+			if stale {
+				if err := w.resync(ctx); err != nil {
+					w.m.log.Error(ctx, "failed to resync balances", err)
+				}
+			}
+		}
+	}
+}
+
+func (w *watcher) apply(ctx context.Context, update watchUpdate) error {
+	switch update.kind {
+	case "logs":
+		notification, err := decodeNotification[logsNotification](update.data)
+		if err != nil {
+			return err
+		}
+		w.emitTransaction(notification.Context.Slot, notification.Value.Signature, memosFromLogs(notification.Value.Logs), notification.Value.Err)
+
+		// The transaction may have opened token accounts.
+		return w.resync(ctx)
+	case WatchEventSOL:
+		notification, err := decodeNotification[accountNotification](update.data)
+		if err != nil {
+			return err
+		}
+		w.setBalance(notification.Context.Slot, notification.Value.Lamports)
+	case WatchEventToken:
+		notification, err := decodeNotification[accountNotification](update.data)
+		if err != nil {
+			return err
+		}
+
+		if notification.Value.Lamports == 0 {
+			// Closed token account.
+			if previous, ok := w.tokens[update.account]; ok {
+				w.setTokenAmount(ctx, notification.Context.Slot, update.account, previous.Mint, 0)
+			}
+
+			return nil
+		}
+
+		mint, amount, err := decodeTokenAccountBalance(notification.Value.Data)
+		if err != nil {
+			return err
+		}
+		w.setTokenAmount(ctx, notification.Context.Slot, update.account, mint, amount)
+	}
+
+	return nil
+}
+
+func (w *watcher) resync(ctx context.Context) error {
+	if err := w.sync(ctx); err != nil {
+		return err
+	}
+
+	for account := range w.tokens {
+		if err := w.addSubscription(ctx, WatchEventToken, account); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *watcher) close() {
+	for key, sub := range w.subs {
+		sub.Close()
+		delete(w.subs, key)
+	}
+}
+
+// ownerTokenAccounts returns the owner's token accounts of both token
+// programs, keyed by address.
+func (m *Module) ownerTokenAccounts(ctx context.Context, owner string) (map[string]*watchedTokenAccount, error) {
+	res := map[string]*watchedTokenAccount{}
+
+	for _, programID := range []common.PublicKey{common.TokenProgramID, common.Token2022ProgramID} {
+		accounts, err := m.solanaClient.RpcClient.GetTokenAccountsByOwnerWithConfig(ctx, owner,
+			rpc.GetTokenAccountsByOwnerConfigFilter{ProgramId: programID.ToBase58()},
+			rpc.GetTokenAccountsByOwnerConfig{
+				Encoding:   rpc.AccountEncodingBase64,
+				Commitment: m.config.commitment(),
+			},
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get token accounts")
+		}
+		if err := accounts.GetError(); err != nil {
+			return nil, errors.Wrap(err, "failed to get token accounts")
+		}
+
+		for _, account := range accounts.Result.Value {
+			data, err := decodeRpcAccountData(account.Account.Data)
+			if err != nil {
+				return nil, errors.Wrapf(err, "decode token account %s", account.Pubkey)
+			}
+
+			mint, amount, err := tokenAccountBalance(data)
+			if err != nil {
+				return nil, errors.Wrapf(err, "decode token account %s", account.Pubkey)
+			}
+			res[account.Pubkey] = &watchedTokenAccount{Mint: mint, Amount: amount}
+		}
+	}
+
+	return res, nil
+}
+
+// decodeTokenAccountBalance reads the mint and amount of a token account
+// from notification data, a [base64, "base64"] pair.
+func decodeTokenAccountBalance(data []string) (string, uint64, error) {
+	if len(data) != 2 || data[1] != string(rpc.AccountEncodingBase64) {
+		return "", 0, errors.New("unexpected account data format")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(data[0])
+	if err != nil {
+		return "", 0, errors.Wrap(err, "decode account data")
+	}
+
+	return tokenAccountBalance(raw)
+}
+
+// tokenAccountBalance reads the mint and amount, laid out the same way by
+// both token programs.
+func tokenAccountBalance(data []byte) (string, uint64, error) {
+	if len(data) < 72 {
+		return "", 0, errors.Errorf("token account data too short: %d bytes", len(data))
+	}
+
+	return common.PublicKeyFromBytes(data[:32]).ToBase58(), binary.LittleEndian.Uint64(data[64:72]), nil
+}
+
+// formatTokenAmountChange renders the signed difference between two amounts.
+func formatTokenAmountChange(previous, current uint64, decimals uint8) string {
+	if current >= previous {
+		return "+" + formatTokenAmount(current-previous, decimals)
+	}
+
+	return "-" + formatTokenAmount(previous-current, decimals)
+}
@@ -0,0 +1,283 @@
+package solana
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/tracer"
+)
+
+const (
+	wsPingInterval      = 30 * time.Second
+	wsMaxReconnectDelay = 30 * time.Second
+)
+
+// wsUrl derives the PubSub endpoint from the RPC URL when it is not
+// configured explicitly.
+func (c *config) wsUrl() string {
+	if c.WsUrl != "" {
+		return c.WsUrl
+	}
+
+	url := c.apiUrl()
+	switch {
+	case strings.HasPrefix(url, "https://"):
+		return "wss://" + strings.TrimPrefix(url, "https://")
+	case strings.HasPrefix(url, "http://"):
+		return "ws://" + strings.TrimPrefix(url, "http://")
+	default:
+		return url
+	}
+}
+
+type wsRequest struct {
+	JsonRpc string `json:"jsonrpc"`
+	ID      uint64 `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type wsMessage struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Method string `json:"method"`
+	Params struct {
+		Result       json.RawMessage `json:"result"`
+		Subscription uint64          `json:"subscription"`
+	} `json:"params"`
+}
+
+// wsNotification is the result of every PubSub notification used here.
+type wsNotification[T any] struct {
+	Context struct {
+		Slot uint64 `json:"slot"`
+	} `json:"context"`
+	Value T `json:"value"`
+}
+
+func decodeNotification[T any](data json.RawMessage) (*wsNotification[T], error) {
+	var res wsNotification[T]
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, errors.Wrap(err, "unmarshal notification")
+	}
+
+	return &res, nil
+}
+
+// wsSubscription owns one connection carrying a single subscription, which
+// keeps reconnecting simple: a new connection just subscribes again.
+// Notifications missed while disconnected are lost, so callers poll when
+// stale reports true.
+type wsSubscription struct {
+	url    string
+	method string
+	params []any
+
+	notifications chan json.RawMessage
+	// missed is set while disconnected and after a reconnect, until read by stale.
+	missed atomic.Bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// subscribe connects and subscribes before returning, so a node without
+// PubSub support is reported right away and the caller can fall back to
+// polling. The subscription lives until ctx is done or Close is called.
+func (m *Module) subscribe(ctx context.Context, method string, params ...any) (*wsSubscription, error) {
+	_, span := tracer.Start(ctx, "internal.solana.subscribe")
+	defer span.End()
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := &wsSubscription{
+		url:           m.config.wsUrl(),
+		method:        method,
+		params:        params,
+		notifications: make(chan json.RawMessage),
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+
+	conn, err := s.connect(ctx)
+	if err != nil {
+		cancel()
+
+		return nil, err
+	}
+
+	go m.runSubscription(ctx, s, conn)
+
+	return s, nil
+}
+
+func (s *wsSubscription) Notifications() <-chan json.RawMessage {
+	return s.notifications
+}
+
+// stale reports whether notifications may have been missed since the last
+// call.
+func (s *wsSubscription) stale() bool {
+	return s.missed.Swap(false)
+}
+
+func (s *wsSubscription) Close() {
+	s.cancel()
+	<-s.done
+}
+
+func (s *wsSubscription) connect(ctx context.Context) (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, s.url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "connect to websocket")
+	}
+
+	if err := conn.WriteJSON(wsRequest{JsonRpc: "2.0", ID: 1, Method: s.method, Params: s.params}); err != nil {
+		conn.Close()
+
+		return nil, errors.Wrapf(err, "send %s", s.method)
+	}
+
+	var res wsMessage
+	if err := conn.ReadJSON(&res); err != nil {
+		conn.Close()
+
+		return nil, errors.Wrapf(err, "read %s response", s.method)
+	}
+	if res.Error != nil {
+		conn.Close()
+
+		return nil, errors.Errorf("%s: %s (code %d)", s.method, res.Error.Message, res.Error.Code)
+	}
+
+	return conn, nil
+}
+
+func (m *Module) runSubscription(ctx context.Context, s *wsSubscription, conn *websocket.Conn) {
+	defer close(s.done)
+
+	delay := time.Second
+	for {
+		if err := s.read(ctx, conn); err != nil && ctx.Err() == nil {
+			m.log.Error(ctx, "websocket subscription interrupted", err, s.method)
+		}
+		s.missed.Store(true)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			var err error
+			if conn, err = s.connect(ctx); err == nil {
+				break
+			}
+			m.log.Error(ctx, "websocket reconnect failed", err, s.method)
+
+			if delay *= 2; delay > wsMaxReconnectDelay {
+				delay = wsMaxReconnectDelay
+			}
+		}
+
+		delay = time.Second
+		m.log.Info(ctx, "websocket subscription restored", s.method)
+	}
+}
+
+// read forwards notifications until the connection breaks or ctx is done.
+func (s *wsSubscription) read(ctx context.Context, conn *websocket.Conn) error {
+	var once sync.Once
+	closeConn := func() { once.Do(func() { conn.Close() }) }
+	defer closeConn()
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				closeConn()
+
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsPingInterval)); err != nil {
+					closeConn()
+
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return errors.Wrap(err, "read notification")
+		}
+		if msg.Method == "" {
+			continue
+		}
+
+		select {
+		case s.notifications <- msg.Params.Result:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+type signatureNotification struct {
+	Err any `json:"err"`
+}
+
+func (m *Module) subscribeSignature(ctx context.Context, signature string) (*wsSubscription, error) {
+	return m.subscribe(ctx, "signatureSubscribe", signature, map[string]any{
+		"commitment": m.config.commitment(),
+	})
+}
+
+type accountNotification struct {
+	Lamports uint64   `json:"lamports"`
+	Owner    string   `json:"owner"`
+	Data     []string `json:"data"`
+}
+
+func (m *Module) subscribeAccount(ctx context.Context, address string) (*wsSubscription, error) {
+	return m.subscribe(ctx, "accountSubscribe", address, map[string]any{
+		"commitment": m.config.commitment(),
+		"encoding":   "base64",
+	})
+}
+
+type logsNotification struct {
+	Signature string   `json:"signature"`
+	Err       any      `json:"err"`
+	Logs      []string `json:"logs"`
+}
+
+func (m *Module) subscribeLogs(ctx context.Context, address string) (*wsSubscription, error) {
+	return m.subscribe(ctx, "logsSubscribe", map[string]any{
+		"mentions": []string{address},
+	}, map[string]any{
+		"commitment": m.config.commitment(),
+	})
+}
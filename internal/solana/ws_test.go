@@ -0,0 +1,120 @@
+package solana
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blocto/solana-go-sdk/common"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kirill-a-belov/solana_token_manager/pkg/logger"
+)
+
+func Test_configWsUrl(t *testing.T) {
+	c := &config{ApiUrl: "https://api.mainnet-beta.solana.com"}
+	require.Equal(t, "wss://api.mainnet-beta.solana.com", c.wsUrl())
+
+	c = &config{UseSandbox: true, ApiSandboxUrl: "http://localhost:8899"}
+	require.Equal(t, "ws://localhost:8899", c.wsUrl())
+
+	c.WsUrl = "ws://localhost:8900"
+	require.Equal(t, "ws://localhost:8900", c.wsUrl())
+}
+
+func Test_decodeNotification(t *testing.T) {
+	account, err := decodeNotification[accountNotification]([]byte(`{"context":{"slot":5},"value":{"lamports":10,"owner":"11111111111111111111111111111111","data":["","base64"]}}`))
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), account.Context.Slot)
+	require.Equal(t, uint64(10), account.Value.Lamports)
+
+	logs, err := decodeNotification[logsNotification]([]byte(`{"context":{"slot":6},"value":{"signature":"sig","err":null,"logs":["Program log: hi"]}}`))
+	require.NoError(t, err)
+	require.Equal(t, "sig", logs.Value.Signature)
+	require.Nil(t, logs.Value.Err)
+}
+
+func Test_decodeTokenAccountBalance(t *testing.T) {
+	mint := common.PublicKeyFromString("So11111111111111111111111111111111111111112")
+	data := make([]byte, 165)
+	copy(data, mint.Bytes())
+	binary.LittleEndian.PutUint64(data[64:], 1500)
+
+	gotMint, amount, err := decodeTokenAccountBalance([]string{base64.StdEncoding.EncodeToString(data), "base64"})
+	require.NoError(t, err)
+	require.Equal(t, mint.ToBase58(), gotMint)
+	require.Equal(t, uint64(1500), amount)
+
+	_, _, err = decodeTokenAccountBalance([]string{base64.StdEncoding.EncodeToString(data[:10]), "base64"})
+	require.Error(t, err)
+}
+
+func Test_formatTokenAmountChange(t *testing.T) {
+	require.Equal(t, "+0.5", formatTokenAmountChange(1000000000, 1500000000, 9))
+	require.Equal(t, "-0.000000001", formatTokenAmountChange(2, 1, 9))
+}
+
+func Test_subscribeReconnects(t *testing.T) {
+	var connections atomic.Int32
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var req wsRequest
+		if err := conn.ReadJSON(&req); err != nil || req.Method != "accountSubscribe" {
+			return
+		}
+
+		n := connections.Add(1)
+		conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": n})
+		conn.WriteJSON(map[string]any{
+			"jsonrpc": "2.0",
+			"method":  "accountNotification",
+			"params": map[string]any{
+				"subscription": n,
+				"result":       map[string]any{"context": map[string]any{"slot": n}, "value": map[string]any{"lamports": 1}},
+			},
+		})
+
+		// The first connection drops right away to force a reconnect.
+		if n > 1 {
+			conn.ReadMessage()
+		}
+	}))
+	defer server.Close()
+
+	m := &Module{
+		config: &config{WsUrl: "ws" + strings.TrimPrefix(server.URL, "http"), ConfirmCommitment: "confirmed"},
+		log:    logger.New("test"),
+	}
+
+	sub, err := m.subscribeAccount(context.Background(), "11111111111111111111111111111111")
+	require.NoError(t, err)
+	defer sub.Close()
+
+	for slot := uint64(1); slot <= 2; slot++ {
+		select {
+		case data := <-sub.Notifications():
+			notification, err := decodeNotification[accountNotification](data)
+			require.NoError(t, err)
+			require.Equal(t, slot, notification.Context.Slot)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification")
+		}
+	}
+
+	require.True(t, sub.stale())
+	require.False(t, sub.stale())
+}
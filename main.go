@@ -4,9 +4,12 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/signal"
 	"runtime/debug"
+	"syscall"
 
 	"github.com/kirill-a-belov/solana_token_manager/cmd"
+	"github.com/kirill-a-belov/solana_token_manager/internal/solana"
 )
 
 func main() {
@@ -19,11 +22,21 @@ func main() {
 		}
 	}()
 
-	ctx := context.Background()
+	// A signal cancels the running command, which reports what it already
+	// sent. A second signal kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
-	if err := cmd.New(ctx).ExecuteContext(ctx); err != nil {
-		os.Exit(-1)
+	err := cmd.New(ctx).ExecuteContext(ctx)
+	if err != nil && ctx.Err() != nil {
+		// RPC errors carry the signal as text only.
+		err = solana.WithKind(solana.ErrorKindInterrupted, err)
+	}
+	stop()
+	if err != nil {
+		os.Exit(cmd.ExitCode(err))
 	}
-
-	fmt.Println("Токен создан и эмиссия завершена.")*/
 }
@@ -21,7 +21,7 @@ type log struct {
 func (l *log) Error(ctx context.Context, args ...interface{}) {
 	var result strings.Builder
 
-	for _, arg := range append(args) {
+	for _, arg := range args {
 		if _, err := result.WriteString(fmt.Sprintf(" %v ", arg)); err != nil {
 			panic(err)
 		}
@@ -36,6 +36,6 @@ func (l *log) Info(ctx context.Context, args ...interface{}) {
 
 func New(prefix string) Logger {
 	return &log{
-		l: stdLog.New(os.Stdout, fmt.Sprintf("%s: ", prefix), stdLog.LstdFlags),
+		l: stdLog.New(os.Stderr, fmt.Sprintf("%s: ", prefix), stdLog.LstdFlags),
 	}
 }